@@ -0,0 +1,269 @@
+// linkcheck checks links on a running status-links server and saves the
+// report, so link checks can gate CI pipelines. It exits 0 when every link
+// is available, 1 when any link is unavailable, blocked or rejected by the
+// server and 2 when the check itself could not be run.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"status-links/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
+const pollInterval = time.Second
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("linkcheck", flag.ContinueOnError)
+	server := fs.String("server", "http://localhost:8080", "status-links server URL")
+	format := fs.String("format", models.FormatJUnit, "report format: junit, sarif, pdf, csv, json, html, markdown or xlsx")
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	file := fs.String("file", "", "read URLs from this file, one per line; - reads stdin")
+	sets := fs.String("sets", "", "report on already checked sets, e.g. 1,2, instead of checking URLs")
+	sortBy := fs.String("sort", "", "row order: url, status or latency, prefix - to reverse")
+	timeout := fs.Duration("timeout", 5*time.Minute, "give up waiting for results after this long")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: linkcheck [flags] url...")
+		fmt.Fprintln(os.Stderr, "       linkcheck [flags] -file urls.txt")
+		fmt.Fprintln(os.Stderr, "       linkcheck [flags] -sets 1,2")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	if _, ok := models.LookupFormat(*format); !ok {
+		slog.Error("Unknown report format", "format", *format)
+		return exitError
+	}
+	if !models.ValidSort(*sortBy) {
+		slog.Error("Unknown sort order", "sort", *sortBy)
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	c := client{base: strings.TrimSuffix(*server, "/")}
+
+	var nums []int
+	var rejected int
+	if *sets != "" {
+		for _, field := range strings.Split(*sets, ",") {
+			num, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				slog.Error("Invalid set number", "value", field)
+				return exitError
+			}
+			nums = append(nums, num)
+		}
+	} else {
+		links, err := readLinks(fs.Args(), *file)
+		if err != nil {
+			slog.Error("Failed to read URLs", "error", err)
+			return exitError
+		}
+		if len(links) == 0 {
+			fs.Usage()
+			return exitError
+		}
+		var num int
+		num, rejected, err = c.check(ctx, links)
+		if err != nil {
+			slog.Error("Link check failed", "error", err)
+			return exitError
+		}
+		nums = []int{num}
+	}
+
+	report, err := c.report(ctx, models.SetNumsOfLinksGet{NumsLinks: nums, Sort: *sortBy, Format: *format})
+	if err != nil {
+		slog.Error("Failed to load report", "error", err)
+		return exitError
+	}
+	if err := writeReport(*output, report.Report); err != nil {
+		slog.Error("Failed to write report", "error", err)
+		return exitError
+	}
+
+	slog.Info("Links checked", "sets", nums, "total", report.Total, "failed", report.Failed, "rejected", rejected)
+	if report.Failed > 0 || rejected > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// readLinks takes URLs from the arguments and from file, skipping blank lines
+// and # comments.
+func readLinks(args []string, file string) ([]string, error) {
+	links := append([]string(nil), args...)
+	if file == "" {
+		return links, nil
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			links = append(links, line)
+		}
+	}
+	return links, scanner.Err()
+}
+
+func writeReport(output string, data []byte) error {
+	if output == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(output, data, 0o644)
+}
+
+type client struct {
+	base string
+	http http.Client
+}
+
+// check submits links as a background job and waits for it to finish. It
+// returns the job's set number and how many URLs the server rejected; those
+// are not in the report.
+func (c *client) check(ctx context.Context, links []string) (int, int, error) {
+	body, err := json.Marshal(models.SetLinksGet{Links: links})
+	if err != nil {
+		return 0, 0, err
+	}
+	var status models.JobStatus
+	if err := c.do(ctx, http.MethodPost, "/api/submitUrls", body, http.StatusAccepted, &status); err != nil {
+		return 0, 0, err
+	}
+	rejected := len(status.Rejected)
+	for _, link := range status.Rejected {
+		slog.Warn("URL rejected", "url", link.URL, "reason", link.Reason)
+	}
+
+	path := "/api/jobStatus?links_num=" + strconv.Itoa(status.ListNum)
+	for {
+		switch status.State {
+		case models.JobDone:
+			return status.ListNum, rejected, nil
+		case models.JobFailed:
+			return 0, rejected, fmt.Errorf("job %d failed: %s", status.ListNum, status.Error)
+		}
+		if err := sleep(ctx, pollInterval); err != nil {
+			return 0, rejected, err
+		}
+		if err := c.do(ctx, http.MethodGet, path, nil, http.StatusOK, &status); err != nil {
+			return 0, rejected, err
+		}
+	}
+}
+
+// report downloads the report, waiting while the server still checks the
+// sets.
+func (c *client) report(ctx context.Context, req models.SetNumsOfLinksGet) (*models.ListOfProcessedLinks, error) {
+	body, err := json.Marshal(models.SetNumsOfLinksGet{NumsLinks: req.NumsLinks})
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"format": {req.Format}}
+	if req.Sort != "" {
+		query.Set("sort", req.Sort)
+	}
+	path := "/api/loadUrls?" + query.Encode()
+
+	for {
+		resp, err := c.send(ctx, http.MethodGet, path, body)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return parseReport(resp.Header, data)
+		case http.StatusAccepted:
+			if err := sleep(ctx, pollInterval); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+		}
+	}
+}
+
+func parseReport(header http.Header, data []byte) (*models.ListOfProcessedLinks, error) {
+	total, err := strconv.Atoi(header.Get(models.HeaderLinksTotal))
+	if err != nil {
+		return nil, errors.New("server did not report link availability, it may be too old")
+	}
+	failed, err := strconv.Atoi(header.Get(models.HeaderLinksFailed))
+	if err != nil {
+		return nil, errors.New("server did not report link availability, it may be too old")
+	}
+	return &models.ListOfProcessedLinks{Report: data, Total: total, Failed: failed}, nil
+}
+
+func (c *client) do(ctx context.Context, method, path string, body []byte, want int, v any) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *client) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.http.Do(req)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
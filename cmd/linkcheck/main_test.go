@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"status-links/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	// server answers every submission as done at once, rejecting ftp:// URLs,
+	// and reports failed links of the checked set.
+	server := func(failed string) *httptest.Server {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/submitUrls", func(w http.ResponseWriter, r *http.Request) {
+			var set models.SetLinksGet
+			json.NewDecoder(r.Body).Decode(&set)
+			status := models.JobStatus{ListNum: 1, State: models.JobDone}
+			for _, link := range set.Links {
+				if strings.HasPrefix(link, "ftp:") {
+					status.Rejected = append(status.Rejected, models.RejectedLink{URL: link, Reason: "unsupported scheme"})
+				}
+			}
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(status)
+		})
+		mux.HandleFunc("/api/loadUrls", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(models.HeaderLinksTotal, "1")
+			w.Header().Set(models.HeaderLinksFailed, failed)
+			w.Write([]byte("<testsuites/>"))
+		})
+		return httptest.NewServer(mux)
+	}
+
+	cases := []struct {
+		name   string
+		failed string
+		links  []string
+		want   int
+	}{
+		{"all links available", "0", []string{"https://example.com"}, exitOK},
+		{"unavailable links fail the check", "1", []string{"https://example.com"}, exitFailed},
+		{"rejected links fail the check", "0", []string{"https://example.com", "ftp://example.com"}, exitFailed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := server(tc.failed)
+			defer srv.Close()
+
+			args := append([]string{"-server", srv.URL, "-o", filepath.Join(t.TempDir(), "report.xml")}, tc.links...)
+			if got := run(args); got != tc.want {
+				t.Errorf("Expected exit status %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"status-links/internal/config"
+	"status-links/internal/storage"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "migrate":
+		os.Exit(migrate(os.Args[2:]))
+	case "reencrypt":
+		os.Exit(reencrypt(os.Args[2:]))
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: storagectl migrate [-all file] [-links file] [-nums file] [-to file]")
+	fmt.Fprintln(os.Stderr, "       storagectl reencrypt [-all file] [-links file] [-nums file]")
+}
+
+func migrate(args []string) int {
+	cfg := config.MustLoad()
+
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	allTasks := fs.String("all", cfg.NameFileAllTasks, "AllTasks.json to import")
+	links := fs.String("links", cfg.NameFileProcessTasksLinks, "pending links file to import")
+	nums := fs.String("nums", cfg.NameFileProcessTasksNums, "pending nums file to import")
+	to := fs.String("to", cfg.NameFileBolt, "bbolt file to create")
+	fs.Parse(args)
+
+	keys, err := storage.LoadKeyring(cfg.StorageEncryptionKeys, cfg.StorageEncryptionKeyFile)
+	if err != nil {
+		slog.Error("Failed to load storage encryption keys", "error", err)
+		return 1
+	}
+
+	stats, err := storage.MigrateJSONToBolt(*allTasks, *links, *nums, *to, keys)
+	if err != nil {
+		slog.Error("Migration failed", "error", err)
+		return 1
+	}
+
+	slog.Info("Migration finished",
+		"to", *to,
+		"sets", stats.Sets,
+		"pending_links", stats.PendingLinks,
+		"pending_nums", stats.PendingNums)
+	return 0
+}
+
+// reencrypt rewrites the JSON storage files with the first configured key.
+// Run it with the service stopped, after putting a new key first.
+func reencrypt(args []string) int {
+	cfg := config.MustLoad()
+
+	fs := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	allTasks := fs.String("all", cfg.NameFileAllTasks, "AllTasks.json to re-encrypt")
+	links := fs.String("links", cfg.NameFileProcessTasksLinks, "pending links file to re-encrypt")
+	nums := fs.String("nums", cfg.NameFileProcessTasksNums, "pending nums file to re-encrypt")
+	fs.Parse(args)
+
+	keys, err := storage.LoadKeyring(cfg.StorageEncryptionKeys, cfg.StorageEncryptionKeyFile)
+	if err != nil {
+		slog.Error("Failed to load storage encryption keys", "error", err)
+		return 1
+	}
+
+	stats, err := storage.ReencryptFiles(keys, *allTasks, *links, *nums)
+	if err != nil {
+		slog.Error("Re-encryption failed", "error", err)
+		return 1
+	}
+
+	slog.Info("Re-encryption finished", "rewritten", stats.Rewritten, "skipped", stats.Skipped)
+	return 0
+}
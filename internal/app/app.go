@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
@@ -44,19 +45,110 @@ func NewApp(cfg *config.Config) *App {
 }
 
 func (a *App) initStorages() {
-	a.storages = &Storages{
-		temp: storage.NewTempStorage(),
-		reliable: storage.NewReliableStorage(
+	a.storages = &Storages{}
+
+	switch a.cfg.StorageBackend {
+	case "wal":
+		wal, err := storage.NewWALStorage(a.cfg.NameFileWAL)
+		if err != nil {
+			slog.Error("Failed to open WAL storage", "error", err)
+			os.Exit(1)
+		}
+		a.storages.reliable = wal
+	case "bolt":
+		bolt, err := storage.NewBoltStorage(a.cfg.NameFileBolt)
+		if err != nil {
+			slog.Error("Failed to open bolt storage", "error", err)
+			os.Exit(1)
+		}
+		a.storages.reliable = bolt
+	case "sql":
+		db, err := storage.NewSQLStorage(storage.SQLOptions{
+			Driver:          a.cfg.SQLDriver,
+			DSN:             a.cfg.SQLDSN,
+			MaxOpenConns:    a.cfg.SQLMaxOpenConns,
+			MaxIdleConns:    a.cfg.SQLMaxIdleConns,
+			ConnMaxLifetime: a.cfg.SQLConnMaxLifetime,
+		})
+		if err != nil {
+			slog.Error("Failed to open SQL storage", "error", err)
+			os.Exit(1)
+		}
+		a.storages.reliable = db
+	default:
+		if a.cfg.StorageBackend != "json" {
+			slog.Warn("Unknown storage backend, falling back to json", "backend", a.cfg.StorageBackend)
+		}
+		keys, err := storage.LoadKeyring(a.cfg.StorageEncryptionKeys, a.cfg.StorageEncryptionKeyFile)
+		if err != nil {
+			slog.Error("Failed to load storage encryption keys", "error", err)
+			os.Exit(1)
+		}
+		a.storages.reliable = storage.NewEncryptedReliableStorage(
 			a.cfg.NameFileAllTasks,
 			a.cfg.NameFileProcessTasksLinks,
 			a.cfg.NameFileProcessTasksNums,
-		),
+			keys,
+		)
+	}
+
+	if a.cfg.CacheMaxEntries > 0 || a.cfg.CacheMaxBytes > 0 {
+		if _, ok := a.storages.reliable.(storage.SetLister); !ok {
+			slog.Error("Cache limits need a storage that reads single sets, use the wal, bolt or sql backend", "backend", a.cfg.StorageBackend)
+			os.Exit(1)
+		}
+		a.storages.temp = storage.NewLRUTempStorage(a.storages.reliable, storage.LRUOptions{
+			MaxEntries: a.cfg.CacheMaxEntries,
+			MaxBytes:   a.cfg.CacheMaxBytes,
+		})
+	} else {
+		a.storages.temp = storage.NewStripedTempStorage(a.cfg.TempShards)
 	}
 }
 
 func (a *App) initServices() {
 	a.services = &Services{
-		LinksService: services.NewLinksService(a.storages.temp, a.storages.reliable),
+		LinksService: services.NewLinksService(a.storages.temp, a.storages.reliable, services.Config{
+			Workers:        a.cfg.CheckWorkers,
+			MaxRunningJobs: a.cfg.MaxRunningJobs,
+			CheckMethod:    a.cfg.CheckMethod,
+			MaxBodyBytes:   a.cfg.CheckMaxBodyBytes,
+			Retry: services.RetryPolicy{
+				MaxAttempts: a.cfg.RetryMaxAttempts,
+				BaseDelay:   a.cfg.RetryBaseDelay,
+				MaxDelay:    a.cfg.RetryMaxDelay,
+				Jitter:      a.cfg.RetryJitter,
+			},
+			HostLimits: services.HostLimits{
+				RequestsPerSecond: a.cfg.HostRateLimit,
+				Burst:             a.cfg.HostBurst,
+				MaxConcurrent:     a.cfg.HostMaxConcurrent,
+			},
+			UserAgent: a.cfg.UserAgent,
+			Headers:   a.cfg.CheckHeaders,
+			SSRF: services.SSRFPolicy{
+				Enabled: a.cfg.SSRFProtection,
+				Allow:   a.cfg.SSRFAllowCIDRs,
+				Deny:    a.cfg.SSRFDenyCIDRs,
+			},
+			Redirects: services.RedirectPolicy{
+				Mode:            a.cfg.RedirectPolicy,
+				MaxHops:         a.cfg.RedirectMaxHops,
+				FlagCrossDomain: a.cfg.RedirectFlagCrossDomain,
+				ParkedDomains:   a.cfg.ParkedDomains,
+			},
+			CertExpiryWarnDays: a.cfg.CertExpiryWarnDays,
+			ReportLang:         a.cfg.ReportLang,
+			ReportFontFile:     a.cfg.ReportFontFile,
+			CompactInterval:    a.cfg.CompactInterval,
+			Retention: services.RetentionPolicy{
+				MaxAge:   a.cfg.RetentionMaxAge,
+				MaxSets:  a.cfg.RetentionMaxSets,
+				Interval: a.cfg.RetentionInterval,
+			},
+			InstanceID: a.cfg.InstanceID,
+			JobLease:   a.cfg.JobLease,
+		}),
 	}
 }
 
@@ -66,6 +158,7 @@ func (a *App) initHTTP() {
 		slog.Error("Failed to create handler", "error", err)
 		os.Exit(1)
 	}
+	handler.AdminToken = a.cfg.AdminToken
 
 	router := a.setupRoutes(handler)
 
@@ -90,6 +183,10 @@ func (a *App) setupRoutes(handler *handlers.Handler) http.Handler {
 		"/api/loadUnfinishedWork": handler.LoadUnfinishedWork,
 		"/api/saveNewUrls":        handler.SaveNewUrls,
 		"/api/loadUrls":           handler.LoadUrls,
+		"/api/submitUrls":         handler.SubmitUrls,
+		"/api/jobStatus":          handler.JobStatus,
+		"/api/admin/compact":      handler.Compact,
+		"/api/admin/cache":        handler.Cache,
 	}
 
 	for path, handlerFunc := range apiRoutes {
@@ -130,5 +227,10 @@ func (a *App) shutdown() {
 		os.Exit(1)
 	}
 	a.services.LinksService.WaitForCompletion()
+	if closer, ok := a.storages.reliable.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			slog.Error("error in storage Close", "error", err)
+		}
+	}
 	slog.Info("Server stopped")
 }
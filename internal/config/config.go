@@ -2,6 +2,7 @@ package config
 
 import (
 	"log/slog"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
@@ -12,6 +13,61 @@ type Config struct {
 	NameFileAllTasks          string `env:"ALL_TASKS_FILE" envDefault:"storage/AllTasks.json"`
 	NameFileProcessTasksLinks string `env:"PROCESS_LINKS_FILE" envDefault:"storage/ProcessTasksLinks.json"`
 	NameFileProcessTasksNums  string `env:"PROCESS_NUMS_FILE" envDefault:"storage/ProcessTasksNums.json"`
+	StorageBackend            string `env:"STORAGE_BACKEND" envDefault:"json"`
+	NameFileWAL               string `env:"WAL_FILE" envDefault:"storage/AllTasks.wal"`
+	NameFileBolt              string `env:"BOLT_FILE" envDefault:"storage/links.db"`
+	CheckWorkers              int    `env:"CHECK_WORKERS" envDefault:"32"`
+	MaxRunningJobs            int    `env:"MAX_RUNNING_JOBS" envDefault:"4"`
+	CheckMethod               string `env:"CHECK_METHOD" envDefault:"head-get"`
+	CheckMaxBodyBytes         int64  `env:"CHECK_MAX_BODY_BYTES" envDefault:"16384"`
+
+	StorageEncryptionKeys    []string `env:"STORAGE_ENCRYPTION_KEYS"`
+	StorageEncryptionKeyFile string   `env:"STORAGE_ENCRYPTION_KEY_FILE"`
+
+	SQLDriver          string        `env:"SQL_DRIVER" envDefault:"pgx"`
+	SQLDSN             string        `env:"SQL_DSN"`
+	SQLMaxOpenConns    int           `env:"SQL_MAX_OPEN_CONNS" envDefault:"10"`
+	SQLMaxIdleConns    int           `env:"SQL_MAX_IDLE_CONNS" envDefault:"5"`
+	SQLConnMaxLifetime time.Duration `env:"SQL_CONN_MAX_LIFETIME" envDefault:"30m"`
+
+	RetryMaxAttempts int           `env:"RETRY_MAX_ATTEMPTS" envDefault:"3"`
+	RetryBaseDelay   time.Duration `env:"RETRY_BASE_DELAY" envDefault:"500ms"`
+	RetryMaxDelay    time.Duration `env:"RETRY_MAX_DELAY" envDefault:"10s"`
+	RetryJitter      float64       `env:"RETRY_JITTER" envDefault:"0.2"`
+
+	HostRateLimit     float64           `env:"HOST_RATE_LIMIT" envDefault:"5"`
+	HostBurst         int               `env:"HOST_BURST" envDefault:"5"`
+	HostMaxConcurrent int               `env:"HOST_MAX_CONCURRENT" envDefault:"4"`
+	UserAgent         string            `env:"USER_AGENT" envDefault:"status-links/1.0"`
+	CheckHeaders      map[string]string `env:"CHECK_HEADERS"`
+
+	SSRFProtection bool     `env:"SSRF_PROTECTION" envDefault:"true"`
+	SSRFAllowCIDRs []string `env:"SSRF_ALLOW_CIDRS"`
+	SSRFDenyCIDRs  []string `env:"SSRF_DENY_CIDRS"`
+
+	RedirectPolicy          string   `env:"REDIRECT_POLICY" envDefault:"follow"`
+	RedirectMaxHops         int      `env:"REDIRECT_MAX_HOPS" envDefault:"10"`
+	RedirectFlagCrossDomain bool     `env:"REDIRECT_FLAG_CROSS_DOMAIN" envDefault:"true"`
+	ParkedDomains           []string `env:"PARKED_DOMAINS"`
+
+	CertExpiryWarnDays int `env:"CERT_EXPIRY_WARN_DAYS" envDefault:"14"`
+
+	ReportLang     string `env:"REPORT_LANG" envDefault:"ru"`
+	ReportFontFile string `env:"REPORT_FONT_FILE"`
+
+	CompactInterval time.Duration `env:"COMPACT_INTERVAL" envDefault:"1h"`
+	AdminToken      string        `env:"ADMIN_TOKEN"`
+
+	RetentionMaxAge   time.Duration `env:"RETENTION_MAX_AGE" envDefault:"0"`
+	RetentionMaxSets  int           `env:"RETENTION_MAX_SETS" envDefault:"0"`
+	RetentionInterval time.Duration `env:"RETENTION_INTERVAL" envDefault:"10m"`
+
+	CacheMaxEntries int   `env:"CACHE_MAX_ENTRIES" envDefault:"0"`
+	CacheMaxBytes   int64 `env:"CACHE_MAX_BYTES" envDefault:"0"`
+	TempShards      int   `env:"TEMP_SHARDS" envDefault:"32"`
+
+	InstanceID string        `env:"INSTANCE_ID"`
+	JobLease   time.Duration `env:"JOB_LEASE" envDefault:"5m"`
 }
 
 func MustLoad() *Config {
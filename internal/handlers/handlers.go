@@ -4,16 +4,21 @@ import (
 	"archive/zip"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"status-links/internal/models"
 	"status-links/internal/services"
+	"strconv"
+
+	"golang.org/x/net/http/httpguts"
 )
 
 type Handler struct {
 	LinkService services.LinkProcessor
+	AdminToken  string
 }
 
 func NewHandler(linkService services.LinkProcessor) (*Handler, error) {
@@ -28,17 +33,23 @@ func (h *Handler) LoadUnfinishedWork(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sortBy := r.URL.Query().Get("sort")
+	if !models.ValidSort(sortBy) {
+		http.Error(w, `{"error":"invalid sort"}`, http.StatusBadRequest)
+		return
+	}
+
 	unfinishedWork := h.LinkService.UploadAllUnfinishedWork()
 
 	var pdfsWithData []models.ListOfProcessedLinks
 	for _, pdf := range unfinishedWork.Pdfs {
-		if len(pdf.PDF) > 0 {
+		if len(pdf.Report) > 0 {
 			pdfsWithData = append(pdfsWithData, pdf)
 		}
 	}
 	fmt.Println(len(pdfsWithData), len(unfinishedWork.Links))
 	if len(pdfsWithData) > 0 || len(unfinishedWork.Links) > 0 {
-		h.createZipResponse(w, pdfsWithData, unfinishedWork.Links, "unfinished_work")
+		h.createZipResponse(w, pdfsWithData, unfinishedWork.Links, sortBy, "unfinished_work")
 		return
 	}
 
@@ -53,37 +64,97 @@ func (h *Handler) SaveNewUrls(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
-	var req models.SetLinksGet
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		slog.Warn("Invalid JSON in link request", "error", err)
-		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+	req, ok := decodeLinksRequest(w, r)
+	if !ok {
 		return
 	}
 
-	if len(req.Links) == 0 {
-		http.Error(w, `{"error":"no links provided"}`, http.StatusBadRequest)
+	result, err := h.LinkService.AddLinkSet(req)
+	if err != nil {
+		http.Error(w, `{"error":"failed to store link set"}`, http.StatusInternalServerError)
 		return
 	}
 
-	if len(req.Links) > 100 {
-		http.Error(w, `{"error":"too many links, maximum 100"}`, http.StatusBadRequest)
+	if len(result.Answer) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "no valid links",
+			"rejected": result.Rejected,
+		})
 		return
 	}
 
-	result := h.LinkService.AddLinkSet(req)
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
 	response := map[string]interface{}{
-		"links":     result.Answer,
+		"links":     models.OrderedAnswer{Links: result.Links(), Answer: result.Answer},
 		"links_num": result.ListNum,
 	}
+	if len(result.Inputs) > 0 {
+		response["inputs"] = result.Inputs
+	}
+	if len(result.Rejected) > 0 {
+		response["rejected"] = result.Rejected
+	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
+func (h *Handler) SubmitUrls(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, ok := decodeLinksRequest(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := h.LinkService.SubmitLinkSet(req)
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case errors.Is(err, services.ErrNoValidLinks):
+		w.WriteHeader(http.StatusBadRequest)
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+func (h *Handler) JobStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	listNum, err := strconv.Atoi(r.URL.Query().Get("links_num"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid links_num"}`, http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.LinkService.GetJobStatus(listNum)
+	if err == services.ErrJobNotFound {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "job_not_found",
+			"message": "No job with this number",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(status)
+}
+
 func (h *Handler) LoadUrls(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -108,6 +179,31 @@ func (h *Handler) LoadUrls(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if sortBy := r.URL.Query().Get("sort"); sortBy != "" {
+		req.Sort = sortBy
+	}
+	if !models.ValidSort(req.Sort) {
+		http.Error(w, `{"error":"invalid sort"}`, http.StatusBadRequest)
+		return
+	}
+
+	if format := r.URL.Query().Get("format"); format != "" {
+		req.Format = format
+	} else if accept := r.Header.Get("Accept"); req.Format == "" && accept != "" {
+		// An Accept header without a supported type keeps the default report.
+		if format, ok := models.FormatFromAccept(accept); ok {
+			req.Format = format
+		}
+	}
+	if req.Format != "" {
+		format, ok := models.LookupFormat(req.Format)
+		if !ok {
+			http.Error(w, `{"error":"invalid format"}`, http.StatusBadRequest)
+			return
+		}
+		req.Format = format.Name
+	}
+
 	result, err := h.LinkService.GiveLinkAnswer(req)
 	if err == services.ErrTooBigIndex {
 		w.Header().Set("Content-Type", "application/json")
@@ -119,8 +215,28 @@ func (h *Handler) LoadUrls(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(result.PDF) > 0 {
-		h.sendPDFResponse(w, result.PDF, "links_report")
+	if err == services.ErrExpired {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusGone)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "expired",
+			"message": "One or more link sets were removed by the retention policy",
+		})
+		return
+	}
+
+	if err == services.ErrJobNotReady {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "One or more link sets are still being checked",
+			"status":  "processing",
+		})
+		return
+	}
+
+	if len(result.Report) > 0 {
+		h.sendReportResponse(w, result, "links_report")
 		return
 	}
 
@@ -132,30 +248,142 @@ func (h *Handler) LoadUrls(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *Handler) sendPDFResponse(w http.ResponseWriter, pdfData []byte, filename string) {
-	w.Header().Set("Content-Type", "application/pdf")
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, filename))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(pdfData)))
+// authorizeAdmin lets through requests bearing AdminToken. Without a
+// configured token the admin endpoints are closed.
+func (h *Handler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if h.AdminToken == "" {
+		http.Error(w, `{"error":"admin_disabled"}`, http.StatusForbidden)
+		return false
+	}
+	if r.Header.Get("Authorization") != "Bearer "+h.AdminToken {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) Compact(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(h.LinkService.CompactionStatus())
+	case http.MethodPost:
+		err := h.LinkService.StartCompaction()
+		w.Header().Set("Content-Type", "application/json")
+		switch err {
+		case nil:
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(h.LinkService.CompactionStatus())
+		case services.ErrCompactionRunning:
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "compaction_running"})
+		case services.ErrCompactionUnsupported:
+			w.WriteHeader(http.StatusNotImplemented)
+			json.NewEncoder(w).Encode(map[string]string{"error": "compaction_unsupported"})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) Cache(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := h.LinkService.CacheStats()
+	w.Header().Set("Content-Type", "application/json")
+	if err == services.ErrCacheUnsupported {
+		w.WriteHeader(http.StatusNotImplemented)
+		json.NewEncoder(w).Encode(map[string]string{"error": "cache_disabled"})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+func decodeLinksRequest(w http.ResponseWriter, r *http.Request) (models.SetLinksGet, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	var req models.SetLinksGet
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Warn("Invalid JSON in link request", "error", err)
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return req, false
+	}
+
+	if len(req.Links) == 0 {
+		http.Error(w, `{"error":"no links provided"}`, http.StatusBadRequest)
+		return req, false
+	}
+
+	if len(req.Links) > 100 {
+		http.Error(w, `{"error":"too many links, maximum 100"}`, http.StatusBadRequest)
+		return req, false
+	}
+
+	if len(req.Headers) > 20 {
+		http.Error(w, `{"error":"too many headers, maximum 20"}`, http.StatusBadRequest)
+		return req, false
+	}
+	for name, value := range req.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			http.Error(w, `{"error":"invalid header"}`, http.StatusBadRequest)
+			return req, false
+		}
+	}
+
+	return req, true
+}
+
+func (h *Handler) sendReportResponse(w http.ResponseWriter, report *models.ListOfProcessedLinks, filename string) {
+	format := reportFormat(*report)
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format.Extension))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(report.Report)))
+	w.Header().Set(models.HeaderLinksTotal, strconv.Itoa(report.Total))
+	w.Header().Set(models.HeaderLinksFailed, strconv.Itoa(report.Failed))
 	w.WriteHeader(http.StatusOK)
-	w.Write(pdfData)
+	w.Write(report.Report)
+}
+
+// reportFormat treats reports without a content type as PDF, the only
+// format there used to be.
+func reportFormat(report models.ListOfProcessedLinks) models.ReportFormat {
+	if report.ContentType == "" {
+		format, _ := models.LookupFormat(models.FormatPDF)
+		return format
+	}
+	return models.ReportFormat{ContentType: report.ContentType, Extension: report.Extension}
 }
 
-func (h *Handler) createZipResponse(w http.ResponseWriter, pdfs []models.ListOfProcessedLinks, links []models.ProcessedLinks, baseFilename string) {
+func (h *Handler) createZipResponse(w http.ResponseWriter, pdfs []models.ListOfProcessedLinks, links []models.ProcessedLinks, sortBy string, baseFilename string) {
 	buf := new(bytes.Buffer)
 	zipWriter := zip.NewWriter(buf)
 
 	for i, pdf := range pdfs {
-		if len(pdf.PDF) > 0 {
-			filename := fmt.Sprintf("report_%d.pdf", i+1)
+		if len(pdf.Report) > 0 {
+			filename := fmt.Sprintf("report_%d.%s", i+1, reportFormat(pdf).Extension)
 			writer, err := zipWriter.Create(filename)
 			if err != nil {
-				slog.Error("Failed to create PDF in ZIP", "error", err)
+				slog.Error("Failed to create report in ZIP", "error", err)
 				continue
 			}
-			if _, err := writer.Write(pdf.PDF); err != nil {
-				slog.Error("Failed to write PDF to ZIP", "error", err)
+			if _, err := writer.Write(pdf.Report); err != nil {
+				slog.Error("Failed to write report to ZIP", "error", err)
 			} else {
-				slog.Info("PDF added to ZIP", "filename", filename, "size", len(pdf.PDF))
+				slog.Info("Report added to ZIP", "filename", filename, "size", len(pdf.Report))
 			}
 		}
 	}
@@ -171,8 +399,16 @@ func (h *Handler) createZipResponse(w http.ResponseWriter, pdfs []models.ListOfP
 				content += fmt.Sprintf("Link Set #%d (ID: %d):\n", i+1, linkSet.ListNum)
 				content += "----------------------------------------\n"
 
-				for url, status := range linkSet.Answer {
-					content += fmt.Sprintf("  %s - %s\n", url, status)
+				for _, url := range linkSet.SortedLinks(sortBy) {
+					result := linkSet.Answer[url]
+					content += fmt.Sprintf("  %s - %s", url, result.Status)
+					if result.StatusCode > 0 {
+						content += fmt.Sprintf(" (HTTP %d, %d ms)", result.StatusCode, result.LatencyMs)
+					}
+					if result.Error != "" {
+						content += fmt.Sprintf(" [%s: %s]", result.ErrorKind, result.Error)
+					}
+					content += "\n"
 				}
 				content += "\n"
 			}
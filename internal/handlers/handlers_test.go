@@ -6,9 +6,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"status-links/internal/models"
+	"status-links/internal/services"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -23,14 +25,42 @@ func (m *MockLinkProcessor) UploadAllUnfinishedWork() *models.AllUnfinishedWork
 	return args.Get(0).(*models.AllUnfinishedWork)
 }
 
-func (m *MockLinkProcessor) AddLinkSet(req models.SetLinksGet) *models.ProcessedLinks {
+func (m *MockLinkProcessor) AddLinkSet(req models.SetLinksGet) (*models.ProcessedLinks, error) {
 	args := m.Called(req)
-	return args.Get(0).(*models.ProcessedLinks)
+	result, _ := args.Get(0).(*models.ProcessedLinks)
+	return result, args.Error(1)
+}
+
+func (m *MockLinkProcessor) SubmitLinkSet(req models.SetLinksGet) (*models.JobStatus, error) {
+	args := m.Called(req)
+	return args.Get(0).(*models.JobStatus), args.Error(1)
+}
+
+func (m *MockLinkProcessor) GetJobStatus(listNum int) (*models.JobStatus, error) {
+	args := m.Called(listNum)
+	status, _ := args.Get(0).(*models.JobStatus)
+	return status, args.Error(1)
 }
 
 func (m *MockLinkProcessor) GiveLinkAnswer(req models.SetNumsOfLinksGet) (*models.ListOfProcessedLinks, error) {
 	args := m.Called(req)
-	return args.Get(0).(*models.ListOfProcessedLinks), nil
+	result, _ := args.Get(0).(*models.ListOfProcessedLinks)
+	return result, args.Error(1)
+}
+
+func (m *MockLinkProcessor) StartCompaction() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockLinkProcessor) CompactionStatus() models.CompactionStats {
+	args := m.Called()
+	return args.Get(0).(models.CompactionStats)
+}
+
+func (m *MockLinkProcessor) CacheStats() (models.CacheStats, error) {
+	args := m.Called()
+	return args.Get(0).(models.CacheStats), args.Error(1)
 }
 
 func (m *MockLinkProcessor) WaitForCompletion() {
@@ -43,11 +73,11 @@ func TestSaveNewUrls_Success(t *testing.T) {
 	mockService.On("AddLinkSet", mock.AnythingOfType("models.SetLinksGet")).
 		Return(&models.ProcessedLinks{
 			Answer: models.LinksAnswer{
-				"https://example.com": "processed",
-				"https://google.com":  "pending",
+				"https://example.com": {Status: models.StatusAvailable, StatusCode: 200},
+				"https://google.com":  {Status: models.StatusUnavailable, ErrorKind: models.ErrorKindTimeout},
 			},
 			ListNum: 123,
-		})
+		}, nil)
 
 	handler, _ := NewHandler(mockService)
 
@@ -136,6 +166,278 @@ func TestSaveNewUrls_TooManyLinks(t *testing.T) {
 	mockService.AssertNotCalled(t, "AddLinkSet")
 }
 
+func TestSaveNewUrls_AllLinksRejected(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	mockService.On("AddLinkSet", mock.AnythingOfType("models.SetLinksGet")).
+		Return(&models.ProcessedLinks{
+			Answer: models.LinksAnswer{},
+			Rejected: []models.RejectedLink{
+				{URL: "ftp://x", Reason: "unsupported scheme"},
+			},
+		}, nil)
+
+	handler, _ := NewHandler(mockService)
+
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(models.SetLinksGet{Links: []string{"ftp://x"}})
+
+	req := httptest.NewRequest("POST", "/save-urls", &buf)
+	rr := httptest.NewRecorder()
+	handler.SaveNewUrls(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var response struct {
+		Error    string                `json:"error"`
+		Rejected []models.RejectedLink `json:"rejected"`
+	}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Equal(t, "no valid links", response.Error)
+	assert.Len(t, response.Rejected, 1)
+	assert.Equal(t, "ftp://x", response.Rejected[0].URL)
+}
+
+func TestSubmitUrls_ReturnsQueuedJob(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	mockService.On("SubmitLinkSet", mock.AnythingOfType("models.SetLinksGet")).
+		Return(&models.JobStatus{
+			ListNum: 7,
+			State:   models.JobQueued,
+			Total:   1,
+		}, nil)
+
+	handler, _ := NewHandler(mockService)
+
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(models.SetLinksGet{Links: []string{"https://example.com"}})
+
+	req := httptest.NewRequest("POST", "/api/submitUrls", &buf)
+	rr := httptest.NewRecorder()
+	handler.SubmitUrls(rr, req)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+
+	var response models.JobStatus
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Equal(t, 7, response.ListNum)
+	assert.Equal(t, models.JobQueued, response.State)
+}
+
+func TestSubmitUrls_NoValidLinks(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	mockService.On("SubmitLinkSet", mock.AnythingOfType("models.SetLinksGet")).
+		Return(&models.JobStatus{
+			State:    models.JobFailed,
+			Rejected: []models.RejectedLink{{URL: "ftp://x", Reason: "unsupported scheme"}},
+			Error:    services.ErrNoValidLinks.Error(),
+		}, services.ErrNoValidLinks)
+
+	handler, _ := NewHandler(mockService)
+
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(models.SetLinksGet{Links: []string{"ftp://x"}})
+
+	rr := httptest.NewRecorder()
+	handler.SubmitUrls(rr, httptest.NewRequest("POST", "/api/submitUrls", &buf))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var response models.JobStatus
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Len(t, response.Rejected, 1)
+}
+
+func TestSubmitUrls_InvalidHeader(t *testing.T) {
+	handler, _ := NewHandler(new(MockLinkProcessor))
+
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(models.SetLinksGet{
+		Links:   []string{"https://example.com"},
+		Headers: map[string]string{"Bad Header": "x"},
+	})
+
+	rr := httptest.NewRecorder()
+	handler.SubmitUrls(rr, httptest.NewRequest("POST", "/api/submitUrls", &buf))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestSubmitUrls_NotPersisted(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	mockService.On("SubmitLinkSet", mock.AnythingOfType("models.SetLinksGet")).
+		Return(&models.JobStatus{
+			ListNum: 8,
+			State:   models.JobFailed,
+			Error:   services.ErrJobNotDurable.Error(),
+		}, services.ErrJobNotDurable)
+
+	handler, _ := NewHandler(mockService)
+
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(models.SetLinksGet{Links: []string{"https://example.com"}})
+
+	rr := httptest.NewRecorder()
+	handler.SubmitUrls(rr, httptest.NewRequest("POST", "/api/submitUrls", &buf))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	var response models.JobStatus
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Equal(t, models.JobFailed, response.State)
+}
+
+func TestJobStatus(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	mockService.On("GetJobStatus", 3).
+		Return(&models.JobStatus{
+			ListNum: 3,
+			State:   models.JobDone,
+			Answer:  models.LinksAnswer{"https://example.com": {Status: models.StatusAvailable}},
+		}, nil)
+	mockService.On("GetJobStatus", 4).Return(nil, services.ErrJobNotFound)
+
+	handler, _ := NewHandler(mockService)
+
+	rr := httptest.NewRecorder()
+	handler.JobStatus(rr, httptest.NewRequest("GET", "/api/jobStatus?links_num=3", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var response models.JobStatus
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Equal(t, models.JobDone, response.State)
+	assert.Equal(t, models.StatusAvailable, response.Answer["https://example.com"].Status)
+
+	rr = httptest.NewRecorder()
+	handler.JobStatus(rr, httptest.NewRequest("GET", "/api/jobStatus?links_num=4", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.JobStatus(rr, httptest.NewRequest("GET", "/api/jobStatus?links_num=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestLoadUrls_Expired(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	req := models.SetNumsOfLinksGet{NumsLinks: []int{1}}
+	mockService.On("GiveLinkAnswer", req).Return(nil, services.ErrExpired)
+
+	handler, _ := NewHandler(mockService)
+
+	body, _ := json.Marshal(req)
+	rr := httptest.NewRecorder()
+	handler.LoadUrls(rr, httptest.NewRequest("GET", "/api/loadUrls", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusGone, rr.Code)
+	var response map[string]string
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Equal(t, "expired", response["error"])
+}
+
+func TestSaveNewUrls_KeepsSubmissionOrder(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	mockService.On("AddLinkSet", mock.AnythingOfType("models.SetLinksGet")).
+		Return(&models.ProcessedLinks{
+			Answer: models.LinksAnswer{
+				"https://b.com": {Status: models.StatusAvailable},
+				"https://c.com": {Status: models.StatusAvailable},
+				"https://a.com": {Status: models.StatusAvailable},
+			},
+			ListNum: 1,
+			Order:   []string{"https://c.com", "https://a.com", "https://b.com"},
+		}, nil)
+
+	handler, _ := NewHandler(mockService)
+	body, _ := json.Marshal(models.SetLinksGet{Links: []string{"c.com", "a.com", "b.com"}})
+	rr := httptest.NewRecorder()
+	handler.SaveNewUrls(rr, httptest.NewRequest("POST", "/api/saveNewUrls", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	c := strings.Index(rr.Body.String(), "https://c.com")
+	a := strings.Index(rr.Body.String(), "https://a.com")
+	b := strings.Index(rr.Body.String(), "https://b.com")
+	assert.True(t, c < a && a < b, "links out of submission order: %s", rr.Body.String())
+}
+
+func TestLoadUrls_Sort(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	req := models.SetNumsOfLinksGet{NumsLinks: []int{1}, Sort: models.SortLatency}
+	mockService.On("GiveLinkAnswer", req).Return(&models.ListOfProcessedLinks{Report: []byte("%PDF")}, nil)
+
+	handler, _ := NewHandler(mockService)
+
+	body, _ := json.Marshal(models.SetNumsOfLinksGet{NumsLinks: []int{1}})
+	rr := httptest.NewRecorder()
+	handler.LoadUrls(rr, httptest.NewRequest("GET", "/api/loadUrls?sort=latency", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertCalled(t, "GiveLinkAnswer", req)
+
+	rr = httptest.NewRecorder()
+	handler.LoadUrls(rr, httptest.NewRequest("GET", "/api/loadUrls?sort=size", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestLoadUrls_Format(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	csvReq := models.SetNumsOfLinksGet{NumsLinks: []int{1}, Format: models.FormatCSV}
+	mockService.On("GiveLinkAnswer", csvReq).Return(&models.ListOfProcessedLinks{
+		Report:      []byte("links_num\n"),
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		Total:       3,
+		Failed:      1,
+	}, nil)
+	pdfReq := models.SetNumsOfLinksGet{NumsLinks: []int{1}}
+	mockService.On("GiveLinkAnswer", pdfReq).Return(&models.ListOfProcessedLinks{
+		Report:      []byte("%PDF"),
+		ContentType: "application/pdf",
+		Extension:   "pdf",
+	}, nil)
+	mdReq := models.SetNumsOfLinksGet{NumsLinks: []int{1}, Format: models.FormatMarkdown}
+	mockService.On("GiveLinkAnswer", mdReq).Return(&models.ListOfProcessedLinks{
+		Report:      []byte("# Report\n"),
+		ContentType: "text/markdown; charset=utf-8",
+		Extension:   "md",
+	}, nil)
+
+	handler, _ := NewHandler(mockService)
+	body, _ := json.Marshal(models.SetNumsOfLinksGet{NumsLinks: []int{1}})
+
+	rr := httptest.NewRecorder()
+	handler.LoadUrls(rr, httptest.NewRequest("GET", "/api/loadUrls?format=csv", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), "links_report.csv")
+	assert.Equal(t, "3", rr.Header().Get(models.HeaderLinksTotal))
+	assert.Equal(t, "1", rr.Header().Get(models.HeaderLinksFailed))
+
+	req := httptest.NewRequest("GET", "/api/loadUrls", bytes.NewReader(body))
+	req.Header.Set("Accept", "text/markdown")
+	rr = httptest.NewRecorder()
+	handler.LoadUrls(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), "links_report.md")
+
+	rr = httptest.NewRecorder()
+	handler.LoadUrls(rr, httptest.NewRequest("GET", "/api/loadUrls?format=md", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertCalled(t, "GiveLinkAnswer", mdReq)
+
+	// Unsupported types and the JSON that HTTP clients accept by default
+	// keep the PDF report.
+	for _, accept := range []string{"image/png", "application/json, text/plain, */*", "application/json"} {
+		req = httptest.NewRequest("GET", "/api/loadUrls", bytes.NewReader(body))
+		req.Header.Set("Accept", accept)
+		rr = httptest.NewRecorder()
+		handler.LoadUrls(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, accept)
+		assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"), accept)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.LoadUrls(rr, httptest.NewRequest("GET", "/api/loadUrls?format=docx", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
 func TestLoadUnfinishedWork_OnlyLinks_ReturnsZip(t *testing.T) {
 	mockService := new(MockLinkProcessor)
 	handler, _ := NewHandler(mockService)
@@ -145,7 +447,7 @@ func TestLoadUnfinishedWork_OnlyLinks_ReturnsZip(t *testing.T) {
 			Links: []models.ProcessedLinks{
 				{
 					Answer: models.LinksAnswer{
-						"https://test.com": "completed",
+						"https://test.com": {Status: models.StatusAvailable},
 					},
 					ListNum: 1,
 				},
@@ -164,3 +466,72 @@ func TestLoadUnfinishedWork_OnlyLinks_ReturnsZip(t *testing.T) {
 
 	os.Remove("debug_unfinished_work.zip")
 }
+
+func TestCompact(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	handler, _ := NewHandler(mockService)
+
+	rr := httptest.NewRecorder()
+	handler.Compact(rr, httptest.NewRequest("POST", "/api/admin/compact", nil))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	handler.AdminToken = "secret"
+	rr = httptest.NewRecorder()
+	handler.Compact(rr, httptest.NewRequest("POST", "/api/admin/compact", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	authorized := func(method string) *http.Request {
+		req := httptest.NewRequest(method, "/api/admin/compact", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		return req
+	}
+
+	mockService.On("StartCompaction").Return(nil).Once()
+	mockService.On("CompactionStatus").Return(models.CompactionStats{Running: true})
+	rr = httptest.NewRecorder()
+	handler.Compact(rr, authorized("POST"))
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+
+	mockService.On("StartCompaction").Return(services.ErrCompactionRunning).Once()
+	rr = httptest.NewRecorder()
+	handler.Compact(rr, authorized("POST"))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	mockService.On("StartCompaction").Return(services.ErrCompactionUnsupported).Once()
+	rr = httptest.NewRecorder()
+	handler.Compact(rr, authorized("POST"))
+	assert.Equal(t, http.StatusNotImplemented, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.Compact(rr, authorized("GET"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var stats models.CompactionStats
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	assert.True(t, stats.Running)
+}
+
+func TestCache(t *testing.T) {
+	mockService := new(MockLinkProcessor)
+	mockService.On("CacheStats").Return(models.CacheStats{Hits: 3, Misses: 1, Entries: 2}, nil).Once()
+	mockService.On("CacheStats").Return(models.CacheStats{}, services.ErrCacheUnsupported)
+
+	handler, _ := NewHandler(mockService)
+	handler.AdminToken = "secret"
+
+	rr := httptest.NewRecorder()
+	handler.Cache(rr, httptest.NewRequest("GET", "/api/admin/cache", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	req := httptest.NewRequest("GET", "/api/admin/cache", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	handler.Cache(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var stats models.CacheStats
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&stats))
+	assert.Equal(t, uint64(3), stats.Hits)
+
+	rr = httptest.NewRecorder()
+	handler.Cache(rr, req)
+	assert.Equal(t, http.StatusNotImplemented, rr.Code)
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatPDF      = "pdf"
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+	FormatXLSX     = "xlsx"
+	FormatJUnit    = "junit"
+	FormatSARIF    = "sarif"
+)
+
+// Report responses carry the availability counts in these headers, so CI
+// jobs can pass or fail without parsing the report.
+const (
+	HeaderLinksTotal  = "X-Links-Total"
+	HeaderLinksFailed = "X-Links-Failed"
+)
+
+type ReportFormat struct {
+	Name        string
+	ContentType string
+	Extension   string
+}
+
+var reportFormats = []ReportFormat{
+	{FormatPDF, "application/pdf", "pdf"},
+	{FormatCSV, "text/csv; charset=utf-8", "csv"},
+	{FormatJSON, "application/json", "json"},
+	{FormatHTML, "text/html; charset=utf-8", "html"},
+	{FormatMarkdown, "text/markdown; charset=utf-8", "md"},
+	{FormatXLSX, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"},
+	{FormatJUnit, "application/xml; charset=utf-8", "xml"},
+	{FormatSARIF, "application/sarif+json", "sarif"},
+}
+
+// LookupFormat finds a report format by name or file extension. An empty
+// name is the default PDF report.
+func LookupFormat(name string) (ReportFormat, bool) {
+	if name == "" {
+		name = FormatPDF
+	}
+	name = strings.ToLower(name)
+	for _, format := range reportFormats {
+		if format.Name == name || format.Extension == name {
+			return format, true
+		}
+	}
+	return ReportFormat{}, false
+}
+
+// FormatFromAccept picks the supported format the Accept header prefers
+// most. Wildcards select the default format, returned as "".
+// application/json is skipped: HTTP clients send it by default, so the JSON
+// report is only chosen through the format parameter.
+func FormatFromAccept(header string) (string, bool) {
+	best, bestQ, found := "", 0.0, false
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		name, ok := formatForMediaType(mediaType)
+		if ok && q > bestQ {
+			best, bestQ, found = name, q, true
+		}
+	}
+	return best, found
+}
+
+func formatForMediaType(mediaType string) (string, bool) {
+	if mediaType == "*/*" || mediaType == "application/*" {
+		return "", true
+	}
+	for _, format := range reportFormats {
+		if format.Name == FormatJSON {
+			continue
+		}
+		if base, _, _ := strings.Cut(format.ContentType, ";"); base == mediaType {
+			return format.Name, true
+		}
+	}
+	return "", false
+}
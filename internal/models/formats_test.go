@@ -0,0 +1,48 @@
+package models
+
+import "testing"
+
+func TestReportFormats(t *testing.T) {
+	t.Run("LookupFormat accepts names and extensions", func(t *testing.T) {
+		tests := map[string]string{
+			"":         FormatPDF,
+			"CSV":      FormatCSV,
+			"markdown": FormatMarkdown,
+			"md":       FormatMarkdown,
+			"xlsx":     FormatXLSX,
+			"xml":      FormatJUnit,
+			"sarif":    FormatSARIF,
+		}
+		for name, want := range tests {
+			if format, ok := LookupFormat(name); !ok || format.Name != want {
+				t.Errorf("LookupFormat(%q) = %q, %v, want %q", name, format.Name, ok, want)
+			}
+		}
+		if _, ok := LookupFormat("docx"); ok {
+			t.Error("Expected docx to be unknown")
+		}
+	})
+
+	t.Run("FormatFromAccept", func(t *testing.T) {
+		tests := []struct {
+			header string
+			want   string
+			ok     bool
+		}{
+			{"text/csv", FormatCSV, true},
+			{"text/html,application/xhtml+xml,*/*;q=0.8", FormatHTML, true},
+			{"application/json;q=0.5, text/markdown", FormatMarkdown, true},
+			{"application/json, text/plain, */*", "", true},
+			{"application/json", "", false},
+			{"application/sarif+json", FormatSARIF, true},
+			{"*/*", "", true},
+			{"image/png", "", false},
+			{"text/csv;q=0", "", false},
+		}
+		for _, tt := range tests {
+			if got, ok := FormatFromAccept(tt.header); got != tt.want || ok != tt.ok {
+				t.Errorf("FormatFromAccept(%q) = %q, %v, want %q, %v", tt.header, got, ok, tt.want, tt.ok)
+			}
+		}
+	})
+}
@@ -1,26 +1,180 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type SetLinksGet struct {
 	Links []string `json:"links"`
+	// Headers are sent with every check of this set, on top of the
+	// configured ones.
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 type SetNumsOfLinksGet struct {
 	NumsLinks []int `json:"links_list"`
+	// Sort orders the rows of every set in the report, see ValidSort.
+	Sort string `json:"sort,omitempty"`
+	// Format names the report format, see LookupFormat.
+	Format string `json:"format,omitempty"`
+}
+
+const (
+	StatusAvailable   = "available"
+	StatusUnavailable = "unavailable"
+	StatusBlocked     = "blocked"
+	StatusDegraded    = "degraded"
+)
+
+const (
+	ErrorKindDNS        = "dns"
+	ErrorKindTLS        = "tls"
+	ErrorKindTimeout    = "timeout"
+	ErrorKindRefused    = "connection_refused"
+	ErrorKindHTTPStatus = "http_status"
+	ErrorKindBlocked    = "blocked"
+	ErrorKindRedirects  = "too_many_redirects"
+	ErrorKindOther      = "other"
+)
+
+const (
+	FlagCrossDomainRedirect = "cross_domain_redirect"
+	FlagLoginRedirect       = "login_redirect"
+	FlagParkedDomain        = "parked_domain"
+	FlagCertExpired         = "cert_expired"
+	FlagCertExpiresSoon     = "cert_expires_soon"
+	FlagCertSelfSigned      = "cert_self_signed"
+	FlagCertHostMismatch    = "cert_host_mismatch"
+)
+
+type RedirectHop struct {
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
+}
+
+type TLSInfo struct {
+	Version     string    `json:"version,omitempty"`
+	Issuer      string    `json:"issuer"`
+	Subject     string    `json:"subject"`
+	SANMatch    bool      `json:"san_match"`
+	NotAfter    time.Time `json:"not_after"`
+	DaysLeft    int       `json:"days_left"`
+	Expired     bool      `json:"expired,omitempty"`
+	SelfSigned  bool      `json:"self_signed,omitempty"`
+	ExpiresSoon bool      `json:"expires_soon,omitempty"`
+}
+
+type LinkResult struct {
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Method     string `json:"method,omitempty"`
+	LatencyMs  int64  `json:"latency_ms"`
+	Attempts   int    `json:"attempts,omitempty"`
+	FinalURL   string `json:"final_url,omitempty"`
+	ErrorKind  string `json:"error_kind,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	Redirects []RedirectHop `json:"redirects,omitempty"`
+	TLS       *TLSInfo      `json:"tls,omitempty"`
+	Flags     []string      `json:"flags,omitempty"`
+}
+
+// UnmarshalJSON also accepts the plain "available"/"unavailable" strings
+// written by earlier versions of the service.
+func (r *LinkResult) UnmarshalJSON(data []byte) error {
+	var status string
+	if err := json.Unmarshal(data, &status); err == nil {
+		*r = LinkResult{Status: status}
+		return nil
+	}
+
+	type plain LinkResult
+	return json.Unmarshal(data, (*plain)(r))
 }
 
-type LinksAnswer map[string]string
+type LinksAnswer map[string]LinkResult
 
 type ProcessedLinks struct {
-	Answer  LinksAnswer `json:"links"`
-	ListNum int         `json:"links_num"`
+	Answer    LinksAnswer    `json:"links"`
+	ListNum   int            `json:"links_num"`
+	Rejected  []RejectedLink `json:"rejected,omitempty"`
+	CreatedAt time.Time      `json:"created_at,omitzero"`
+	CheckedAt time.Time      `json:"checked_at,omitzero"`
+	Order     []string       `json:"order,omitempty"`
+	// Inputs maps every accepted URL, as submitted, to its key in Answer.
+	Inputs map[string]string `json:"inputs,omitempty"`
 }
 
 type ListOfProcessedLinks struct {
 	Description string `json:"description,omitempty"`
-	PDF         []byte `json:"-"`
+	Report      []byte `json:"-"`
+	ContentType string `json:"-"`
+	Extension   string `json:"-"`
+	// Total and Failed count the links in the report and those that are
+	// unavailable or blocked.
+	Total  int `json:"-"`
+	Failed int `json:"-"`
 }
 
 type AllUnfinishedWork struct {
 	Pdfs  []ListOfProcessedLinks `json:"pdfs,omitempty"`
 	Links []ProcessedLinks       `json:"links,omitempty"`
 }
+
+type JobState string
+
+const (
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	JobFailed  JobState = "failed"
+)
+
+type LinksJob struct {
+	ID        string
+	ListNum   int
+	Set       SetLinksGet
+	CreatedAt time.Time
+	Attempts  int
+	Owner     string
+}
+
+type JobStatus struct {
+	ListNum  int               `json:"links_num"`
+	State    JobState          `json:"state"`
+	Total    int               `json:"total"`
+	Checked  int               `json:"checked"`
+	Progress map[string]string `json:"progress,omitempty"`
+	Answer   LinksAnswer       `json:"links,omitempty"`
+	Inputs   map[string]string `json:"inputs,omitempty"`
+	Rejected []RejectedLink    `json:"rejected,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	// Order is the submission order "links" is written in.
+	Order []string `json:"-"`
+}
+
+type RejectedLink struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+type CacheStats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Evictions  uint64 `json:"evictions"`
+	Entries    int    `json:"entries"`
+	Bytes      int64  `json:"bytes"`
+	MaxEntries int    `json:"max_entries"`
+	MaxBytes   int64  `json:"max_bytes"`
+}
+
+type CompactionStats struct {
+	Running        bool      `json:"running"`
+	Runs           int       `json:"runs"`
+	LastFinished   time.Time `json:"last_finished"`
+	LastDurationMs int64     `json:"last_duration_ms"`
+	SnapshotBytes  int64     `json:"snapshot_bytes"`
+	SnapshotSets   int       `json:"snapshot_sets"`
+	LastError      string    `json:"last_error,omitempty"`
+}
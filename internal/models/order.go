@@ -0,0 +1,162 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+const (
+	SortSubmission = ""
+	SortURL        = "url"
+	SortStatus     = "status"
+	SortLatency    = "latency"
+)
+
+// ValidSort reports whether by names a known order, optionally prefixed with
+// "-" to reverse it.
+func ValidSort(by string) bool {
+	switch strings.TrimPrefix(by, "-") {
+	case SortSubmission, SortURL, SortStatus, SortLatency:
+		return true
+	}
+	return false
+}
+
+// statusRank puts problems first when sorting by status.
+var statusRank = map[string]int{
+	StatusUnavailable: 0,
+	StatusBlocked:     1,
+	StatusDegraded:    2,
+	StatusAvailable:   3,
+}
+
+// Links returns the checked URLs in the order they were submitted. Sets
+// stored before the order was recorded fall back to alphabetical order.
+func (p ProcessedLinks) Links() []string {
+	links := make([]string, 0, len(p.Answer))
+	seen := make(map[string]bool, len(p.Answer))
+	for _, link := range p.Order {
+		if _, ok := p.Answer[link]; ok && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	if len(links) == len(p.Answer) {
+		return links
+	}
+
+	var rest []string
+	for link := range p.Answer {
+		if !seen[link] {
+			rest = append(rest, link)
+		}
+	}
+	sort.Strings(rest)
+	return append(links, rest...)
+}
+
+// SortedLinks returns Links ordered by by. Ties keep submission order, so the
+// result is the same on every call.
+func (p ProcessedLinks) SortedLinks(by string) []string {
+	links := p.Links()
+	reverse := strings.HasPrefix(by, "-")
+	var less func(a, b string) bool
+	switch strings.TrimPrefix(by, "-") {
+	case SortURL:
+		less = func(a, b string) bool { return a < b }
+	case SortStatus:
+		less = func(a, b string) bool { return rank(p.Answer[a].Status) < rank(p.Answer[b].Status) }
+	case SortLatency:
+		less = func(a, b string) bool { return p.Answer[a].LatencyMs < p.Answer[b].LatencyMs }
+	default:
+		if reverse {
+			for i, j := 0, len(links)-1; i < j; i, j = i+1, j-1 {
+				links[i], links[j] = links[j], links[i]
+			}
+		}
+		return links
+	}
+
+	sort.SliceStable(links, func(i, j int) bool {
+		if reverse {
+			return less(links[j], links[i])
+		}
+		return less(links[i], links[j])
+	})
+	return links
+}
+
+func rank(status string) int {
+	if r, ok := statusRank[status]; ok {
+		return r
+	}
+	return len(statusRank)
+}
+
+// MarshalJSON writes "links" in submission order instead of the sorted key
+// order encoding/json gives maps.
+func (p ProcessedLinks) MarshalJSON() ([]byte, error) {
+	type plain ProcessedLinks
+	return marshalNoEscape(struct {
+		Answer OrderedAnswer `json:"links"`
+		plain
+	}{OrderedAnswer{Links: p.Links(), Answer: p.Answer}, plain(p)})
+}
+
+// MarshalJSON writes "links" in submission order, like ProcessedLinks.
+func (s JobStatus) MarshalJSON() ([]byte, error) {
+	type plain JobStatus
+	var answer *OrderedAnswer
+	if len(s.Answer) > 0 {
+		links := ProcessedLinks{Answer: s.Answer, Order: s.Order}.Links()
+		answer = &OrderedAnswer{Links: links, Answer: s.Answer}
+	}
+	return marshalNoEscape(struct {
+		Answer *OrderedAnswer `json:"links,omitempty"`
+		plain
+	}{answer, plain(s)})
+}
+
+// OrderedAnswer is a LinksAnswer that marshals as a JSON object with its keys
+// in the order of Links.
+type OrderedAnswer struct {
+	Links  []string
+	Answer LinksAnswer
+}
+
+func (o OrderedAnswer) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, link := range o.Links {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := marshalNoEscape(link)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalNoEscape(o.Answer[link])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// marshalNoEscape leaves &, < and > in URLs alone; the outer encoder decides
+// whether to escape them.
+func marshalNoEscape(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessedLinksOrder(t *testing.T) {
+	set := ProcessedLinks{
+		Answer: LinksAnswer{
+			"https://b.com/?x=1&y=2": {Status: StatusAvailable, LatencyMs: 30},
+			"https://c.com/":         {Status: StatusUnavailable, LatencyMs: 10},
+			"https://a.com/":         {Status: StatusDegraded, LatencyMs: 20},
+			"https://d.com/":         {Status: StatusAvailable, LatencyMs: 10},
+		},
+		Order: []string{"https://c.com/", "https://b.com/?x=1&y=2", "https://a.com/", "https://d.com/"},
+	}
+
+	t.Run("Links keeps submission order", func(t *testing.T) {
+		want := []string{"https://c.com/", "https://b.com/?x=1&y=2", "https://a.com/", "https://d.com/"}
+		if got := set.Links(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("legacy sets fall back to alphabetical order", func(t *testing.T) {
+		legacy := set
+		legacy.Order = []string{"https://d.com/"}
+		want := []string{"https://d.com/", "https://a.com/", "https://b.com/?x=1&y=2", "https://c.com/"}
+		if got := legacy.Links(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("SortedLinks", func(t *testing.T) {
+		tests := map[string][]string{
+			SortURL:           {"https://a.com/", "https://b.com/?x=1&y=2", "https://c.com/", "https://d.com/"},
+			SortStatus:        {"https://c.com/", "https://a.com/", "https://b.com/?x=1&y=2", "https://d.com/"},
+			SortLatency:       {"https://c.com/", "https://d.com/", "https://a.com/", "https://b.com/?x=1&y=2"},
+			"-" + SortLatency: {"https://b.com/?x=1&y=2", "https://a.com/", "https://c.com/", "https://d.com/"},
+		}
+		for by, want := range tests {
+			if got := set.SortedLinks(by); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %v, got %v", by, want, got)
+			}
+		}
+		if ValidSort("size") || !ValidSort("-status") || !ValidSort(SortSubmission) {
+			t.Error("Unexpected ValidSort result")
+		}
+	})
+
+	t.Run("JSON keeps submission order and round-trips", func(t *testing.T) {
+		raw, err := json.Marshal(set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"links":{"https://c.com/":{"status":"unavailable","latency_ms":10},` +
+			`"https://b.com/?x=1\u0026y=2":{"status":"available","latency_ms":30},` +
+			`"https://a.com/":{"status":"degraded","latency_ms":20},` +
+			`"https://d.com/":{"status":"available","latency_ms":10}}`
+		if got := string(raw); len(got) < len(want) || got[:len(want)] != want {
+			t.Errorf("Unexpected JSON: %s", got)
+		}
+
+		var decoded ProcessedLinks
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded.Links(), set.Links()) || len(decoded.Answer) != 4 {
+			t.Errorf("Unexpected round trip: %+v", decoded)
+		}
+	})
+
+	t.Run("job status JSON keeps submission order", func(t *testing.T) {
+		status := JobStatus{ListNum: 7, State: JobDone, Answer: set.Answer, Order: set.Order}
+		raw, err := json.Marshal(status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"links":{"https://c.com/":{"status":"unavailable","latency_ms":10},` +
+			`"https://b.com/?x=1\u0026y=2":{"status":"available","latency_ms":30},` +
+			`"https://a.com/":{"status":"degraded","latency_ms":20},` +
+			`"https://d.com/":{"status":"available","latency_ms":10}},"links_num":7`
+		if got := string(raw); len(got) < len(want) || got[:len(want)] != want {
+			t.Errorf("Unexpected JSON: %s", got)
+		}
+
+		var decoded JobStatus
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded.Answer) != 4 || decoded.ListNum != 7 {
+			t.Errorf("Unexpected round trip: %+v", decoded)
+		}
+
+		raw, _ = json.Marshal(JobStatus{ListNum: 7, State: JobRunning})
+		if strings.Contains(string(raw), `"links"`) {
+			t.Errorf("Expected no links while running, got %s", raw)
+		}
+	})
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"errors"
+	"status-links/internal/models"
+	"status-links/internal/storage"
+)
+
+var ErrCacheUnsupported = errors.New("temp storage is not a cache")
+
+func (l *LinksService) CacheStats() (models.CacheStats, error) {
+	reporter, ok := l.temp.(storage.CacheReporter)
+	if !ok {
+		return models.CacheStats{}, ErrCacheUnsupported
+	}
+	return reporter.CacheStats(), nil
+}
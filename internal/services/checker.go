@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"status-links/internal/models"
+	"syscall"
+	"time"
+)
+
+const (
+	CheckHeadThenGet = "head-get"
+	CheckGetOnly     = "get"
+	CheckHeadOnly    = "head"
+)
+
+const defaultMaxBodyBytes = 16 << 10
+
+// headRejectedCodes are answers servers commonly give to HEAD while serving
+// GET normally.
+var headRejectedCodes = map[int]bool{
+	http.StatusBadRequest:       true,
+	http.StatusForbidden:        true,
+	http.StatusNotFound:         true,
+	http.StatusMethodNotAllowed: true,
+	http.StatusNotImplemented:   true,
+}
+
+// checkLinkStatus checks link, sending headers in addition to the configured
+// ones.
+// link is expected in the form normalizeLinks returns.
+func (l *LinksService) checkLinkStatus(fullURL string, headers map[string]string) models.LinkResult {
+	var result models.LinkResult
+	for attempt := 1; ; attempt++ {
+		var retryAfter time.Duration
+		var err error
+		result, retryAfter, err = l.checkOnce(fullURL, headers)
+		result.Attempts = attempt
+
+		if attempt >= l.cfg.Retry.MaxAttempts || !retryableResult(result, err) {
+			return result
+		}
+
+		select {
+		case <-time.After(l.cfg.Retry.delay(attempt, retryAfter)):
+		case <-l.ctx.Done():
+			return result
+		}
+	}
+}
+
+func (l *LinksService) checkOnce(fullURL string, headers map[string]string) (models.LinkResult, time.Duration, error) {
+	method := http.MethodHead
+	if l.cfg.CheckMethod == CheckGetOnly {
+		method = http.MethodGet
+	}
+
+	start := time.Now()
+	resp, hops, err := l.sendCheck(method, fullURL, headers)
+	if err == nil && method == http.MethodHead && l.cfg.CheckMethod == CheckHeadThenGet && headRejectedCodes[resp.StatusCode] {
+		resp.Body.Close()
+		method = http.MethodGet
+		resp, hops, err = l.sendCheck(method, fullURL, headers)
+	}
+
+	result := models.LinkResult{
+		Method:    method,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Redirects: hops,
+		Flags:     l.cfg.Redirects.redirectFlags(fullURL, hops),
+	}
+	if err != nil {
+		result.Status = models.StatusUnavailable
+		result.ErrorKind, result.Error = classifyError(err)
+		switch result.ErrorKind {
+		case models.ErrorKindBlocked:
+			result.Status = models.StatusBlocked
+		case models.ErrorKindTLS:
+			// The server answers but its certificate was rejected: the link
+			// works for clients that accept it, so it is degraded rather than
+			// down. Other handshake failures leave it unavailable.
+			if certificateRejected(err) {
+				result.Status = models.StatusDegraded
+			}
+			if info, flags := inspectVerificationError(err, lastHost(fullURL, hops), time.Now(), l.cfg.CertExpiryWarnDays); info != nil {
+				result.TLS = info
+				result.Flags = append(result.Flags, flags...)
+			}
+		}
+		return result, 0, err
+	}
+	defer resp.Body.Close()
+
+	if method == http.MethodGet {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, l.cfg.MaxBodyBytes))
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.FinalURL = resp.Request.URL.String()
+
+	info, certFlags := inspectConnection(resp.TLS, resp.Request.URL.Hostname(), time.Now(), l.cfg.CertExpiryWarnDays)
+	result.TLS = info
+	result.Flags = append(result.Flags, certFlags...)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		result.Status = models.StatusAvailable
+		if len(certFlags) > 0 {
+			result.Status = models.StatusDegraded
+		}
+		return result, 0, nil
+	}
+	result.Status = models.StatusUnavailable
+	result.ErrorKind = models.ErrorKindHTTPStatus
+	result.Error = resp.Status
+
+	var retryAfter time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return result, retryAfter, nil
+}
+
+func (l *LinksService) sendCheck(method, fullURL string, headers map[string]string) (*http.Response, []models.RedirectHop, error) {
+	ctx, recorder := withRedirectRecorder(l.ctx)
+	req, err := http.NewRequestWithContext(ctx, method, fullURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, value := range l.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("User-Agent", l.cfg.UserAgent)
+
+	resp, err := l.client.Do(req)
+	return resp, recorder.chain(), err
+}
+
+// lastHost is the host the failed request was addressed to, which is the
+// last redirect target when there was one.
+func lastHost(fullURL string, hops []models.RedirectHop) string {
+	if len(hops) > 0 {
+		fullURL = hops[len(hops)-1].Location
+	}
+	u, err := url.Parse(fullURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func classifyError(err error) (kind string, reason string) {
+	reason = err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		reason = urlErr.Err.Error()
+	}
+
+	var blockedErr *blockedAddressError
+	var redirectsErr *tooManyRedirectsError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var recordErr tls.RecordHeaderError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &blockedErr):
+		return models.ErrorKindBlocked, blockedErr.Error()
+	case errors.As(err, &redirectsErr):
+		return models.ErrorKindRedirects, redirectsErr.Error()
+	case errors.As(err, &dnsErr):
+		return models.ErrorKindDNS, reason
+	case errors.As(err, &verifyErr), errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr),
+		errors.As(err, &recordErr):
+		return models.ErrorKindTLS, reason
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return models.ErrorKindTimeout, reason
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return models.ErrorKindRefused, reason
+	}
+	return models.ErrorKindOther, reason
+}
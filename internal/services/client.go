@@ -0,0 +1,39 @@
+package services
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const defaultUserAgent = "status-links/1.0"
+
+// checkTimeout bounds each request of a check, counted from the moment the
+// host limiter lets it through.
+const checkTimeout = 10 * time.Second
+
+func newCheckClient(cfg Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.SSRF.Enabled {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   newIPGuard(cfg.SSRF).control,
+		}
+		transport.DialContext = dialer.DialContext
+		// A proxy would make the guard see only the proxy's address.
+		transport.Proxy = nil
+	}
+	if cfg.HostLimits.MaxConcurrent > 0 {
+		transport.MaxConnsPerHost = cfg.HostLimits.MaxConcurrent
+	}
+
+	return &http.Client{
+		CheckRedirect: cfg.Redirects.checkRedirect,
+		Transport: &limitedTransport{
+			base:    transport,
+			limiter: newHostLimiter(cfg.HostLimits),
+			timeout: checkTimeout,
+		},
+	}
+}
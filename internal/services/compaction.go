@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"status-links/internal/models"
+	"status-links/internal/storage"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCompactionRunning     = errors.New("compaction already running")
+	ErrCompactionUnsupported = errors.New("storage does not support compaction")
+)
+
+type compaction struct {
+	mu    sync.Mutex
+	stats models.CompactionStats
+}
+
+func (l *LinksService) StartCompaction() error {
+	compactor, ok := l.reliable.(storage.Compactor)
+	if !ok {
+		return ErrCompactionUnsupported
+	}
+
+	l.compaction.mu.Lock()
+	if l.compaction.stats.Running {
+		l.compaction.mu.Unlock()
+		return ErrCompactionRunning
+	}
+	l.compaction.stats.Running = true
+	l.compaction.mu.Unlock()
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		l.runCompaction(compactor)
+	}()
+	return nil
+}
+
+func (l *LinksService) runCompaction(compactor storage.Compactor) {
+	start := time.Now()
+	info, err := compactor.Compact()
+	duration := time.Since(start)
+
+	l.compaction.mu.Lock()
+	defer l.compaction.mu.Unlock()
+	stats := &l.compaction.stats
+	stats.Running = false
+	stats.Runs++
+	stats.LastFinished = time.Now()
+	stats.LastDurationMs = duration.Milliseconds()
+	if err != nil {
+		slog.Error("error in Compact", "error", err)
+		stats.LastError = err.Error()
+		return
+	}
+	stats.LastError = ""
+	stats.SnapshotBytes = info.Bytes
+	stats.SnapshotSets = info.Sets
+	slog.Info("Storage compacted", "sets", info.Sets, "bytes", info.Bytes, "duration", duration)
+}
+
+func (l *LinksService) CompactionStatus() models.CompactionStats {
+	l.compaction.mu.Lock()
+	defer l.compaction.mu.Unlock()
+	return l.compaction.stats
+}
+
+func (l *LinksService) compactPeriodically(interval time.Duration) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.StartCompaction(); err != nil && err != ErrCompactionRunning {
+				slog.Error("error in StartCompaction", "error", err)
+				return
+			}
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"status-links/internal/storage"
+	"testing"
+	"time"
+)
+
+type compactingStorage struct {
+	*mockReliableStorage
+	release chan struct{}
+	err     error
+}
+
+func (c *compactingStorage) Compact() (storage.SnapshotInfo, error) {
+	<-c.release
+	return storage.SnapshotInfo{Bytes: 123, Sets: 4}, c.err
+}
+
+func waitForCompaction(t *testing.T, service *LinksService, runs int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if stats := service.CompactionStatus(); !stats.Running && stats.Runs >= runs {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Compaction did not finish")
+}
+
+func TestCompaction(t *testing.T) {
+	t.Run("unsupported storage", func(t *testing.T) {
+		service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{})
+		defer service.WaitForCompletion()
+
+		if err := service.StartCompaction(); err != ErrCompactionUnsupported {
+			t.Errorf("Expected ErrCompactionUnsupported, got %v", err)
+		}
+	})
+
+	t.Run("runs in background and records stats", func(t *testing.T) {
+		reliable := &compactingStorage{mockReliableStorage: newMockReliableStorage(), release: make(chan struct{})}
+		service := NewLinksService(newMockTempStorage(), reliable, Config{})
+		defer service.WaitForCompletion()
+
+		if err := service.StartCompaction(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !service.CompactionStatus().Running {
+			t.Error("Expected compaction to be running")
+		}
+		if err := service.StartCompaction(); err != ErrCompactionRunning {
+			t.Errorf("Expected ErrCompactionRunning, got %v", err)
+		}
+
+		close(reliable.release)
+		waitForCompaction(t, service, 1)
+
+		stats := service.CompactionStatus()
+		if stats.SnapshotBytes != 123 || stats.SnapshotSets != 4 || stats.LastError != "" {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+		if stats.LastFinished.IsZero() {
+			t.Error("Expected finish time to be recorded")
+		}
+	})
+
+	t.Run("failure is reported", func(t *testing.T) {
+		reliable := &compactingStorage{
+			mockReliableStorage: newMockReliableStorage(),
+			release:             make(chan struct{}),
+			err:                 errors.New("disk full"),
+		}
+		close(reliable.release)
+		service := NewLinksService(newMockTempStorage(), reliable, Config{})
+		defer service.WaitForCompletion()
+
+		service.StartCompaction()
+		waitForCompaction(t, service, 1)
+		if stats := service.CompactionStatus(); stats.LastError != "disk full" {
+			t.Errorf("Expected error to be recorded, got %+v", stats)
+		}
+	})
+
+	t.Run("runs periodically", func(t *testing.T) {
+		reliable := &compactingStorage{mockReliableStorage: newMockReliableStorage(), release: make(chan struct{})}
+		close(reliable.release)
+		service := NewLinksService(newMockTempStorage(), reliable, Config{CompactInterval: 10 * time.Millisecond})
+		defer service.WaitForCompletion()
+
+		waitForCompaction(t, service, 2)
+	})
+}
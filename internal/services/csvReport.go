@@ -0,0 +1,78 @@
+package services
+
+import (
+	"encoding/csv"
+	"io"
+	"status-links/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resultColumns head the CSV and XLSX exports. They are meant for scripts and
+// spreadsheets, so they stay in English and statuses are not translated.
+var resultColumns = []string{
+	"links_num", "checked_at", "url", "status", "status_code", "latency_ms",
+	"error_kind", "error", "final_url", "redirects", "tls_expires", "flags",
+}
+
+// resultRows flattens every set into one row per URL, matching resultColumns.
+func (r report) resultRows() [][]string {
+	var rows [][]string
+	for _, set := range r.sets {
+		var at string
+		if t := checkedAt(set); !t.IsZero() {
+			at = t.UTC().Format(time.RFC3339)
+		}
+		for _, link := range r.links(set) {
+			result := set.Answer[link]
+			rows = append(rows, []string{
+				strconv.Itoa(set.ListNum),
+				at,
+				link,
+				result.Status,
+				optionalInt(result.StatusCode),
+				strconv.FormatInt(result.LatencyMs, 10),
+				result.ErrorKind,
+				result.Error,
+				result.FinalURL,
+				redirectChain(result.Redirects),
+				tlsExpires(result.TLS),
+				strings.Join(result.Flags, ";"),
+			})
+		}
+	}
+	return rows
+}
+
+func optionalInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func redirectChain(hops []models.RedirectHop) string {
+	chain := make([]string, len(hops))
+	for i, hop := range hops {
+		chain[i] = strconv.Itoa(hop.StatusCode) + " " + hop.Location
+	}
+	return strings.Join(chain, "; ")
+}
+
+func tlsExpires(info *models.TLSInfo) string {
+	if info == nil {
+		return ""
+	}
+	return info.NotAfter.UTC().Format(dateLayout)
+}
+
+type csvRenderer struct{}
+
+func (csvRenderer) render(w io.Writer, r report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(resultColumns); err != nil {
+		return err
+	}
+	return writer.WriteAll(r.resultRows())
+}
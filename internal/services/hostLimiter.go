@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type HostLimits struct {
+	RequestsPerSecond float64
+	Burst             int
+	MaxConcurrent     int
+}
+
+// hostLimiter keeps a token bucket and a connection limit per host. It is
+// shared by every check the service makes, so a batch of URLs on one domain
+// cannot flood it no matter how many requests submitted them.
+type hostLimiter struct {
+	limits    HostLimits
+	mu        sync.Mutex
+	hosts     map[string]*hostState
+	lastSweep time.Time
+}
+
+// hostSweepInterval is how often hosts nobody is using are dropped.
+const hostSweepInterval = time.Minute
+
+type hostState struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	slots  chan struct{}
+	// users counts the callers holding or waiting for this state, under
+	// hostLimiter.mu.
+	users int
+}
+
+func newHostLimiter(limits HostLimits) *hostLimiter {
+	if limits.Burst <= 0 {
+		limits.Burst = 1
+	}
+	return &hostLimiter{
+		limits:    limits,
+		hosts:     make(map[string]*hostState),
+		lastSweep: time.Now(),
+	}
+}
+
+// checkout returns the state of host and marks it in use, so it is not
+// dropped while the caller waits on it.
+func (h *hostLimiter) checkout(host string) *hostState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now := time.Now(); now.Sub(h.lastSweep) >= hostSweepInterval {
+		h.sweep(now)
+		h.lastSweep = now
+	}
+	st, ok := h.hosts[host]
+	if !ok {
+		st = &hostState{
+			tokens: float64(h.limits.Burst),
+			last:   time.Now(),
+		}
+		if h.limits.MaxConcurrent > 0 {
+			st.slots = make(chan struct{}, h.limits.MaxConcurrent)
+		}
+		h.hosts[host] = st
+	}
+	st.users++
+	return st
+}
+
+func (h *hostLimiter) checkin(st *hostState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st.users--
+}
+
+// sweep drops hosts that nobody uses and whose bucket has refilled, which a
+// fresh state would reproduce exactly.
+func (h *hostLimiter) sweep(now time.Time) {
+	for host, st := range h.hosts {
+		if st.users > 0 {
+			continue
+		}
+		st.mu.Lock()
+		full := h.limits.RequestsPerSecond <= 0 ||
+			st.tokens+now.Sub(st.last).Seconds()*h.limits.RequestsPerSecond >= float64(h.limits.Burst)
+		st.mu.Unlock()
+		if full {
+			delete(h.hosts, host)
+		}
+	}
+}
+
+// acquire blocks until the host has a free connection slot and a token.
+// The returned release func frees the slot.
+func (h *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	st := h.checkout(strings.ToLower(host))
+
+	release := func() { h.checkin(st) }
+	if st.slots != nil {
+		select {
+		case st.slots <- struct{}{}:
+			release = func() {
+				<-st.slots
+				h.checkin(st)
+			}
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	if wait := h.reserve(st); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+// reserve takes a token, going into debt if necessary, and returns how long
+// the caller has to wait for that token to become valid.
+func (h *hostLimiter) reserve(st *hostState) time.Duration {
+	rate := h.limits.RequestsPerSecond
+	if rate <= 0 {
+		return 0
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	st.tokens = min(st.tokens+now.Sub(st.last).Seconds()*rate, float64(h.limits.Burst))
+	st.last = now
+	st.tokens--
+	if st.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-st.tokens / rate * float64(time.Second))
+}
+
+// limitedTransport waits for the host limiter and only then starts the
+// request's deadline, so time spent queued behind other checks of the same
+// host is not mistaken for a timeout.
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *hostLimiter
+	timeout time.Duration
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limiter.acquire(req.Context(), req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	done := release
+	if t.timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+		req = req.WithContext(ctx)
+		done = func() {
+			cancel()
+			release()
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: done}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostLimiter(t *testing.T) {
+	t.Run("token bucket spaces out requests to one host", func(t *testing.T) {
+		limiter := newHostLimiter(HostLimits{RequestsPerSecond: 50, Burst: 1})
+
+		start := time.Now()
+		for i := 0; i < 6; i++ {
+			release, err := limiter.acquire(context.Background(), "example.com")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			release()
+		}
+
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Errorf("Expected rate limiting to take at least 100ms, took %v", elapsed)
+		}
+	})
+
+	t.Run("hosts have independent buckets", func(t *testing.T) {
+		limiter := newHostLimiter(HostLimits{RequestsPerSecond: 1, Burst: 1})
+
+		start := time.Now()
+		for _, host := range []string{"a.com", "b.com", "c.com"} {
+			release, err := limiter.acquire(context.Background(), host)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			release()
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected distinct hosts not to wait, took %v", elapsed)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := limiter.acquire(ctx, "A.COM"); err == nil {
+			t.Error("Expected second request to a.com to wait past the deadline")
+		}
+	})
+
+	t.Run("concurrent connections per host are bounded", func(t *testing.T) {
+		limiter := newHostLimiter(HostLimits{MaxConcurrent: 2})
+
+		var current, peak int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release, err := limiter.acquire(context.Background(), "example.com")
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				n := atomic.AddInt32(&current, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(2 * time.Millisecond)
+				atomic.AddInt32(&current, -1)
+				release()
+			}()
+		}
+		wg.Wait()
+
+		if peak > 2 {
+			t.Errorf("Expected at most 2 concurrent requests, got %d", peak)
+		}
+	})
+	t.Run("idle hosts are dropped", func(t *testing.T) {
+		limiter := newHostLimiter(HostLimits{RequestsPerSecond: 1000, Burst: 1, MaxConcurrent: 1})
+
+		release, err := limiter.acquire(context.Background(), "busy.com")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		idle, err := limiter.acquire(context.Background(), "idle.com")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		idle()
+
+		time.Sleep(5 * time.Millisecond)
+		limiter.mu.Lock()
+		limiter.sweep(time.Now())
+		_, busy := limiter.hosts["busy.com"]
+		_, kept := limiter.hosts["idle.com"]
+		limiter.mu.Unlock()
+		release()
+
+		if !busy {
+			t.Error("Expected host in use to be kept")
+		}
+		if kept {
+			t.Error("Expected idle host to be dropped")
+		}
+	})
+
+	t.Run("waiting for the limiter does not count towards the timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		client := &http.Client{Transport: &limitedTransport{
+			base:    http.DefaultTransport,
+			limiter: newHostLimiter(HostLimits{RequestsPerSecond: 10, Burst: 1}),
+			timeout: 50 * time.Millisecond,
+		}}
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("Request %d failed while queued: %v", i, err)
+			}
+			resp.Body.Close()
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// htmlStyle is inlined so the page needs nothing but itself to display.
+const htmlStyle = `body { font-family: "DejaVu Sans", Arial, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #bbb; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #e6e6e6; }
+td.num { text-align: right; }
+td.url { word-break: break-all; max-width: 40em; }
+.hop, .flag { color: #666; font-size: 0.9em; }
+.status-available { background: #e3f4e3; }
+.status-degraded { background: #fdf3d6; }
+.status-unavailable { background: #f9dede; }
+.status-blocked { background: #ececec; }`
+
+type htmlRenderer struct{}
+
+func (htmlRenderer) render(w io.Writer, r report) error {
+	text := r.text
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html lang=\"%s\">\n<head>\n<meta charset=\"utf-8\">\n", text.lang)
+	fmt.Fprintf(&b, "<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", html.EscapeString(text.title), htmlStyle)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(text.title))
+	fmt.Fprintf(&b, "<p>%s: %s<br>\n%s: %s</p>\n",
+		html.EscapeString(text.generated), r.generatedAt.Format(reportTimeLayout),
+		html.EscapeString(text.sets), r.setNums())
+
+	summary := r.summary()
+	fmt.Fprintf(&b, "<h2>%s</h2>\n<table>\n", html.EscapeString(text.summary))
+	htmlRow(&b, "th", nil, text.metric, text.value)
+	htmlRow(&b, "td", []string{"", "num"}, text.totalLinks, strconv.Itoa(summary.total))
+	for _, status := range reportStatuses {
+		htmlRow(&b, "td", []string{"", "num"}, text.status(status), strconv.Itoa(summary.byStatus[status]))
+	}
+	htmlRow(&b, "td", []string{"", "num"}, text.availability, formatPercent(summary.availability()))
+	b.WriteString("</table>\n")
+
+	if len(summary.domains) > 0 {
+		fmt.Fprintf(&b, "<h2>%s</h2>\n<table>\n", html.EscapeString(text.byDomain))
+		htmlRow(&b, "th", nil, text.domain, text.links, text.up, text.down, text.availability)
+		for _, d := range summary.domains {
+			htmlRow(&b, "td", []string{"", "num", "num", "num", "num"},
+				d.domain, strconv.Itoa(d.total), strconv.Itoa(d.up), strconv.Itoa(d.total-d.up), formatPercent(d.availability()))
+		}
+		b.WriteString("</table>\n")
+	}
+
+	for _, set := range r.sets {
+		fmt.Fprintf(&b, "<section id=\"set-%d\">\n<h2>%s</h2>\n", set.ListNum, html.EscapeString(r.setHeading(set)))
+		if len(set.Answer) == 0 {
+			fmt.Fprintf(&b, "<p>%s</p>\n</section>\n", html.EscapeString(text.noLinks))
+			continue
+		}
+		b.WriteString("<table>\n")
+		htmlRow(&b, "th", nil, text.url, text.statusColumn, "HTTP", text.latency, text.errorColumn)
+		for _, link := range r.links(set) {
+			result := set.Answer[link]
+			fmt.Fprintf(&b, "<tr class=\"status-%s\">", html.EscapeString(result.Status))
+			b.WriteString(`<td class="url">` + html.EscapeString(link))
+			for _, hop := range result.Redirects {
+				fmt.Fprintf(&b, `<div class="hop">→ %d %s</div>`, hop.StatusCode, html.EscapeString(hop.Location))
+			}
+			b.WriteString("</td><td>" + html.EscapeString(text.status(result.Status)))
+			for _, flag := range result.Flags {
+				b.WriteString(`<div class="flag">` + html.EscapeString(flag) + "</div>")
+			}
+			fmt.Fprintf(&b, "</td><td class=\"num\">%s</td><td class=\"num\">%s</td><td>%s</td></tr>\n",
+				statusCode(result), html.EscapeString(text.latencyOf(result)), html.EscapeString(errorText(result)))
+		}
+		b.WriteString("</table>\n</section>\n")
+	}
+
+	if rows := r.certificates(); len(rows) > 0 {
+		fmt.Fprintf(&b, "<h2>%s</h2>\n<table>\n", html.EscapeString(text.certificates))
+		htmlRow(&b, "th", nil, text.url, text.expires, text.state, text.issuer)
+		for _, row := range rows {
+			htmlRow(&b, "td", []string{"url"},
+				row.url, row.info.NotAfter.Format(dateLayout), text.certificateState(row.info), certificateIssuer(row.info))
+		}
+		b.WriteString("</table>\n")
+	}
+
+	b.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// htmlRow writes one table row of escaped cells; classes apply to the cells
+// in order.
+func htmlRow(b *strings.Builder, tag string, classes []string, cells ...string) {
+	b.WriteString("<tr>")
+	for i, cell := range cells {
+		if i < len(classes) && classes[i] != "" {
+			fmt.Fprintf(b, "<%s class=\"%s\">", tag, classes[i])
+		} else {
+			fmt.Fprintf(b, "<%s>", tag)
+		}
+		fmt.Fprintf(b, "%s</%s>", html.EscapeString(cell), tag)
+	}
+	b.WriteString("</tr>\n")
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"status-links/internal/models"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobNotReady  = errors.New("job not finished")
+	ErrNoValidLinks = errors.New("no valid links")
+	// ErrJobNotDurable means the job could not be recorded as pending, so it
+	// would not survive a restart and was not started.
+	ErrJobNotDurable = errors.New("job could not be persisted")
+)
+
+const (
+	defaultRunningJobs = 4
+	defaultJobLease    = 5 * time.Minute
+	pendingStatus      = "pending"
+)
+
+type job struct {
+	listNum   int
+	taskID    string
+	links     []string
+	rejected  []models.RejectedLink
+	inputs    map[string]string
+	headers   map[string]string
+	createdAt time.Time
+
+	mu      sync.Mutex
+	state   models.JobState
+	results map[string]models.LinkResult
+	checked int
+	err     string
+	// final is the stored answer, set together with JobDone.
+	final models.LinksAnswer
+}
+
+func newJob(listNum int, taskID string, links []string, rejected []models.RejectedLink, inputs map[string]string, headers map[string]string, createdAt time.Time) *job {
+	return &job{
+		listNum:   listNum,
+		taskID:    taskID,
+		links:     links,
+		rejected:  rejected,
+		inputs:    inputs,
+		headers:   headers,
+		createdAt: createdAt,
+		state:     models.JobQueued,
+		results:   make(map[string]models.LinkResult, len(links)),
+	}
+}
+
+func (j *job) setState(state models.JobState, errText string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.state = state
+	j.err = errText
+}
+
+// finish publishes the stored answer and marks the job done in one step, so
+// a done status always carries its answer.
+func (j *job) finish(answer models.LinksAnswer) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.final = answer
+	j.state = models.JobDone
+	j.err = ""
+}
+
+func (j *job) record(url string, result models.LinkResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.results[url] = result
+	j.checked++
+}
+
+func (j *job) unfinished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state == models.JobQueued || j.state == models.JobRunning
+}
+
+func (j *job) answer() models.LinksAnswer {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	answer := make(models.LinksAnswer, len(j.results))
+	for url, result := range j.results {
+		answer[url] = result
+	}
+	return answer
+}
+
+func (j *job) status() *models.JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	progress := make(map[string]string, len(j.links))
+	for _, url := range j.links {
+		if result, ok := j.results[url]; ok {
+			progress[url] = result.Status
+		} else {
+			progress[url] = pendingStatus
+		}
+	}
+
+	return &models.JobStatus{
+		ListNum:  j.listNum,
+		State:    j.state,
+		Total:    len(j.links),
+		Checked:  j.checked,
+		Progress: progress,
+		Answer:   j.final,
+		Inputs:   j.inputs,
+		Rejected: j.rejected,
+		Error:    j.err,
+		Order:    j.links,
+	}
+}
+
+func (l *LinksService) SubmitLinkSet(set models.SetLinksGet) (*models.JobStatus, error) {
+	links, rejected, inputs := normalizeLinks(set.Links)
+	if len(links) == 0 {
+		return &models.JobStatus{
+			State:    models.JobFailed,
+			Rejected: rejected,
+			Error:    ErrNoValidLinks.Error(),
+		}, ErrNoValidLinks
+	}
+
+	createdAt := time.Now().UTC()
+	listNum, err := l.storeNewSet(&models.ProcessedLinks{
+		Answer:    make(models.LinksAnswer),
+		CreatedAt: createdAt,
+		Order:     links,
+		Inputs:    inputs,
+	})
+	if err != nil {
+		slog.Error("error in AllocateListNum", "error", err)
+		return &models.JobStatus{
+			State:    models.JobFailed,
+			Rejected: rejected,
+			Error:    ErrJobNotDurable.Error(),
+		}, ErrJobNotDurable
+	}
+
+	taskID, err := l.reliable.AddLinksJob(listNum, &set, l.cfg.InstanceID)
+	if err != nil {
+		slog.Error("error in AddLinksJob", "error", err)
+		j := newJob(listNum, "", links, rejected, inputs, set.Headers, createdAt)
+		j.setState(models.JobFailed, ErrJobNotDurable.Error())
+		l.jobsMu.Lock()
+		l.jobs[listNum] = j
+		l.jobsMu.Unlock()
+		return j.status(), ErrJobNotDurable
+	}
+
+	j := newJob(listNum, taskID, links, rejected, inputs, set.Headers, createdAt)
+	l.startJob(j)
+	return j.status(), nil
+}
+
+func (l *LinksService) GetJobStatus(listNum int) (*models.JobStatus, error) {
+	l.jobsMu.Lock()
+	j, ok := l.jobs[listNum]
+	l.jobsMu.Unlock()
+	if ok {
+		return j.status(), nil
+	}
+
+	l.loadSharedSets([]int{listNum})
+	sets, err := l.temp.FindSets(&models.SetNumsOfLinksGet{NumsLinks: []int{listNum}})
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+
+	set := (*sets)[0]
+	answer := set.Answer
+	progress := make(map[string]string, len(answer))
+	for url, result := range answer {
+		progress[url] = result.Status
+	}
+	return &models.JobStatus{
+		ListNum:  listNum,
+		State:    models.JobDone,
+		Total:    len(answer),
+		Checked:  len(answer),
+		Progress: progress,
+		Answer:   answer,
+		Inputs:   set.Inputs,
+		Rejected: set.Rejected,
+		Order:    set.Order,
+	}, nil
+}
+
+func (l *LinksService) jobUnfinished(listNum int) bool {
+	l.jobsMu.Lock()
+	j, ok := l.jobs[listNum]
+	l.jobsMu.Unlock()
+	return ok && j.unfinished()
+}
+
+// resumeJobs claims and restarts pending jobs. Without an InstanceID there
+// is no one to share the storage with, so every job is taken; otherwise jobs
+// of other owners are taken only once their lease has run out.
+func (l *LinksService) resumeJobs() {
+	staleBefore := time.Now()
+	if l.cfg.InstanceID != "" {
+		staleBefore = staleBefore.Add(-l.cfg.JobLease)
+	}
+	pending, err := l.reliable.ClaimPendingJobs(l.cfg.InstanceID, staleBefore)
+	if err != nil {
+		slog.Error("error in ClaimPendingJobs", "error", err)
+		return
+	}
+	l.startClaimedJobs(pending)
+
+	if len(pending) > 0 {
+		slog.Info("Resumed pending jobs", "count", len(pending))
+	}
+}
+
+// takeOverJobs restarts the jobs other instances abandoned while this one
+// is running.
+func (l *LinksService) takeOverJobs() {
+	pending, err := l.reliable.TakeOverJobs(l.cfg.InstanceID, time.Now().Add(-l.cfg.JobLease))
+	if err != nil {
+		slog.Error("error in TakeOverJobs", "error", err)
+		return
+	}
+	l.startClaimedJobs(pending)
+
+	if len(pending) > 0 {
+		slog.Info("Took over abandoned jobs", "count", len(pending))
+	}
+}
+
+func (l *LinksService) startClaimedJobs(pending []models.LinksJob) {
+	for _, p := range pending {
+		l.loadSharedSets([]int{p.ListNum})
+		if _, err := l.temp.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{p.ListNum}}); err == nil {
+			// The result was stored before the pending entry could be cleared.
+			if err := l.reliable.RemoveLinksTask(p.ID); err != nil {
+				slog.Error("error in RemoveLinksTask", "error", err)
+			}
+			continue
+		}
+
+		l.temp.UpdateData(&models.ProcessedLinks{
+			Answer:    make(models.LinksAnswer),
+			ListNum:   p.ListNum,
+			CreatedAt: p.CreatedAt,
+		})
+		if p.Attempts > 1 {
+			slog.Warn("Resuming job again", "links_num", p.ListNum, "task_id", p.ID, "attempts", p.Attempts)
+		}
+		links, rejected, inputs := normalizeLinks(p.Set.Links)
+		l.startJob(newJob(p.ListNum, p.ID, links, rejected, inputs, p.Set.Headers, p.CreatedAt))
+	}
+}
+
+// renewClaimsPeriodically keeps this instance's claims fresh and picks up
+// the jobs of instances that stopped renewing theirs.
+func (l *LinksService) renewClaimsPeriodically(interval time.Duration) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.reliable.RenewJobClaims(l.cfg.InstanceID); err != nil {
+				slog.Error("error in RenewJobClaims", "error", err)
+			}
+			l.takeOverJobs()
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
+
+func (l *LinksService) startJob(j *job) {
+	l.jobsMu.Lock()
+	l.jobs[j.listNum] = j
+	l.jobsMu.Unlock()
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		select {
+		case l.jobSlots <- struct{}{}:
+		case <-l.ctx.Done():
+			return
+		}
+		defer func() { <-l.jobSlots }()
+		l.runJob(j)
+	}()
+}
+
+func (l *LinksService) runJob(j *job) {
+	j.setState(models.JobRunning, "")
+
+	l.pool.run(len(j.links), func(i int) {
+		if l.ctx.Err() != nil {
+			return
+		}
+		j.record(j.links[i], l.checkLinkStatus(j.links[i], j.headers))
+	})
+
+	if l.ctx.Err() != nil {
+		slog.Info("Job interrupted, left pending", "links_num", j.listNum)
+		return
+	}
+
+	processed := &models.ProcessedLinks{
+		Answer:    j.answer(),
+		ListNum:   j.listNum,
+		Rejected:  j.rejected,
+		CreatedAt: j.createdAt,
+		CheckedAt: time.Now().UTC(),
+		Order:     j.links,
+		Inputs:    j.inputs,
+	}
+	l.temp.UpdateData(processed)
+
+	if err := l.completeTask(j.taskID, processed); err != nil {
+		j.setState(models.JobFailed, err.Error())
+		return
+	}
+
+	j.finish(processed.Answer)
+	l.jobsMu.Lock()
+	delete(l.jobs, j.listNum)
+	l.jobsMu.Unlock()
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"status-links/internal/models"
+	"time"
+)
+
+type jsonReport struct {
+	GeneratedAt time.Time   `json:"generated_at"`
+	Summary     jsonSummary `json:"summary"`
+	Sets        []jsonSet   `json:"sets"`
+}
+
+type jsonSummary struct {
+	Total        int            `json:"total"`
+	Up           int            `json:"up"`
+	Availability float64        `json:"availability"`
+	ByStatus     map[string]int `json:"by_status"`
+	Domains      []jsonDomain   `json:"domains"`
+}
+
+type jsonDomain struct {
+	Domain       string  `json:"domain"`
+	Total        int     `json:"total"`
+	Up           int     `json:"up"`
+	Availability float64 `json:"availability"`
+}
+
+type jsonSet struct {
+	ListNum   int          `json:"links_num"`
+	CheckedAt time.Time    `json:"checked_at,omitzero"`
+	Results   []jsonResult `json:"results"`
+}
+
+// jsonResult is a LinkResult with its URL, so results keep their order as an
+// array instead of becoming object keys.
+type jsonResult struct {
+	URL string `json:"url"`
+	models.LinkResult
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) render(w io.Writer, r report) error {
+	summary := r.summary()
+	out := jsonReport{
+		GeneratedAt: r.generatedAt,
+		Summary: jsonSummary{
+			Total:        summary.total,
+			Up:           summary.up,
+			Availability: roundPercent(summary.availability()),
+			ByStatus:     make(map[string]int, len(reportStatuses)),
+			Domains:      make([]jsonDomain, 0, len(summary.domains)),
+		},
+		Sets: make([]jsonSet, 0, len(r.sets)),
+	}
+	for _, status := range reportStatuses {
+		out.Summary.ByStatus[status] = summary.byStatus[status]
+	}
+	for _, d := range summary.domains {
+		out.Summary.Domains = append(out.Summary.Domains, jsonDomain{
+			Domain:       d.domain,
+			Total:        d.total,
+			Up:           d.up,
+			Availability: roundPercent(d.availability()),
+		})
+	}
+
+	for _, set := range r.sets {
+		links := r.links(set)
+		results := make([]jsonResult, len(links))
+		for i, link := range links {
+			results[i] = jsonResult{URL: link, LinkResult: set.Answer[link]}
+		}
+		out.Sets = append(out.Sets, jsonSet{ListNum: set.ListNum, CheckedAt: checkedAt(set), Results: results})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+func roundPercent(p float64) float64 {
+	return math.Round(p*10) / 10
+}
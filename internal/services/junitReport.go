@@ -0,0 +1,117 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"status-links/internal/models"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Details string `xml:",cdata"`
+}
+
+// junitRenderer reports each set as a test suite and each URL as a test case
+// that fails when the link is down. Degraded links pass, with the reason in
+// system-out, matching the availability figures of the other formats.
+type junitRenderer struct{}
+
+func (junitRenderer) render(w io.Writer, r report) error {
+	out := junitTestSuites{Name: "status-links"}
+	var totalMs int64
+	for _, set := range r.sets {
+		suite := junitTestSuite{Name: fmt.Sprintf("set-%d", set.ListNum)}
+		if at := checkedAt(set); !at.IsZero() {
+			suite.Timestamp = at.UTC().Format(time.RFC3339)
+		}
+		var suiteMs int64
+		for _, link := range r.links(set) {
+			result := set.Answer[link]
+			testCase := junitTestCase{Name: link, ClassName: linkDomain(link), Time: junitSeconds(result.LatencyMs)}
+			switch {
+			case !isUp(result.Status):
+				kind := result.ErrorKind
+				if kind == "" {
+					kind = result.Status
+				}
+				testCase.Failure = &junitFailure{Message: failureReason(result), Type: kind, Details: junitDetails(result)}
+				suite.Failures++
+			case result.Status == models.StatusDegraded:
+				testCase.SystemOut = "degraded: " + failureReason(result)
+			}
+			suite.Cases = append(suite.Cases, testCase)
+			suiteMs += result.LatencyMs
+		}
+		suite.Tests = len(suite.Cases)
+		suite.Time = junitSeconds(suiteMs)
+
+		out.Tests += suite.Tests
+		out.Failures += suite.Failures
+		totalMs += suiteMs
+		out.Suites = append(out.Suites, suite)
+	}
+	out.Time = junitSeconds(totalMs)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitSeconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
+
+// junitDetails is the failure body CI systems show when a case is expanded.
+func junitDetails(result models.LinkResult) string {
+	lines := []string{"status: " + result.Status}
+	if result.StatusCode > 0 {
+		lines = append(lines, fmt.Sprintf("status_code: %d", result.StatusCode))
+	}
+	if result.Error != "" {
+		lines = append(lines, "error: "+result.Error)
+	}
+	if len(result.Redirects) > 0 {
+		lines = append(lines, "redirects: "+redirectChain(result.Redirects))
+	}
+	if result.FinalURL != "" {
+		lines = append(lines, "final_url: "+result.FinalURL)
+	}
+	return strings.Join(lines, "\n")
+}
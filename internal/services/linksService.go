@@ -1,40 +1,138 @@
 package services
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
 	"status-links/internal/models"
 	"status-links/internal/storage"
 	"sync"
 	"time"
-
-	"github.com/jung-kurt/gofpdf"
 )
 
 var (
 	ErrTooBigIndex = errors.New("too big index")
+	ErrExpired     = errors.New("link set expired")
 )
 
+type Config struct {
+	Workers        int
+	MaxRunningJobs int
+	CheckMethod    string
+	MaxBodyBytes   int64
+	Retry          RetryPolicy
+	HostLimits     HostLimits
+	UserAgent      string
+	Headers        map[string]string
+	SSRF           SSRFPolicy
+	Redirects      RedirectPolicy
+
+	CertExpiryWarnDays int
+	CompactInterval    time.Duration
+	Retention          RetentionPolicy
+	// ReportLang selects the language of PDF headings and status labels.
+	ReportLang string
+	// ReportFontFile replaces the embedded font, for scripts it lacks.
+	ReportFontFile string
+	// InstanceID marks the pending tasks this process owns, so replicas
+	// sharing a storage only resume their own jobs. When empty, every
+	// pending job is resumed on start.
+	InstanceID string
+	// JobLease is how long the jobs of an owner that stopped renewing its
+	// claims are left alone before another instance takes them over.
+	JobLease time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxRunningJobs <= 0 {
+		c.MaxRunningJobs = defaultRunningJobs
+	}
+	switch c.CheckMethod {
+	case CheckHeadThenGet, CheckGetOnly, CheckHeadOnly:
+	default:
+		if c.CheckMethod != "" {
+			slog.Warn("Unknown check method, falling back to default", "method", c.CheckMethod)
+		}
+		c.CheckMethod = CheckHeadThenGet
+	}
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	c.Retry = c.Retry.withDefaults()
+	c.Redirects = c.Redirects.withDefaults()
+	c.Retention = c.Retention.withDefaults()
+	if c.JobLease <= 0 {
+		c.JobLease = defaultJobLease
+	}
+	if c.CertExpiryWarnDays <= 0 {
+		c.CertExpiryWarnDays = defaultCertExpiryWarnDays
+	}
+	if c.UserAgent == "" {
+		c.UserAgent = defaultUserAgent
+	}
+	if !isReportLang(c.ReportLang) {
+		if c.ReportLang != "" {
+			slog.Warn("Unknown report language, falling back to default", "lang", c.ReportLang)
+		}
+		c.ReportLang = defaultReportLang
+	}
+	return c
+}
+
 type LinksService struct {
+	cfg      Config
 	temp     storage.TempStorage
 	reliable storage.ReliableStorage
 	client   *http.Client
+	pool     *workerPool
 	wg       sync.WaitGroup
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	jobs     map[int]*job
+	jobsMu   sync.Mutex
+	jobSlots chan struct{}
+
+	compaction compaction
+
+	text  reportText
+	fonts reportFonts
 }
 
-func NewLinksService(temp storage.TempStorage, reliable storage.ReliableStorage) *LinksService {
+func NewLinksService(temp storage.TempStorage, reliable storage.ReliableStorage, cfg Config) *LinksService {
+	cfg = cfg.withDefaults()
 	service := &LinksService{
+		cfg:      cfg,
 		temp:     temp,
 		reliable: reliable,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		client:   newCheckClient(cfg),
+		pool:     newWorkerPool(cfg.Workers),
+		jobs:     make(map[int]*job),
+		jobSlots: make(chan struct{}, cfg.MaxRunningJobs),
+		text:     reportTextFor(cfg.ReportLang),
+		fonts:    loadReportFonts(cfg.ReportFontFile),
 	}
+	service.ctx, service.cancel = context.WithCancel(context.Background())
 
 	service.uploadAllToFastMem()
+	service.resumeJobs()
+
+	if _, ok := reliable.(storage.Compactor); ok && cfg.CompactInterval > 0 {
+		service.wg.Add(1)
+		go service.compactPeriodically(cfg.CompactInterval)
+	}
+	if cfg.Retention.enabled() {
+		service.expireSets()
+		service.wg.Add(1)
+		go service.expirePeriodically(cfg.Retention.Interval)
+	}
+	if cfg.InstanceID != "" {
+		service.wg.Add(1)
+		go service.renewClaimsPeriodically(cfg.JobLease / 3)
+	}
 	return service
 }
 
@@ -83,7 +181,7 @@ func (l *LinksService) UploadAllUnfinishedWork() *models.AllUnfinishedWork {
 	}
 
 	for _, numSet := range pendingNums {
-		pdfResult := l.generatePDF(numSet)
+		pdfResult := l.generateReport(numSet)
 		if pdfResult != nil {
 			result.Pdfs = append(result.Pdfs, *pdfResult)
 		}
@@ -96,6 +194,12 @@ func (l *LinksService) UploadAllUnfinishedWork() *models.AllUnfinishedWork {
 	return result
 }
 func (l *LinksService) uploadAllToFastMem() *models.ProcessedLinks {
+	loader, canLoad := l.temp.(storage.IndexLoader)
+	lister, canList := l.reliable.(storage.SetLister)
+	if canLoad && canList {
+		return l.uploadIndexToFastMem(loader, lister)
+	}
+
 	allData, err := l.reliable.ReadAllFile()
 	if err != nil {
 		return &models.ProcessedLinks{
@@ -105,6 +209,11 @@ func (l *LinksService) uploadAllToFastMem() *models.ProcessedLinks {
 	}
 
 	l.temp.UploadAllData(allData)
+	if lastNum, err := l.reliable.LastListNum(); err != nil {
+		slog.Error("error in LastListNum", "error", err)
+	} else {
+		l.temp.RestoreMaxIndex(lastNum)
+	}
 
 	return &models.ProcessedLinks{
 		Answer:  make(models.LinksAnswer),
@@ -112,166 +221,220 @@ func (l *LinksService) uploadAllToFastMem() *models.ProcessedLinks {
 	}
 }
 
-func (l *LinksService) AddLinkSet(set models.SetLinksGet) *models.ProcessedLinks {
-	hash, err := l.reliable.AddLinksProcessList(&set)
+// storeNewSet puts a new set into the temp storage and returns its number.
+// A reliable storage shared between replicas allocates the number, so it is
+// unique across all of them.
+func (l *LinksService) storeNewSet(set *models.ProcessedLinks) (int, error) {
+	allocator, ok := l.reliable.(storage.NumberAllocator)
+	if !ok {
+		return l.temp.UploadNewData(set), nil
+	}
+	listNum, err := allocator.AllocateListNum()
 	if err != nil {
-		slog.Error("error in AddLinksProcessList", "error", err)
+		return 0, err
 	}
+	set.ListNum = listNum
+	l.temp.UpdateData(set)
+	return listNum, nil
+}
 
-	answer := make(models.LinksAnswer)
-
-	for _, url := range set.Links {
-		status := l.checkLinkStatus(url)
-		answer[url] = status
+// completeTask persists a checked set and clears its pending task. The set
+// is written before the task is removed, in one step when the backend
+// supports it, so a crash between the two never loses the result.
+func (l *LinksService) completeTask(taskID string, processed *models.ProcessedLinks) error {
+	if completer, ok := l.reliable.(storage.TaskCompleter); ok {
+		if err := completer.CompleteLinksTask(taskID, processed); err != nil {
+			slog.Error("error in CompleteLinksTask", "error", err)
+			return err
+		}
+		return nil
 	}
+	if err := l.reliable.AddNewLinkPerm(processed); err != nil {
+		slog.Error("failed to save processed links:", "error", err)
+		return err
+	}
+	if err := l.reliable.RemoveLinksTask(taskID); err != nil {
+		slog.Error("error in RemoveLinksTask", "error", err)
+	}
+	return nil
+}
 
-	listNum := l.temp.UploadNewData(&models.ProcessedLinks{
-		Answer: answer,
-	})
-
-	l.wg.Add(1)
-	go func() {
-		defer l.wg.Done()
-		processed := &models.ProcessedLinks{
-			Answer:  answer,
-			ListNum: listNum,
+// loadSharedSets reads the given sets from a shared reliable storage when
+// this replica does not hold them, e.g. because another replica created them.
+func (l *LinksService) loadSharedSets(listNums []int) {
+	if _, ok := l.reliable.(storage.NumberAllocator); !ok {
+		return
+	}
+	for _, num := range listNums {
+		if _, err := l.temp.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{num}}); err == nil {
+			continue
 		}
-		if err := l.reliable.AddNewLinkPerm(processed); err != nil {
-			slog.Error("failed to save processed links:", "error", err)
+		set, err := l.reliable.ReadSet(num)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				slog.Error("error in ReadSet", "links_num", num, "error", err)
+			}
+			continue
 		}
-	}()
+		l.temp.UpdateData(set)
+	}
+}
 
-	err = l.reliable.RemoveLinksProcessByHash(hash)
+// uploadIndexToFastMem starts a cache from the list of stored sets, so the
+// whole history is never read into memory at once.
+func (l *LinksService) uploadIndexToFastMem(loader storage.IndexLoader, lister storage.SetLister) *models.ProcessedLinks {
+	created, err := lister.ListSets()
 	if err != nil {
-		slog.Error("error in RemoveLinksProcessByHash", "error", err)
+		slog.Error("error in ListSets", "error", err)
+		return &models.ProcessedLinks{
+			Answer:  make(models.LinksAnswer),
+			ListNum: -1,
+		}
+	}
+	if err := loader.UploadIndex(created); err != nil {
+		slog.Error("error in UploadIndex", "error", err)
+	}
+	if lastNum, err := l.reliable.LastListNum(); err != nil {
+		slog.Error("error in LastListNum", "error", err)
+	} else {
+		l.temp.RestoreMaxIndex(lastNum)
 	}
+
 	return &models.ProcessedLinks{
-		Answer:  answer,
-		ListNum: listNum,
+		Answer:  make(models.LinksAnswer),
+		ListNum: len(created),
+	}
+}
+
+func (l *LinksService) AddLinkSet(set models.SetLinksGet) (*models.ProcessedLinks, error) {
+	links, rejected, inputs := normalizeLinks(set.Links)
+	if len(links) == 0 {
+		return &models.ProcessedLinks{
+			Answer:   make(models.LinksAnswer),
+			Rejected: rejected,
+		}, nil
+	}
+
+	taskID, err := l.reliable.AddLinksProcessList(&set, l.cfg.InstanceID)
+	if err != nil {
+		slog.Error("error in AddLinksProcessList", "error", err)
+	}
+
+	answer := l.checkLinks(links, set.Headers)
+
+	createdAt := time.Now().UTC()
+	processed := &models.ProcessedLinks{
+		Answer:    answer,
+		Rejected:  rejected,
+		CreatedAt: createdAt,
+		CheckedAt: createdAt,
+		Order:     links,
+		Inputs:    inputs,
+	}
+	listNum, err := l.storeNewSet(processed)
+	if err != nil {
+		slog.Error("error in AllocateListNum", "error", err)
+		return nil, err
 	}
+	processed.ListNum = listNum
+
+	// A failed write keeps the pending task, so the links are checked
+	// again after a restart.
+	l.completeTask(taskID, processed)
+
+	return &models.ProcessedLinks{
+		Answer:   answer,
+		ListNum:  listNum,
+		Rejected: rejected,
+		Order:    links,
+		Inputs:   inputs,
+	}, nil
 }
 
 func (l *LinksService) GiveLinkAnswer(list models.SetNumsOfLinksGet) (*models.ListOfProcessedLinks, error) {
+	l.loadSharedSets(list.NumsLinks)
 	maxInt := l.temp.ReturnMaxIndex()
 	for _, v := range list.NumsLinks {
 		if v > maxInt {
 			return nil, ErrTooBigIndex
 		}
+		if l.jobUnfinished(v) {
+			return nil, ErrJobNotReady
+		}
+	}
+	if _, err := l.temp.FindKeys(&list); errors.Is(err, storage.ErrSetExpired) {
+		return nil, ErrExpired
 	}
-	hash, err := l.reliable.AddNumProcessList(&list)
+	taskID, err := l.reliable.AddNumProcessList(&list, l.cfg.InstanceID)
 	if err != nil {
 		slog.Error("error in AddNumProcessList", "error", err)
 
 	}
-	result := l.generatePDF(list)
+	result := l.generateReport(list)
 
 	l.wg.Add(1)
 	go func() {
 		defer l.wg.Done()
-		hash, err := l.reliable.AddNumProcessList(&list)
+		taskID, err := l.reliable.AddNumProcessList(&list, l.cfg.InstanceID)
 		if err != nil {
 			slog.Error("failed to add to pending:", "error", err)
 			return
 		}
-		l.reliable.RemoveNumsProcessByHash(hash)
+		l.reliable.RemoveNumsTask(taskID)
 	}()
-	err = l.reliable.RemoveNumsProcessByHash(hash)
+	err = l.reliable.RemoveNumsTask(taskID)
 	if err != nil {
-		slog.Error("error in RemoveNumsProcessByHash", "error", err)
+		slog.Error("error in RemoveNumsTask", "error", err)
 	}
 	return result, nil
 }
 
 func (l *LinksService) processLinks(set models.SetLinksGet) *models.ProcessedLinks {
-	answer := make(models.LinksAnswer)
-
-	for _, url := range set.Links {
-		status := l.checkLinkStatus(url)
-		answer[url] = status
-	}
-
-	listNum := l.temp.UploadNewData(&models.ProcessedLinks{
-		Answer: answer,
-	})
-
-	return &models.ProcessedLinks{
-		Answer:  answer,
-		ListNum: listNum,
+	links, rejected, inputs := normalizeLinks(set.Links)
+	if len(links) == 0 {
+		return nil
 	}
-}
 
-func (l *LinksService) checkLinkStatus(url string) string {
-	fullURL := url
-	if !hasScheme(url) {
-		fullURL = "https://" + url
-	}
+	answer := l.checkLinks(links, set.Headers)
 
-	resp, err := l.client.Head(fullURL)
+	checkedAt := time.Now().UTC()
+	listNum, err := l.storeNewSet(&models.ProcessedLinks{
+		Answer:    answer,
+		Rejected:  rejected,
+		CreatedAt: checkedAt,
+		CheckedAt: checkedAt,
+		Order:     links,
+		Inputs:    inputs,
+	})
 	if err != nil {
-		return "unavailable"
+		slog.Error("error in AllocateListNum", "error", err)
+		return nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-		return "available"
+	return &models.ProcessedLinks{
+		Answer:   answer,
+		ListNum:  listNum,
+		Rejected: rejected,
+		Order:    links,
+		Inputs:   inputs,
 	}
-	return "unavailable"
 }
 
-func (l *LinksService) generatePDF(set models.SetNumsOfLinksGet) *models.ListOfProcessedLinks {
-	linksAnswers, err := l.temp.FindKeys(&set)
-	if err != nil {
-		return &models.ListOfProcessedLinks{
-			Description: fmt.Sprintf("Error finding keys: %v", err),
-		}
-	}
-
-	if len(*linksAnswers) == 0 {
-		return &models.ListOfProcessedLinks{
-			Description: "No data found for the provided numbers",
-			PDF:         []byte{},
-		}
-	}
-
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetFont("Arial", "B", 16)
-	pdf.Cell(40, 10, "Links Status Report")
-	pdf.Ln(12)
-
-	pdf.SetFont("Arial", "", 12)
-
-	row := 1
-	for _, linkAnswer := range *linksAnswers {
-		for url, status := range linkAnswer {
-			statusText := "Available"
-			if status == "unavailable" {
-				statusText = "Unavailable"
-			}
-			pdf.Cell(0, 10, fmt.Sprintf("%d. %s - %s", row, url, statusText))
-			pdf.Ln(6)
-			row++
-		}
-	}
-
-	var buf bytes.Buffer
-	err = pdf.Output(&buf)
-	if err != nil {
-		return &models.ListOfProcessedLinks{
-			Description: fmt.Sprintf("Error generating PDF: %v", err),
-			PDF:         []byte{},
-		}
-	}
+func (l *LinksService) checkLinks(links []string, headers map[string]string) models.LinksAnswer {
+	statuses := make([]models.LinkResult, len(links))
+	l.pool.run(len(links), func(i int) {
+		statuses[i] = l.checkLinkStatus(links[i], headers)
+	})
 
-	return &models.ListOfProcessedLinks{
-		Description: "PDF report generated successfully",
-		PDF:         buf.Bytes(),
+	answer := make(models.LinksAnswer, len(links))
+	for i, url := range links {
+		answer[url] = statuses[i]
 	}
+	return answer
 }
 
 func (l *LinksService) WaitForCompletion() {
+	l.cancel()
 	l.wg.Wait()
-}
-func hasScheme(url string) bool {
-	return len(url) > 7 && (url[0:7] == "http://" || url[0:8] == "https://")
+	l.pool.close()
 }
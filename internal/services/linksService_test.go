@@ -1,10 +1,21 @@
 package services
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"status-links/internal/models"
+	"status-links/internal/storage"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type mockTempStorage struct {
@@ -36,16 +47,40 @@ func (m *mockTempStorage) UploadNewData(bs *models.ProcessedLinks) int {
 	m.data[newNum] = *bs
 	return newNum
 }
+func (m *mockTempStorage) UpdateData(bs *models.ProcessedLinks) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[bs.ListNum] = *bs
+}
 func (m *mockTempStorage) ReturnMaxIndex() int {
 	return m.maxInt
 }
+func (m *mockTempStorage) RestoreMaxIndex(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxInt = max(m.maxInt, n)
+}
+func (m *mockTempStorage) Expire(cutoff time.Time, maxSets int, keep func(int) bool) []int {
+	return nil
+}
 func (m *mockTempStorage) FindKeys(list *models.SetNumsOfLinksGet) (*[]models.LinksAnswer, error) {
+	sets, err := m.FindSets(list)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.LinksAnswer, 0, len(*sets))
+	for _, item := range *sets {
+		result = append(result, item.Answer)
+	}
+	return &result, nil
+}
+func (m *mockTempStorage) FindSets(list *models.SetNumsOfLinksGet) (*[]models.ProcessedLinks, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	result := make([]models.LinksAnswer, 0)
+	result := make([]models.ProcessedLinks, 0)
 	for _, num := range list.NumsLinks {
 		if item, exists := m.data[num]; exists {
-			result = append(result, item.Answer)
+			result = append(result, item)
 		} else {
 			return nil, fmt.Errorf("key %d does not exist", num)
 		}
@@ -57,6 +92,11 @@ type mockReliableStorage struct {
 	allData      []models.ProcessedLinks
 	pendingLinks []models.SetLinksGet
 	pendingNums  []models.SetNumsOfLinksGet
+	jobs         []models.LinksJob
+	deleted      []int
+	lastNum      int
+	jobErr       error
+	permErr      error
 	mu           sync.Mutex
 }
 
@@ -74,37 +114,67 @@ func (m *mockReliableStorage) ReadAllFile() (*[]models.ProcessedLinks, error) {
 	return &m.allData, nil
 }
 
+func (m *mockReliableStorage) ReadSet(listNum int) (*models.ProcessedLinks, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, set := range m.allData {
+		if set.ListNum == listNum {
+			return &set, nil
+		}
+	}
+	return nil, fmt.Errorf("set %d not found", listNum)
+}
+
 func (m *mockReliableStorage) AddNewLinkPerm(item *models.ProcessedLinks) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.permErr != nil {
+		return m.permErr
+	}
 	m.allData = append(m.allData, *item)
 	return nil
 }
 
-func (m *mockReliableStorage) AddLinksProcessList(set *models.SetLinksGet) (string, error) {
+func (m *mockReliableStorage) AddLinksProcessList(set *models.SetLinksGet, owner string) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.pendingLinks = append(m.pendingLinks, *set)
-	return "link-hash", nil
+	return "link-task", nil
+}
+
+func (m *mockReliableStorage) AddLinksJob(listNum int, set *models.SetLinksGet, owner string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.jobErr != nil {
+		return "", m.jobErr
+	}
+	m.jobs = append(m.jobs, models.LinksJob{ID: fmt.Sprintf("job-%d", listNum), ListNum: listNum, Set: *set, Owner: owner})
+	return fmt.Sprintf("job-%d", listNum), nil
 }
 
-func (m *mockReliableStorage) AddNumProcessList(set *models.SetNumsOfLinksGet) (string, error) {
+func (m *mockReliableStorage) AddNumProcessList(set *models.SetNumsOfLinksGet, owner string) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.pendingNums = append(m.pendingNums, *set)
-	return "num-hash", nil
+	return "num-task", nil
 }
 
-func (m *mockReliableStorage) RemoveLinksProcessByHash(hash string) error {
+func (m *mockReliableStorage) RemoveLinksTask(id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	for i, job := range m.jobs {
+		if job.ID == id {
+			m.jobs = append(m.jobs[:i], m.jobs[i+1:]...)
+			return nil
+		}
+	}
 	if len(m.pendingLinks) > 0 {
 		m.pendingLinks = m.pendingLinks[:len(m.pendingLinks)-1]
 	}
 	return nil
 }
 
-func (m *mockReliableStorage) RemoveNumsProcessByHash(hash string) error {
+func (m *mockReliableStorage) RemoveNumsTask(id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if len(m.pendingNums) > 0 {
@@ -129,12 +199,106 @@ func (m *mockReliableStorage) GetPendingNumsData() ([]models.SetNumsOfLinksGet,
 	return result, nil
 }
 
+// ClaimPendingJobs treats a job's CreatedAt as the time it was last claimed.
+func (m *mockReliableStorage) ClaimPendingJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var claimed []models.LinksJob
+	for i := range m.jobs {
+		if m.jobs[i].Owner == "" || m.jobs[i].Owner == owner || m.jobs[i].CreatedAt.Before(staleBefore) {
+			m.jobs[i].Owner = owner
+			m.jobs[i].CreatedAt = time.Now()
+			m.jobs[i].Attempts++
+			claimed = append(claimed, m.jobs[i])
+		}
+	}
+	return claimed, nil
+}
+
+func (m *mockReliableStorage) TakeOverJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var claimed []models.LinksJob
+	for i := range m.jobs {
+		if m.jobs[i].Owner != owner && (m.jobs[i].Owner == "" || m.jobs[i].CreatedAt.Before(staleBefore)) {
+			m.jobs[i].Owner = owner
+			m.jobs[i].CreatedAt = time.Now()
+			m.jobs[i].Attempts++
+			claimed = append(claimed, m.jobs[i])
+		}
+	}
+	return claimed, nil
+}
+
+func (m *mockReliableStorage) RenewJobClaims(owner string) error {
+	return nil
+}
+
+func (m *mockReliableStorage) DeleteSets(listNums []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleted = append(m.deleted, listNums...)
+	return nil
+}
+
+func (m *mockReliableStorage) LastListNum() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastNum, nil
+}
+
+type completingStorage struct {
+	*mockReliableStorage
+	completed []string
+}
+
+func (c *completingStorage) CompleteLinksTask(id string, item *models.ProcessedLinks) error {
+	c.mu.Lock()
+	c.completed = append(c.completed, id)
+	c.mu.Unlock()
+	if err := c.AddNewLinkPerm(item); err != nil {
+		return err
+	}
+	return c.RemoveLinksTask(id)
+}
+
+// sharedStorage stands in for a database shared by replicas: it allocates
+// the set numbers itself.
+type sharedStorage struct {
+	*mockReliableStorage
+	allocated int
+}
+
+func (s *sharedStorage) AllocateListNum() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allocated++
+	return s.allocated, nil
+}
+
+func waitForJob(t *testing.T, service *LinksService, listNum int) *models.JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := service.GetJobStatus(listNum)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if status.State == models.JobDone || status.State == models.JobFailed {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job %d did not finish in time", listNum)
+	return nil
+}
+
 func TestLinksService(t *testing.T) {
 	t.Run("NewLinksService initializes correctly", func(t *testing.T) {
 		tempStorage := newMockTempStorage()
 		reliableStorage := newMockReliableStorage()
 
-		service := NewLinksService(tempStorage, reliableStorage)
+		service := NewLinksService(tempStorage, reliableStorage, Config{})
 
 		if service == nil {
 			t.Error("Expected service to be created")
@@ -154,13 +318,13 @@ func TestLinksService(t *testing.T) {
 	t.Run("AddLinkSet processes links and returns result", func(t *testing.T) {
 		tempStorage := newMockTempStorage()
 		reliableStorage := newMockReliableStorage()
-		service := NewLinksService(tempStorage, reliableStorage)
+		service := NewLinksService(tempStorage, reliableStorage, Config{})
 
 		set := models.SetLinksGet{
 			Links: []string{"https://httpbin.org/status/200", "https://httpbin.org/status/404"},
 		}
 
-		result := service.AddLinkSet(set)
+		result, _ := service.AddLinkSet(set)
 
 		if result == nil {
 			t.Error("Expected non-nil result")
@@ -176,15 +340,41 @@ func TestLinksService(t *testing.T) {
 		service.WaitForCompletion()
 	})
 
+	t.Run("AddLinkSet persists the set before clearing its pending task", func(t *testing.T) {
+		set := models.SetLinksGet{Links: []string{"https://example.com"}}
+
+		reliableStorage := &completingStorage{mockReliableStorage: newMockReliableStorage()}
+		result, _ := NewLinksService(newMockTempStorage(), reliableStorage, Config{}).AddLinkSet(set)
+		if len(reliableStorage.completed) != 1 || len(reliableStorage.allData) != 1 {
+			t.Fatalf("Expected the set completed in one step, got %v and %d sets", reliableStorage.completed, len(reliableStorage.allData))
+		}
+		if reliableStorage.allData[0].ListNum != result.ListNum {
+			t.Errorf("Expected set %d stored, got %d", result.ListNum, reliableStorage.allData[0].ListNum)
+		}
+
+		plain := newMockReliableStorage()
+		NewLinksService(newMockTempStorage(), plain, Config{}).AddLinkSet(set)
+		if len(plain.allData) != 1 || len(plain.pendingLinks) != 0 {
+			t.Errorf("Expected the set stored and the task cleared, got %d sets and %d tasks", len(plain.allData), len(plain.pendingLinks))
+		}
+
+		failing := newMockReliableStorage()
+		failing.permErr = errors.New("disk full")
+		NewLinksService(newMockTempStorage(), failing, Config{}).AddLinkSet(set)
+		if len(failing.pendingLinks) != 1 {
+			t.Errorf("Expected the pending task kept after a failed write, got %d", len(failing.pendingLinks))
+		}
+	})
+
 	t.Run("GiveLinkAnswer generates PDF for existing data", func(t *testing.T) {
 		tempStorage := newMockTempStorage()
 		reliableStorage := newMockReliableStorage()
-		service := NewLinksService(tempStorage, reliableStorage)
+		service := NewLinksService(tempStorage, reliableStorage, Config{})
 
 		set := models.SetLinksGet{
 			Links: []string{"https://example.com"},
 		}
-		addResult := service.AddLinkSet(set)
+		addResult, _ := service.AddLinkSet(set)
 
 		pdfRequest := models.SetNumsOfLinksGet{
 			NumsLinks: []int{addResult.ListNum},
@@ -199,7 +389,7 @@ func TestLinksService(t *testing.T) {
 		if pdfResult.Description == "" {
 			t.Error("Expected description in PDF result")
 		}
-		if len(pdfResult.PDF) == 0 {
+		if len(pdfResult.Report) == 0 {
 			t.Error("Expected non-empty PDF data")
 		}
 
@@ -209,7 +399,7 @@ func TestLinksService(t *testing.T) {
 	t.Run("UploadAllUnfinishedWork with no pending work", func(t *testing.T) {
 		tempStorage := newMockTempStorage()
 		reliableStorage := newMockReliableStorage()
-		service := NewLinksService(tempStorage, reliableStorage)
+		service := NewLinksService(tempStorage, reliableStorage, Config{})
 
 		result := service.UploadAllUnfinishedWork()
 
@@ -232,51 +422,33 @@ func TestLinksService(t *testing.T) {
 	t.Run("checkLinkStatus handles different URLs", func(t *testing.T) {
 		tempStorage := newMockTempStorage()
 		reliableStorage := newMockReliableStorage()
-		service := NewLinksService(tempStorage, reliableStorage)
+		service := NewLinksService(tempStorage, reliableStorage, Config{})
 
-		status1 := service.checkLinkStatus("https://httpbin.org/status/200")
-		if status1 != "available" {
-			t.Errorf("Expected available for 200 status, got %s", status1)
+		status1 := service.checkLinkStatus("https://httpbin.org/status/200", nil)
+		if status1.Status != "available" {
+			t.Errorf("Expected available for 200 status, got %s", status1.Status)
 		}
 
-		status2 := service.checkLinkStatus("httpbin.org/status/200")
-		if status2 != "available" {
-			t.Errorf("Expected available for URL without scheme, got %s", status2)
+		withoutScheme, err := normalizeURL("httpbin.org/status/200")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
 		}
-	})
-
-	t.Run("hasScheme correctly detects URL schemes", func(t *testing.T) {
-		tests := []struct {
-			url      string
-			expected bool
-		}{
-			{"https://example.com", true},
-			{"http://example.com", true},
-			{"example.com", false},
-			{"ftp://example.com", false},
-			{"https://a", true},
-			{"http://a", true},
-			{"", false},
-		}
-
-		for _, test := range tests {
-			result := hasScheme(test.url)
-			if result != test.expected {
-				t.Errorf("hasScheme(%q) = %v, expected %v", test.url, result, test.expected)
-			}
+		status2 := service.checkLinkStatus(withoutScheme, nil)
+		if status2.Status != "available" {
+			t.Errorf("Expected available for URL without scheme, got %s", status2.Status)
 		}
 	})
 
-	t.Run("generatePDF with empty data returns appropriate message", func(t *testing.T) {
+	t.Run("generateReport with empty data returns appropriate message", func(t *testing.T) {
 		tempStorage := newMockTempStorage()
 		reliableStorage := newMockReliableStorage()
-		service := NewLinksService(tempStorage, reliableStorage)
+		service := NewLinksService(tempStorage, reliableStorage, Config{})
 
 		request := models.SetNumsOfLinksGet{
 			NumsLinks: []int{999},
 		}
 
-		result := service.generatePDF(request)
+		result := service.generateReport(request)
 
 		if result == nil {
 			t.Error("Expected non-nil result")
@@ -286,26 +458,26 @@ func TestLinksService(t *testing.T) {
 		if result.Description != expectedDescription {
 			t.Errorf("Unexpected description: %s, expected: %s", result.Description, expectedDescription)
 		}
-		if len(result.PDF) != 0 {
+		if len(result.Report) != 0 {
 			t.Error("Expected empty PDF for no data")
 		}
 	})
 
-	t.Run("generatePDF with valid data returns PDF", func(t *testing.T) {
+	t.Run("generateReport with valid data returns PDF", func(t *testing.T) {
 		tempStorage := newMockTempStorage()
 		reliableStorage := newMockReliableStorage()
-		service := NewLinksService(tempStorage, reliableStorage)
+		service := NewLinksService(tempStorage, reliableStorage, Config{})
 
 		set := models.SetLinksGet{
 			Links: []string{"https://example.com"},
 		}
-		addResult := service.AddLinkSet(set)
+		addResult, _ := service.AddLinkSet(set)
 
 		request := models.SetNumsOfLinksGet{
 			NumsLinks: []int{addResult.ListNum},
 		}
 
-		result := service.generatePDF(request)
+		result := service.generateReport(request)
 
 		if result == nil {
 			t.Error("Expected non-nil result")
@@ -314,11 +486,551 @@ func TestLinksService(t *testing.T) {
 		if result.Description != "PDF report generated successfully" {
 			t.Errorf("Unexpected description: %s", result.Description)
 		}
-		if len(result.PDF) == 0 {
+		if len(result.Report) == 0 {
 			t.Error("Expected non-empty PDF data")
 		}
 
 		service.WaitForCompletion()
 	})
 
+	t.Run("SubmitLinkSet runs job in background", func(t *testing.T) {
+		tempStorage := newMockTempStorage()
+		reliableStorage := newMockReliableStorage()
+		service := NewLinksService(tempStorage, reliableStorage, Config{})
+
+		status, err := service.SubmitLinkSet(models.SetLinksGet{
+			Links: []string{"http://does-not-exist.example.com"},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if status.ListNum <= 0 {
+			t.Errorf("Expected positive list number, got %d", status.ListNum)
+		}
+		if status.Total != 1 {
+			t.Errorf("Expected total 1, got %d", status.Total)
+		}
+
+		final := waitForJob(t, service, status.ListNum)
+		if final.State != models.JobDone {
+			t.Errorf("Expected job to be done, got %s", final.State)
+		}
+		if final.Answer["http://does-not-exist.example.com/"].Status != "unavailable" {
+			t.Errorf("Expected unavailable result, got %q", final.Answer["http://does-not-exist.example.com/"].Status)
+		}
+
+		jobs, _ := reliableStorage.ClaimPendingJobs("", time.Time{})
+		if len(jobs) != 0 {
+			t.Errorf("Expected pending job to be cleared, got %d", len(jobs))
+		}
+		if len(reliableStorage.allData) != 1 {
+			t.Errorf("Expected result to be persisted, got %d sets", len(reliableStorage.allData))
+		}
+
+		service.WaitForCompletion()
+	})
+
+	t.Run("Finished job is completed in one storage call when supported", func(t *testing.T) {
+		reliableStorage := &completingStorage{mockReliableStorage: newMockReliableStorage()}
+		service := NewLinksService(newMockTempStorage(), reliableStorage, Config{})
+
+		status, _ := service.SubmitLinkSet(models.SetLinksGet{
+			Links: []string{"http://does-not-exist.example.com"},
+		})
+		waitForJob(t, service, status.ListNum)
+		service.WaitForCompletion()
+
+		if len(reliableStorage.completed) != 1 || reliableStorage.completed[0] != fmt.Sprintf("job-%d", status.ListNum) {
+			t.Errorf("Expected CompleteLinksTask for the job, got %v", reliableStorage.completed)
+		}
+		if len(reliableStorage.allData) != 1 {
+			t.Errorf("Expected result to be persisted, got %d sets", len(reliableStorage.allData))
+		}
+	})
+
+	t.Run("Replicas sharing a storage number sets uniquely and read each other's", func(t *testing.T) {
+		shared := &sharedStorage{mockReliableStorage: newMockReliableStorage()}
+		first := NewLinksService(storage.NewTempStorage(), shared, Config{})
+		second := NewLinksService(storage.NewTempStorage(), shared, Config{})
+
+		set := models.SetLinksGet{Links: []string{"http://does-not-exist.example.com"}}
+		a, err := first.AddLinkSet(set)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		b, err := second.AddLinkSet(set)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		first.WaitForCompletion()
+		second.WaitForCompletion()
+
+		if a.ListNum == b.ListNum {
+			t.Fatalf("Expected distinct numbers, both got %d", a.ListNum)
+		}
+		if _, err := second.GiveLinkAnswer(models.SetNumsOfLinksGet{NumsLinks: []int{a.ListNum}}); err != nil {
+			t.Errorf("Expected a set created by another replica to be readable, got %v", err)
+		}
+		status, err := first.GetJobStatus(b.ListNum)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if status.State != models.JobDone {
+			t.Errorf("Expected done status, got %s", status.State)
+		}
+	})
+
+	t.Run("SubmitLinkSet reports a set without valid links", func(t *testing.T) {
+		service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{})
+		defer service.WaitForCompletion()
+
+		status, err := service.SubmitLinkSet(models.SetLinksGet{Links: []string{"ftp://x"}})
+		if err != ErrNoValidLinks {
+			t.Fatalf("Expected ErrNoValidLinks, got %v", err)
+		}
+		if status.State != models.JobFailed || len(status.Rejected) != 1 {
+			t.Errorf("Expected a failed status listing the rejected link, got %+v", status)
+		}
+	})
+
+	t.Run("SubmitLinkSet fails when the job cannot be persisted", func(t *testing.T) {
+		reliableStorage := newMockReliableStorage()
+		reliableStorage.jobErr = fmt.Errorf("disk full")
+		service := NewLinksService(newMockTempStorage(), reliableStorage, Config{})
+		defer service.WaitForCompletion()
+
+		status, err := service.SubmitLinkSet(models.SetLinksGet{
+			Links: []string{"http://does-not-exist.example.com"},
+		})
+		if err != ErrJobNotDurable {
+			t.Fatalf("Expected ErrJobNotDurable, got %v", err)
+		}
+		if status.State != models.JobFailed {
+			t.Errorf("Expected failed job, got %s", status.State)
+		}
+		if got, _ := service.GetJobStatus(status.ListNum); got.State != models.JobFailed {
+			t.Errorf("Expected status to stay failed, got %s", got.State)
+		}
+	})
+
+	t.Run("Done job status carries its answer", func(t *testing.T) {
+		j := newJob(3, "job-3", []string{"https://example.com/"}, nil, nil, nil, time.Now())
+		j.finish(models.LinksAnswer{"https://example.com/": {Status: models.StatusAvailable}})
+
+		status := j.status()
+		if status.State != models.JobDone || status.Answer["https://example.com/"].Status != models.StatusAvailable {
+			t.Errorf("Expected done status with answer, got %+v", status)
+		}
+	})
+
+	t.Run("Pending jobs are resumed on startup", func(t *testing.T) {
+		tempStorage := newMockTempStorage()
+		reliableStorage := newMockReliableStorage()
+		reliableStorage.jobs = []models.LinksJob{
+			{ID: "job-5", ListNum: 5, Set: models.SetLinksGet{Links: []string{"http://does-not-exist.example.com"}}},
+		}
+
+		service := NewLinksService(tempStorage, reliableStorage, Config{})
+
+		final := waitForJob(t, service, 5)
+		if final.State != models.JobDone {
+			t.Errorf("Expected resumed job to be done, got %s", final.State)
+		}
+
+		service.WaitForCompletion()
+	})
+
+	t.Run("Jobs owned by another live instance are not resumed", func(t *testing.T) {
+		reliableStorage := newMockReliableStorage()
+		reliableStorage.jobs = []models.LinksJob{
+			{ID: "job-5", ListNum: 5, Owner: "node-b", CreatedAt: time.Now(), Set: models.SetLinksGet{Links: []string{"http://does-not-exist.example.com"}}},
+		}
+
+		service := NewLinksService(newMockTempStorage(), reliableStorage, Config{InstanceID: "node-a"})
+		defer service.WaitForCompletion()
+
+		if _, err := service.GetJobStatus(5); err != ErrJobNotFound {
+			t.Errorf("Expected job of node-b to be left alone, got %v", err)
+		}
+		if reliableStorage.jobs[0].Attempts != 0 {
+			t.Errorf("Expected no claim attempt, got %d", reliableStorage.jobs[0].Attempts)
+		}
+	})
+
+	t.Run("Jobs resume after a restart under a different owner", func(t *testing.T) {
+		set := models.SetLinksGet{Links: []string{"http://does-not-exist.example.com"}}
+
+		// Left by a container that got a new hostname on restart.
+		reliableStorage := newMockReliableStorage()
+		reliableStorage.jobs = []models.LinksJob{
+			{ID: "job-6", ListNum: 6, Owner: "3f2a9c1d", CreatedAt: time.Now(), Set: set},
+		}
+		service := NewLinksService(newMockTempStorage(), reliableStorage, Config{})
+		if status := waitForJob(t, service, 6); status.State != models.JobDone {
+			t.Errorf("Expected the job to resume without INSTANCE_ID, got %s", status.State)
+		}
+		service.WaitForCompletion()
+
+		// Left by a replica that stopped renewing its claim.
+		reliableStorage = newMockReliableStorage()
+		reliableStorage.jobs = []models.LinksJob{
+			{ID: "job-7", ListNum: 7, Owner: "node-b", CreatedAt: time.Now().Add(-time.Hour), Set: set},
+		}
+		service = NewLinksService(newMockTempStorage(), reliableStorage, Config{InstanceID: "node-a", JobLease: time.Minute})
+		if status := waitForJob(t, service, 7); status.State != models.JobDone {
+			t.Errorf("Expected the abandoned job to be taken over, got %s", status.State)
+		}
+		service.WaitForCompletion()
+	})
+
+	t.Run("Running instances take over jobs whose lease runs out", func(t *testing.T) {
+		reliableStorage := newMockReliableStorage()
+		reliableStorage.jobs = []models.LinksJob{
+			{ID: "job-8", ListNum: 8, Owner: "node-b", CreatedAt: time.Now(), Set: models.SetLinksGet{Links: []string{"http://does-not-exist.example.com"}}},
+		}
+		service := NewLinksService(newMockTempStorage(), reliableStorage, Config{InstanceID: "node-a", JobLease: 30 * time.Millisecond})
+		defer service.WaitForCompletion()
+
+		if _, err := service.GetJobStatus(8); err != ErrJobNotFound {
+			t.Fatalf("Expected the fresh claim of node-b to be respected, got %v", err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if _, err := service.GetJobStatus(8); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Expected the job to be taken over once node-b stopped renewing it")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if status := waitForJob(t, service, 8); status.State != models.JobDone {
+			t.Errorf("Expected the taken over job to finish, got %s", status.State)
+		}
+	})
+
+	t.Run("GetJobStatus for unknown job", func(t *testing.T) {
+		service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{})
+
+		if _, err := service.GetJobStatus(42); err != ErrJobNotFound {
+			t.Errorf("Expected ErrJobNotFound, got %v", err)
+		}
+
+		service.WaitForCompletion()
+	})
+	t.Run("checkLinkStatus records code, latency and error kind", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{})
+		defer service.WaitForCompletion()
+
+		ok := service.checkLinkStatus(server.URL+"/", nil)
+		if ok.Status != models.StatusAvailable || ok.StatusCode != http.StatusOK {
+			t.Errorf("Expected available 200, got %+v", ok)
+		}
+		if ok.FinalURL != server.URL+"/" {
+			t.Errorf("Expected final URL %s, got %s", server.URL+"/", ok.FinalURL)
+		}
+
+		missing := service.checkLinkStatus(server.URL+"/missing", nil)
+		if missing.Status != models.StatusUnavailable || missing.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected unavailable 404, got %+v", missing)
+		}
+		if missing.ErrorKind != models.ErrorKindHTTPStatus {
+			t.Errorf("Expected http_status error kind, got %q", missing.ErrorKind)
+		}
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		closedAddr := listener.Addr().String()
+		listener.Close()
+
+		refused := service.checkLinkStatus("http://"+closedAddr, nil)
+		if refused.ErrorKind != models.ErrorKindRefused {
+			t.Errorf("Expected connection_refused, got %q (%s)", refused.ErrorKind, refused.Error)
+		}
+
+		dns := service.checkLinkStatus("http://host.invalid", nil)
+		if dns.ErrorKind != models.ErrorKindDNS {
+			t.Errorf("Expected dns error kind, got %q (%s)", dns.ErrorKind, dns.Error)
+		}
+	})
+	t.Run("checkLinkStatus falls back to GET when HEAD is rejected", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Write(make([]byte, 1<<20))
+		}))
+		defer server.Close()
+
+		tests := []struct {
+			method         string
+			expectedStatus string
+			expectedMethod string
+		}{
+			{CheckHeadThenGet, models.StatusAvailable, http.MethodGet},
+			{CheckHeadOnly, models.StatusUnavailable, http.MethodHead},
+			{CheckGetOnly, models.StatusAvailable, http.MethodGet},
+		}
+
+		for _, test := range tests {
+			service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{
+				CheckMethod:  test.method,
+				MaxBodyBytes: 1024,
+			})
+
+			result := service.checkLinkStatus(server.URL, nil)
+			if result.Status != test.expectedStatus {
+				t.Errorf("%s: expected status %s, got %s", test.method, test.expectedStatus, result.Status)
+			}
+			if result.Method != test.expectedMethod {
+				t.Errorf("%s: expected method %s, got %s", test.method, test.expectedMethod, result.Method)
+			}
+			service.WaitForCompletion()
+		}
+	})
+	t.Run("checkLinkStatus retries transient failures", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{
+			CheckMethod: CheckHeadOnly,
+			Retry: RetryPolicy{
+				MaxAttempts: 3,
+				BaseDelay:   time.Millisecond,
+				MaxDelay:    5 * time.Millisecond,
+			},
+		})
+		defer service.WaitForCompletion()
+
+		result := service.checkLinkStatus(server.URL, nil)
+		if result.Status != models.StatusAvailable {
+			t.Errorf("Expected available after retries, got %+v", result)
+		}
+		if result.Attempts != 3 {
+			t.Errorf("Expected 3 attempts, got %d", result.Attempts)
+		}
+
+		atomic.StoreInt32(&calls, -10)
+		result = service.checkLinkStatus(server.URL, nil)
+		if result.Status != models.StatusUnavailable || result.Attempts != 3 {
+			t.Errorf("Expected unavailable after 3 attempts, got %+v", result)
+		}
+	})
+	t.Run("checkLinkStatus sends User-Agent and custom headers", func(t *testing.T) {
+		var gotAgent, gotToken string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotAgent = r.Header.Get("User-Agent")
+			gotToken = r.Header.Get("X-Token")
+		}))
+		defer server.Close()
+
+		service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{
+			UserAgent: "links-bot/2.0",
+			Headers:   map[string]string{"X-Token": "secret"},
+		})
+		defer service.WaitForCompletion()
+
+		service.checkLinkStatus(server.URL, nil)
+		if gotAgent != "links-bot/2.0" {
+			t.Errorf("Expected configured User-Agent, got %q", gotAgent)
+		}
+		if gotToken != "secret" {
+			t.Errorf("Expected custom header, got %q", gotToken)
+		}
+
+		service.checkLinkStatus(server.URL, map[string]string{"X-Token": "per-set"})
+		if gotToken != "per-set" {
+			t.Errorf("Expected request header to override the configured one, got %q", gotToken)
+		}
+	})
+	t.Run("checkLinkStatus blocks private addresses", func(t *testing.T) {
+		internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer internal.Close()
+
+		service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{
+			SSRF: SSRFPolicy{Enabled: true},
+		})
+		defer service.WaitForCompletion()
+
+		result := service.checkLinkStatus(internal.URL, nil)
+		if result.Status != models.StatusBlocked {
+			t.Errorf("Expected blocked status, got %+v", result)
+		}
+		if result.ErrorKind != models.ErrorKindBlocked {
+			t.Errorf("Expected blocked error kind, got %q", result.ErrorKind)
+		}
+
+		allowing := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{
+			SSRF: SSRFPolicy{Enabled: true, Allow: []string{"127.0.0.1/32"}},
+		})
+		defer allowing.WaitForCompletion()
+
+		if result := allowing.checkLinkStatus(internal.URL, nil); result.Status != models.StatusAvailable {
+			t.Errorf("Expected allowed address to be checked, got %+v", result)
+		}
+	})
+	t.Run("AddLinkSet rejects invalid entries and dedupes equivalent URLs", func(t *testing.T) {
+		service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{})
+		defer service.WaitForCompletion()
+
+		result, _ := service.AddLinkSet(models.SetLinksGet{
+			Links: []string{
+				"http://does-not-exist.example.com",
+				"HTTP://Does-Not-Exist.Example.com:80/#top",
+				"ftp://x",
+				"https://exame.sdfcom",
+			},
+		})
+
+		if len(result.Answer) != 1 {
+			t.Errorf("Expected 1 checked link, got %d", len(result.Answer))
+		}
+		if _, ok := result.Answer["http://does-not-exist.example.com/"]; !ok {
+			t.Errorf("Expected normalized URL in answer, got %v", result.Answer)
+		}
+		if len(result.Rejected) != 2 {
+			t.Fatalf("Expected 2 rejected links, got %+v", result.Rejected)
+		}
+		if result.Rejected[0].URL != "ftp://x" || result.Rejected[0].Reason == "" {
+			t.Errorf("Unexpected rejected entry: %+v", result.Rejected[0])
+		}
+		if got := result.Inputs["HTTP://Does-Not-Exist.Example.com:80/#top"]; got != "http://does-not-exist.example.com/" {
+			t.Errorf("Expected submitted URL to map to its key, got %q", got)
+		}
+
+		empty, _ := service.AddLinkSet(models.SetLinksGet{Links: []string{"ftp://x"}})
+		if len(empty.Answer) != 0 || empty.ListNum != 0 {
+			t.Errorf("Expected nothing to be stored for an all-invalid set, got %+v", empty)
+		}
+	})
+	t.Run("checkLinkStatus records the redirect chain", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		})
+		mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/login", http.StatusFound)
+		})
+		mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{
+			CheckMethod: CheckHeadOnly,
+			Redirects:   RedirectPolicy{MaxHops: 3},
+		})
+		defer service.WaitForCompletion()
+
+		result := service.checkLinkStatus(server.URL+"/a", nil)
+		if result.Status != models.StatusAvailable {
+			t.Errorf("Expected available, got %+v", result)
+		}
+		expected := []models.RedirectHop{
+			{StatusCode: http.StatusMovedPermanently, Location: server.URL + "/b"},
+			{StatusCode: http.StatusFound, Location: server.URL + "/login"},
+		}
+		if !reflect.DeepEqual(result.Redirects, expected) {
+			t.Errorf("Expected chain %v, got %v", expected, result.Redirects)
+		}
+		if !reflect.DeepEqual(result.Flags, []string{models.FlagLoginRedirect}) {
+			t.Errorf("Expected login flag, got %v", result.Flags)
+		}
+
+		loop := service.checkLinkStatus(server.URL+"/loop", nil)
+		if loop.Status != models.StatusUnavailable || loop.ErrorKind != models.ErrorKindRedirects {
+			t.Errorf("Expected redirect limit failure, got %+v", loop)
+		}
+		if len(loop.Redirects) != 4 {
+			t.Errorf("Expected 4 recorded hops, got %d", len(loop.Redirects))
+		}
+
+		noFollow := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{
+			CheckMethod: CheckHeadOnly,
+			Redirects:   RedirectPolicy{Mode: RedirectNone},
+		})
+		defer noFollow.WaitForCompletion()
+
+		first := noFollow.checkLinkStatus(server.URL+"/a", nil)
+		if first.StatusCode != http.StatusMovedPermanently {
+			t.Errorf("Expected the 301 itself, got %+v", first)
+		}
+		if len(first.Redirects) != 1 || first.Redirects[0].Location != server.URL+"/b" {
+			t.Errorf("Expected the single hop to be recorded, got %v", first.Redirects)
+		}
+	})
+	t.Run("checkLinkStatus inspects TLS certificates", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		service := NewLinksService(newMockTempStorage(), newMockReliableStorage(), Config{
+			CheckMethod: CheckHeadOnly,
+		})
+		defer service.WaitForCompletion()
+
+		untrusted := service.checkLinkStatus(server.URL, nil)
+		if untrusted.Status != models.StatusDegraded || untrusted.ErrorKind != models.ErrorKindTLS {
+			t.Errorf("Expected a rejected certificate to be degraded, got %+v", untrusted)
+		}
+		if untrusted.TLS == nil || !untrusted.TLS.SelfSigned {
+			t.Errorf("Expected self-signed certificate details, got %+v", untrusted.TLS)
+		}
+
+		pool := x509.NewCertPool()
+		pool.AddCert(server.Certificate())
+		transport := service.client.Transport.(*limitedTransport).base.(*http.Transport)
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+		trusted := service.checkLinkStatus(server.URL, nil)
+		if trusted.TLS == nil || trusted.TLS.Version == "" {
+			t.Fatalf("Expected TLS details for trusted certificate, got %+v", trusted)
+		}
+		if !trusted.TLS.SANMatch {
+			t.Errorf("Expected SAN to match, got %+v", trusted.TLS)
+		}
+		if trusted.StatusCode != http.StatusOK || trusted.TLS.ExpiresSoon {
+			t.Errorf("Expected a completed request with a long-lived certificate, got %+v", trusted)
+		}
+
+		service.cfg.CertExpiryWarnDays = 365 * 100
+		expiring := service.checkLinkStatus(server.URL, nil)
+		if expiring.Status != models.StatusDegraded || !expiring.TLS.ExpiresSoon {
+			t.Errorf("Expected degraded for soon-to-expire certificate, got %+v", expiring)
+		}
+
+		plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer plain.Close()
+		broken := service.checkLinkStatus(strings.Replace(plain.URL, "http://", "https://", 1), nil)
+		if broken.Status != models.StatusUnavailable {
+			t.Errorf("Expected a failed handshake to be unavailable, got %+v", broken)
+		}
+	})
 }
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type markdownRenderer struct{}
+
+func (markdownRenderer) render(w io.Writer, r report) error {
+	text := r.text
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", text.title)
+	fmt.Fprintf(&b, "%s: %s  \n%s: %s\n\n", text.generated, r.generatedAt.Format(reportTimeLayout), text.sets, r.setNums())
+
+	summary := r.summary()
+	fmt.Fprintf(&b, "## %s\n\n", text.summary)
+	markdownHeader(&b, []string{text.metric, text.value}, "-", "-:")
+	markdownRow(&b, text.totalLinks, strconv.Itoa(summary.total))
+	for _, status := range reportStatuses {
+		markdownRow(&b, text.status(status), strconv.Itoa(summary.byStatus[status]))
+	}
+	markdownRow(&b, text.availability, formatPercent(summary.availability()))
+	b.WriteString("\n")
+
+	if len(summary.domains) > 0 {
+		fmt.Fprintf(&b, "## %s\n\n", text.byDomain)
+		markdownHeader(&b, []string{text.domain, text.links, text.up, text.down, text.availability}, "-", "-:", "-:", "-:", "-:")
+		for _, d := range summary.domains {
+			markdownRow(&b, d.domain, strconv.Itoa(d.total), strconv.Itoa(d.up), strconv.Itoa(d.total-d.up), formatPercent(d.availability()))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, set := range r.sets {
+		fmt.Fprintf(&b, "## %s\n\n", r.setHeading(set))
+		if len(set.Answer) == 0 {
+			fmt.Fprintf(&b, "%s\n\n", text.noLinks)
+			continue
+		}
+		markdownHeader(&b, []string{text.url, text.statusColumn, "HTTP", text.latency, text.errorColumn}, "-", "-", ":-:", "-:", "-")
+		for _, link := range r.links(set) {
+			result := set.Answer[link]
+			urlCell := link
+			for _, hop := range result.Redirects {
+				urlCell += fmt.Sprintf("\n→ %d %s", hop.StatusCode, hop.Location)
+			}
+			statusCell := text.status(result.Status)
+			if len(result.Flags) > 0 {
+				statusCell += "\n" + strings.Join(result.Flags, "\n")
+			}
+			markdownRow(&b, urlCell, statusCell, statusCode(result), text.latencyOf(result), errorText(result))
+		}
+		b.WriteString("\n")
+	}
+
+	if rows := r.certificates(); len(rows) > 0 {
+		fmt.Fprintf(&b, "## %s\n\n", text.certificates)
+		markdownHeader(&b, []string{text.url, text.expires, text.state, text.issuer})
+		for _, row := range rows {
+			markdownRow(&b, row.url, row.info.NotAfter.Format(dateLayout), text.certificateState(row.info), certificateIssuer(row.info))
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownHeader writes the header and delimiter rows; align gives each
+// column's delimiter, "-" when missing.
+func markdownHeader(b *strings.Builder, titles []string, align ...string) {
+	markdownRow(b, titles...)
+	b.WriteString("|")
+	for i := range titles {
+		delimiter := "-"
+		if i < len(align) {
+			delimiter = align[i]
+		}
+		b.WriteString(" " + strings.Replace(delimiter, "-", "---", 1) + " |")
+	}
+	b.WriteString("\n")
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"<", "&lt;",
+	"\n", "<br>",
+)
+
+func markdownRow(b *strings.Builder, cells ...string) {
+	b.WriteString("|")
+	for _, cell := range cells {
+		b.WriteString(" " + markdownEscaper.Replace(cell) + " |")
+	}
+	b.WriteString("\n")
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"status-links/internal/models"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	errEmptyURL          = errors.New("empty URL")
+	errUnsupportedScheme = errors.New("unsupported scheme, only http and https are checked")
+	errMissingHost       = errors.New("missing host")
+	errSingleLabelHost   = errors.New("host must be a fully qualified domain name or an IP address")
+	errUnknownTLD        = errors.New("unknown top-level domain")
+	errInvalidPort       = errors.New("invalid port")
+)
+
+// normalizeLinks normalizes every submitted URL, drops duplicates that
+// normalize to the same value and reports the entries that can not be checked.
+// inputs maps each accepted URL, as submitted, to its normalized form.
+func normalizeLinks(links []string) (valid []string, rejected []models.RejectedLink, inputs map[string]string) {
+	valid = make([]string, 0, len(links))
+	inputs = make(map[string]string, len(links))
+	seen := make(map[string]bool, len(links))
+
+	for _, link := range links {
+		normalized, err := normalizeURL(link)
+		if err != nil {
+			rejected = append(rejected, models.RejectedLink{URL: link, Reason: err.Error()})
+			continue
+		}
+		inputs[link] = normalized
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		valid = append(valid, normalized)
+	}
+	return valid, rejected, inputs
+}
+
+func normalizeURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errEmptyURL
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return "", urlErr.Err
+		}
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errUnsupportedScheme
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+
+	port := u.Port()
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", errInvalidPort
+		}
+	}
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String(), nil
+}
+
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", errMissingHost
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", err
+	}
+	ascii = strings.ToLower(ascii)
+
+	if !strings.Contains(ascii, ".") {
+		return "", errSingleLabelHost
+	}
+	suffix, icann := publicsuffix.PublicSuffix(ascii)
+	if !icann && !strings.Contains(suffix, ".") {
+		return "", errUnknownTLD
+	}
+	return ascii, nil
+}
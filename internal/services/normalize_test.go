@@ -0,0 +1,89 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	t.Run("valid URLs are normalized", func(t *testing.T) {
+		tests := []struct {
+			raw      string
+			expected string
+		}{
+			{"example.com", "https://example.com/"},
+			{"  https://Example.COM/Path?q=1#frag  ", "https://example.com/Path?q=1"},
+			{"HTTP://example.com:80/a", "http://example.com/a"},
+			{"https://example.com:443", "https://example.com/"},
+			{"https://example.com:8443/", "https://example.com:8443/"},
+			{"https://пример.рф/путь", "https://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C"},
+			{"https://bücher.de", "https://xn--bcher-kva.de/"},
+			{"http://93.184.216.34/", "http://93.184.216.34/"},
+			{"http://[2606:4700::1111]:8080/", "http://[2606:4700::1111]:8080/"},
+			{"https://user.github.io", "https://user.github.io/"},
+		}
+		for _, test := range tests {
+			got, err := normalizeURL(test.raw)
+			if err != nil {
+				t.Errorf("normalizeURL(%q) unexpected error: %v", test.raw, err)
+				continue
+			}
+			if got != test.expected {
+				t.Errorf("normalizeURL(%q) = %q, expected %q", test.raw, got, test.expected)
+			}
+		}
+	})
+
+	t.Run("invalid URLs are rejected", func(t *testing.T) {
+		tests := []struct {
+			raw      string
+			expected error
+		}{
+			{"", errEmptyURL},
+			{"   ", errEmptyURL},
+			{"ftp://x", errUnsupportedScheme},
+			{"javascript://alert(1)", errUnsupportedScheme},
+			{"https://", errMissingHost},
+			{"https://localhost", errSingleLabelHost},
+			{"https://exame.sdfcom", errUnknownTLD},
+			{"https://example.com:99999999/", errInvalidPort},
+		}
+		for _, test := range tests {
+			_, err := normalizeURL(test.raw)
+			if err != test.expected {
+				t.Errorf("normalizeURL(%q) error = %v, expected %v", test.raw, err, test.expected)
+			}
+		}
+
+		if _, err := normalizeURL("http://exa mple.com"); err == nil {
+			t.Error("Expected error for host with space")
+		}
+	})
+
+	t.Run("normalizeLinks dedupes and keeps order", func(t *testing.T) {
+		valid, rejected, inputs := normalizeLinks([]string{
+			"b.com",
+			"https://a.com",
+			"https://B.com/",
+			"ftp://c.com",
+			"https://a.com/#x",
+		})
+
+		expected := []string{"https://b.com/", "https://a.com/"}
+		if !reflect.DeepEqual(valid, expected) {
+			t.Errorf("Expected %v, got %v", expected, valid)
+		}
+		if len(rejected) != 1 || rejected[0].URL != "ftp://c.com" {
+			t.Errorf("Unexpected rejected list: %+v", rejected)
+		}
+		expectedInputs := map[string]string{
+			"b.com":            "https://b.com/",
+			"https://a.com":    "https://a.com/",
+			"https://B.com/":   "https://b.com/",
+			"https://a.com/#x": "https://a.com/",
+		}
+		if !reflect.DeepEqual(inputs, expectedInputs) {
+			t.Errorf("Expected inputs %v, got %v", expectedInputs, inputs)
+		}
+	})
+}
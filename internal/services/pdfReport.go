@@ -0,0 +1,197 @@
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"status-links/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//go:embed fonts/DejaVuSansCondensed.ttf
+var dejaVuRegular []byte
+
+//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+var dejaVuBold []byte
+
+const reportFont = "Report"
+
+type reportFonts struct {
+	regular []byte
+	bold    []byte
+}
+
+// loadReportFonts returns the embedded DejaVu fonts, which cover Latin and
+// Cyrillic, or the TTF at path for both styles, e.g. a CJK font.
+func loadReportFonts(path string) reportFonts {
+	embedded := reportFonts{regular: dejaVuRegular, bold: dejaVuBold}
+	if path == "" {
+		return embedded
+	}
+
+	raw, err := os.ReadFile(path)
+	if err == nil && !isTrueType(raw) {
+		err = errors.New("not a TrueType font")
+	}
+	if err != nil {
+		slog.Error("error in loadReportFonts, using embedded font", "file", path, "error", err)
+		return embedded
+	}
+	return reportFonts{regular: raw, bold: raw}
+}
+
+// isTrueType checks the sfnt version gofpdf can parse; it prints rather than
+// returns errors for anything else.
+func isTrueType(raw []byte) bool {
+	return bytes.HasPrefix(raw, []byte{0, 1, 0, 0}) || bytes.HasPrefix(raw, []byte("true"))
+}
+
+func (f reportFonts) newPDF() *gofpdf.Fpdf {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(reportFont, "", f.regular)
+	pdf.AddUTF8FontFromBytes(reportFont, "B", f.bold)
+	return pdf
+}
+
+type pdfRenderer struct {
+	fonts reportFonts
+}
+
+func (p pdfRenderer) render(w io.Writer, r report) error {
+	pdf := p.fonts.newPDF()
+	writeSummary(pdf, r)
+
+	pdf.AddPage()
+	for _, set := range r.sets {
+		writeSetSection(pdf, r, set)
+	}
+
+	writeCertificateSection(pdf, r)
+	return pdf.Output(w)
+}
+
+// writeSummary fills the cover page: totals by status, overall availability
+// and a per-domain breakdown over every requested set.
+func writeSummary(pdf *gofpdf.Fpdf, r report) {
+	text := r.text
+	pdf.AddPage()
+	pdf.SetFont(reportFont, "B", 16)
+	pdf.Cell(0, 10, text.title)
+	pdf.Ln(12)
+
+	pdf.SetFont(reportFont, "", 10)
+	pdf.Cell(0, 6, text.generated+": "+r.generatedAt.Format(reportTimeLayout))
+	pdf.Ln(6)
+	pdf.MultiCell(0, 6, text.sets+": "+r.setNums(), "", "L", false)
+	pdf.Ln(4)
+
+	summary := r.summary()
+	writeHeading(pdf, text.summary)
+	totals := pdfTable{pdf: pdf, columns: []pdfColumn{
+		{title: text.metric, width: 70, align: "L"},
+		{title: text.value, width: 30, align: "R"},
+	}}
+	totals.header()
+	totals.row(text.totalLinks, strconv.Itoa(summary.total))
+	for _, status := range reportStatuses {
+		totals.row(text.status(status), strconv.Itoa(summary.byStatus[status]))
+	}
+	totals.row(text.availability, formatPercent(summary.availability()))
+	pdf.Ln(6)
+
+	if len(summary.domains) == 0 {
+		return
+	}
+	writeHeading(pdf, text.byDomain)
+	domains := pdfTable{pdf: pdf, columns: []pdfColumn{
+		{title: text.domain, width: 80, align: "L"},
+		{title: text.links, width: 25, align: "R"},
+		{title: text.up, width: 25, align: "R"},
+		{title: text.down, width: 25, align: "R"},
+		{title: text.availability, width: 35, align: "R"},
+	}}
+	domains.header()
+	for _, d := range summary.domains {
+		domains.row(d.domain, strconv.Itoa(d.total), strconv.Itoa(d.up), strconv.Itoa(d.total-d.up), formatPercent(d.availability()))
+	}
+}
+
+// writeSetSection prints one set as a table headed by its number and the
+// time it was checked. Redirect hops follow the URL, flags follow the status.
+func writeSetSection(pdf *gofpdf.Fpdf, r report, set models.ProcessedLinks) {
+	text := r.text
+	writeHeading(pdf, r.setHeading(set))
+
+	if len(set.Answer) == 0 {
+		pdf.SetFont(reportFont, "", 10)
+		pdf.Cell(0, 6, text.noLinks)
+		pdf.Ln(10)
+		return
+	}
+
+	table := pdfTable{pdf: pdf, columns: []pdfColumn{
+		{title: text.url, width: 78, align: "L"},
+		{title: text.statusColumn, width: 32, align: "L"},
+		{title: "HTTP", width: 14, align: "C"},
+		{title: text.latency, width: 18, align: "R"},
+		{title: text.errorColumn, width: 48, align: "L"},
+	}}
+	table.header()
+
+	for _, link := range r.links(set) {
+		result := set.Answer[link]
+		urlCell := link
+		for _, hop := range result.Redirects {
+			urlCell += fmt.Sprintf("\n→ %d %s", hop.StatusCode, hop.Location)
+		}
+		statusCell := text.status(result.Status)
+		if len(result.Flags) > 0 {
+			statusCell += "\n" + strings.Join(result.Flags, "\n")
+		}
+		table.row(urlCell, statusCell, statusCode(result), text.latencyOf(result), errorText(result))
+	}
+	pdf.Ln(6)
+}
+
+// writeHeading keeps a heading on the same page as the first rows below it.
+func writeHeading(pdf *gofpdf.Fpdf, heading string) {
+	if !fitsPage(pdf, 8+3*tableLineHeight) {
+		pdf.AddPage()
+	}
+	pdf.SetFont(reportFont, "B", 12)
+	pdf.MultiCell(0, 7, heading, "", "L", false)
+	pdf.Ln(1)
+}
+
+func formatPercent(p float64) string {
+	return strconv.FormatFloat(p, 'f', 1, 64) + "%"
+}
+
+// writeCertificateSection lists every inspected certificate, soonest expiry
+// first, so the report doubles as a certificate audit.
+func writeCertificateSection(pdf *gofpdf.Fpdf, r report) {
+	rows := r.certificates()
+	if len(rows) == 0 {
+		return
+	}
+
+	text := r.text
+	writeHeading(pdf, text.certificates)
+	table := pdfTable{pdf: pdf, columns: []pdfColumn{
+		{title: text.url, width: 70, align: "L"},
+		{title: text.expires, width: 22, align: "C"},
+		{title: text.state, width: 43, align: "L"},
+		{title: text.issuer, width: 55, align: "L"},
+	}}
+	table.header()
+	for _, row := range rows {
+		table.row(row.url, row.info.NotAfter.Format(dateLayout), text.certificateState(row.info), certificateIssuer(row.info))
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"status-links/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPDFReport(t *testing.T) {
+	newService := func(t *testing.T, cfg Config) *LinksService {
+		t.Helper()
+		temp := newMockTempStorage()
+		temp.UploadNewData(&models.ProcessedLinks{Answer: models.LinksAnswer{
+			"https://пример.рф/путь?q=значение": {Status: models.StatusAvailable, StatusCode: 200},
+			"https://例子.测试/":                    {Status: models.StatusUnavailable, ErrorKind: models.ErrorKindTimeout, Error: "тайм-аут"},
+		}})
+		return NewLinksService(temp, newMockReliableStorage(), cfg)
+	}
+
+	t.Run("Cyrillic and IDN URLs render with the embedded font", func(t *testing.T) {
+		result := newService(t, Config{}).generateReport(models.SetNumsOfLinksGet{NumsLinks: []int{1}})
+		if result.Description != "PDF report generated successfully" {
+			t.Fatalf("Unexpected description: %s", result.Description)
+		}
+		if !bytes.Contains(result.Report, []byte("/FontFile2")) || !bytes.Contains(result.Report, []byte("/BaseFont /utf8report")) {
+			t.Error("Expected the TrueType font to be embedded")
+		}
+		if bytes.Contains(result.Report, []byte("/Helvetica")) {
+			t.Error("Expected no core font in the report")
+		}
+	})
+
+	t.Run("report language selects labels", func(t *testing.T) {
+		if got := reportTextFor(ReportLangEN).status(models.StatusDegraded); got != "Degraded" {
+			t.Errorf("Unexpected English label: %q", got)
+		}
+		if got := reportTextFor(ReportLangRU).status(models.StatusDegraded); got != "Работает с проблемами" {
+			t.Errorf("Unexpected Russian label: %q", got)
+		}
+		if got := reportTextFor("xx").title; got != reportTexts[defaultReportLang].title {
+			t.Errorf("Expected unknown languages to fall back to default, got %q", got)
+		}
+		if cfg := (Config{ReportLang: "de"}).withDefaults(); cfg.ReportLang != defaultReportLang {
+			t.Errorf("Expected the default language, got %q", cfg.ReportLang)
+		}
+	})
+
+	t.Run("an unusable font file falls back to the embedded font", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), "bad.ttf")
+		if err := os.WriteFile(bad, []byte("not a font"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if fonts := loadReportFonts(bad); !bytes.Equal(fonts.regular, dejaVuRegular) {
+			t.Error("Expected the embedded font after a bad font file")
+		}
+
+		service := newService(t, Config{ReportFontFile: filepath.Join(t.TempDir(), "missing.ttf")})
+		if result := service.generateReport(models.SetNumsOfLinksGet{NumsLinks: []int{1}}); len(result.Report) == 0 {
+			t.Errorf("Expected a PDF, got %s", result.Description)
+		}
+	})
+
+	t.Run("summary counts statuses, availability and domains", func(t *testing.T) {
+		summary := summarize([]models.ProcessedLinks{
+			{Answer: models.LinksAnswer{
+				"https://a.example/1": {Status: models.StatusAvailable},
+				"https://a.example/2": {Status: models.StatusUnavailable},
+				"https://B.example/":  {Status: models.StatusDegraded},
+			}},
+			{Answer: models.LinksAnswer{"https://a.example/3": {Status: models.StatusBlocked}}},
+		})
+
+		if summary.total != 4 || summary.up != 2 || summary.availability() != 50 {
+			t.Errorf("Unexpected totals: %+v", summary)
+		}
+		if summary.byStatus[models.StatusBlocked] != 1 {
+			t.Errorf("Expected one blocked link, got %v", summary.byStatus)
+		}
+		want := []domainSummary{{domain: "a.example", total: 3, up: 1}, {domain: "b.example", total: 1, up: 1}}
+		if !reflect.DeepEqual(summary.domains, want) {
+			t.Errorf("Expected %+v, got %+v", want, summary.domains)
+		}
+	})
+
+	t.Run("long URLs wrap inside their column", func(t *testing.T) {
+		pdf := loadReportFonts("").newPDF()
+		pdf.AddPage()
+		pdf.SetFont(reportFont, "", tableFontSize)
+
+		link := "https://пример.рф/" + strings.Repeat("segment/", 30) + "?q=1\n→ 301 https://example.com/"
+		lines := wrapText(pdf, link, 60)
+		if len(lines) < 3 {
+			t.Fatalf("Expected the URL to wrap, got %d lines", len(lines))
+		}
+		for _, line := range lines {
+			if width := pdf.GetStringWidth(line); width > 60 {
+				t.Errorf("Line %q is %.1fmm wide", line, width)
+			}
+		}
+		if strings.Join(lines, "") != strings.Replace(link, "\n", "", 1) {
+			t.Error("Expected wrapping to keep every character")
+		}
+	})
+
+	t.Run("several sets with very long URLs render", func(t *testing.T) {
+		temp := newMockTempStorage()
+		for i := 0; i < 2; i++ {
+			temp.UploadNewData(&models.ProcessedLinks{
+				Answer:    models.LinksAnswer{"https://example.com/" + strings.Repeat("x", 300): {Status: models.StatusAvailable}},
+				CheckedAt: time.Now(),
+			})
+		}
+		service := NewLinksService(temp, newMockReliableStorage(), Config{ReportLang: ReportLangEN})
+
+		result := service.generateReport(models.SetNumsOfLinksGet{NumsLinks: []int{1, 2}})
+		if len(result.Report) == 0 {
+			t.Fatalf("Expected a PDF, got %s", result.Description)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	tableFontSize   = 8
+	tableLineHeight = 4.5
+)
+
+type pdfColumn struct {
+	title string
+	width float64
+	align string
+}
+
+// pdfTable draws rows whose cells wrap inside their column, so long URLs
+// never run off the page. A row is never split across pages, and the header
+// is repeated on every page the table spans.
+type pdfTable struct {
+	pdf     *gofpdf.Fpdf
+	columns []pdfColumn
+}
+
+func (t pdfTable) header() {
+	pdf := t.pdf
+	if !fitsPage(pdf, 2*tableLineHeight) {
+		pdf.AddPage()
+	}
+	pdf.SetFont(reportFont, "B", tableFontSize)
+	pdf.SetFillColor(230, 230, 230)
+	for _, column := range t.columns {
+		pdf.CellFormat(column.width, tableLineHeight+1, column.title, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+func (t pdfTable) row(cells ...string) {
+	pdf := t.pdf
+	pdf.SetFont(reportFont, "", tableFontSize)
+
+	lines := make([][]string, len(t.columns))
+	height := 1
+	for i, column := range t.columns {
+		lines[i] = wrapText(pdf, cells[i], column.width-2*pdf.GetCellMargin())
+		height = max(height, len(lines[i]))
+	}
+	rowHeight := float64(height) * tableLineHeight
+
+	if !fitsPage(pdf, rowHeight) {
+		pdf.AddPage()
+		t.header()
+		pdf.SetFont(reportFont, "", tableFontSize)
+	}
+
+	left, y := pdf.GetXY()
+	x := left
+	for i, column := range t.columns {
+		pdf.Rect(x, y, column.width, rowHeight, "D")
+		for j, line := range lines[i] {
+			pdf.SetXY(x, y+float64(j)*tableLineHeight)
+			pdf.CellFormat(column.width, tableLineHeight, line, "", 0, column.align, false, 0, "")
+		}
+		x += column.width
+	}
+	pdf.SetXY(left, y+rowHeight)
+}
+
+func fitsPage(pdf *gofpdf.Fpdf, height float64) bool {
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	return pdf.GetY()+height <= pageHeight-bottom
+}
+
+// wrapText splits text into lines no wider than width. URLs have no spaces,
+// so a line may break at any character; explicit newlines are kept.
+func wrapText(pdf *gofpdf.Fpdf, text string, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		var line []rune
+		for _, r := range paragraph {
+			if len(line) > 0 && pdf.GetStringWidth(string(append(line, r))) > width {
+				lines = append(lines, string(line))
+				line = line[:0]
+			}
+			line = append(line, r)
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"status-links/internal/models"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	RedirectFollow = "follow"
+	RedirectNone   = "none"
+)
+
+const defaultMaxRedirects = 10
+
+var defaultParkedDomains = []string{
+	"sedoparking.com",
+	"parkingcrew.net",
+	"bodis.com",
+	"above.com",
+	"dan.com",
+	"afternic.com",
+	"hugedomains.com",
+	"parklogic.com",
+}
+
+// loginPathTokens are whole path tokens that mark a login page. Spelled-out
+// forms such as "log-in" or "sign_in" match as two adjacent tokens.
+var loginPathTokens = map[string]bool{
+	"login":        true,
+	"logon":        true,
+	"signin":       true,
+	"signon":       true,
+	"sso":          true,
+	"auth":         true,
+	"oauth":        true,
+	"oauth2":       true,
+	"authorize":    true,
+	"authenticate": true,
+}
+
+type RedirectPolicy struct {
+	Mode            string
+	MaxHops         int
+	FlagCrossDomain bool
+	ParkedDomains   []string
+}
+
+func (p RedirectPolicy) withDefaults() RedirectPolicy {
+	switch p.Mode {
+	case RedirectFollow, RedirectNone:
+	default:
+		p.Mode = RedirectFollow
+	}
+	if p.MaxHops <= 0 {
+		p.MaxHops = defaultMaxRedirects
+	}
+	if p.ParkedDomains == nil {
+		p.ParkedDomains = defaultParkedDomains
+	}
+	return p
+}
+
+type redirectsKey struct{}
+
+type redirectRecorder struct {
+	mu   sync.Mutex
+	hops []models.RedirectHop
+}
+
+func (r *redirectRecorder) add(hop models.RedirectHop) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hops = append(r.hops, hop)
+}
+
+func (r *redirectRecorder) chain() []models.RedirectHop {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.RedirectHop(nil), r.hops...)
+}
+
+func withRedirectRecorder(ctx context.Context) (context.Context, *redirectRecorder) {
+	rec := &redirectRecorder{}
+	return context.WithValue(ctx, redirectsKey{}, rec), rec
+}
+
+type tooManyRedirectsError struct {
+	limit int
+}
+
+func (e *tooManyRedirectsError) Error() string {
+	return fmt.Sprintf("stopped after %d redirects", e.limit)
+}
+
+// checkRedirect records every hop before deciding whether to follow it.
+// req.Response is the 3xx answer that led to req.
+func (p RedirectPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if rec, ok := req.Context().Value(redirectsKey{}).(*redirectRecorder); ok && req.Response != nil {
+		rec.add(models.RedirectHop{
+			StatusCode: req.Response.StatusCode,
+			Location:   req.URL.String(),
+		})
+	}
+
+	if p.Mode == RedirectNone {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > p.MaxHops {
+		return &tooManyRedirectsError{limit: p.MaxHops}
+	}
+	return nil
+}
+
+// redirectFlags marks chains worth a human look: leaving the original site,
+// ending on a login form or on a domain parking service.
+func (p RedirectPolicy) redirectFlags(original string, hops []models.RedirectHop) []string {
+	if len(hops) == 0 {
+		return nil
+	}
+
+	var flags []string
+	origin := registrableDomain(original)
+	final := hops[len(hops)-1].Location
+
+	if p.FlagCrossDomain {
+		for _, hop := range hops {
+			if registrableDomain(hop.Location) != origin {
+				flags = append(flags, models.FlagCrossDomainRedirect)
+				break
+			}
+		}
+	}
+
+	if u, err := url.Parse(final); err == nil {
+		if isLoginPath(u.Path) {
+			flags = append(flags, models.FlagLoginRedirect)
+		}
+
+		host := strings.ToLower(u.Hostname())
+		for _, parked := range p.ParkedDomains {
+			if host == parked || strings.HasSuffix(host, "."+parked) {
+				flags = append(flags, models.FlagParkedDomain)
+				break
+			}
+		}
+	}
+	return flags
+}
+
+// isLoginPath splits path into tokens on "/", "-", "_" and "." and looks for
+// a login token, so "/lessons" or "/author" are not mistaken for one.
+func isLoginPath(path string) bool {
+	tokens := strings.FieldsFunc(strings.ToLower(path), func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '.'
+	})
+	for i, token := range tokens {
+		if loginPathTokens[token] {
+			return true
+		}
+		if i+1 < len(tokens) && loginPathTokens[token+tokens[i+1]] {
+			return true
+		}
+	}
+	return false
+}
+
+func registrableDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"reflect"
+	"status-links/internal/models"
+	"testing"
+)
+
+func TestRedirectFlags(t *testing.T) {
+	policy := RedirectPolicy{FlagCrossDomain: true}.withDefaults()
+
+	tests := []struct {
+		name     string
+		original string
+		hops     []models.RedirectHop
+		expected []string
+	}{
+		{
+			name:     "no redirects",
+			original: "https://example.com/",
+			expected: nil,
+		},
+		{
+			name:     "same site redirect",
+			original: "http://example.com/",
+			hops:     []models.RedirectHop{{StatusCode: 301, Location: "https://www.example.com/"}},
+			expected: nil,
+		},
+		{
+			name:     "cross domain redirect",
+			original: "http://a.com/",
+			hops: []models.RedirectHop{
+				{StatusCode: 301, Location: "https://b.com/"},
+				{StatusCode: 302, Location: "https://c.com/start"},
+			},
+			expected: []string{models.FlagCrossDomainRedirect},
+		},
+		{
+			name:     "redirect to login page",
+			original: "https://app.example.com/dashboard",
+			hops:     []models.RedirectHop{{StatusCode: 302, Location: "https://app.example.com/Account/Login?next=/dashboard"}},
+			expected: []string{models.FlagLoginRedirect},
+		},
+		{
+			name:     "redirect to spelled-out sign in",
+			original: "https://example.com/",
+			hops:     []models.RedirectHop{{StatusCode: 302, Location: "https://example.com/users/sign_in"}},
+			expected: []string{models.FlagLoginRedirect},
+		},
+		{
+			name:     "redirect to login script",
+			original: "https://example.com/",
+			hops:     []models.RedirectHop{{StatusCode: 302, Location: "https://example.com/Login.aspx"}},
+			expected: []string{models.FlagLoginRedirect},
+		},
+		{
+			name:     "words containing sso are not login pages",
+			original: "https://example.com/",
+			hops:     []models.RedirectHop{{StatusCode: 301, Location: "https://example.com/lessons/professor"}},
+			expected: nil,
+		},
+		{
+			name:     "words containing auth are not login pages",
+			original: "https://example.com/",
+			hops:     []models.RedirectHop{{StatusCode: 301, Location: "https://example.com/blog/author/jane"}},
+			expected: nil,
+		},
+		{
+			name:     "redirect to parked domain",
+			original: "https://old-shop.com/",
+			hops:     []models.RedirectHop{{StatusCode: 302, Location: "https://www.sedoparking.com/old-shop.com"}},
+			expected: []string{models.FlagCrossDomainRedirect, models.FlagParkedDomain},
+		},
+	}
+
+	for _, test := range tests {
+		got := policy.redirectFlags(test.original, test.hops)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+
+	quiet := RedirectPolicy{FlagCrossDomain: false}.withDefaults()
+	if got := quiet.redirectFlags("http://a.com/", []models.RedirectHop{{StatusCode: 301, Location: "https://b.com/"}}); got != nil {
+		t.Errorf("Expected no cross domain flag when disabled, got %v", got)
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"status-links/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// report is the data every renderer draws from: the requested sets in
+// request order, with rows ordered by sortBy.
+type report struct {
+	sets        []models.ProcessedLinks
+	sortBy      string
+	generatedAt time.Time
+	text        reportText
+}
+
+func (r report) links(set models.ProcessedLinks) []string {
+	return set.SortedLinks(r.sortBy)
+}
+
+func (r report) summary() reportSummary {
+	return summarize(r.sets)
+}
+
+// reportRenderer writes a report in one format. Output depends only on the
+// report, so renderers can be checked against golden files.
+type reportRenderer interface {
+	render(w io.Writer, r report) error
+}
+
+func (l *LinksService) renderer(format string) reportRenderer {
+	switch format {
+	case models.FormatCSV:
+		return csvRenderer{}
+	case models.FormatJSON:
+		return jsonRenderer{}
+	case models.FormatHTML:
+		return htmlRenderer{}
+	case models.FormatMarkdown:
+		return markdownRenderer{}
+	case models.FormatXLSX:
+		return xlsxRenderer{}
+	case models.FormatJUnit:
+		return junitRenderer{}
+	case models.FormatSARIF:
+		return sarifRenderer{}
+	default:
+		return pdfRenderer{fonts: l.fonts}
+	}
+}
+
+func (l *LinksService) generateReport(set models.SetNumsOfLinksGet) *models.ListOfProcessedLinks {
+	format, ok := models.LookupFormat(set.Format)
+	if !ok {
+		return &models.ListOfProcessedLinks{
+			Description: fmt.Sprintf("Unknown report format %q", set.Format),
+		}
+	}
+	name := strings.ToUpper(format.Name)
+
+	sets, err := l.temp.FindSets(&set)
+	if err != nil {
+		return &models.ListOfProcessedLinks{
+			Description: fmt.Sprintf("Error finding keys: %v", err),
+		}
+	}
+
+	if len(*sets) == 0 {
+		return &models.ListOfProcessedLinks{
+			Description: "No data found for the provided numbers",
+			Report:      []byte{},
+		}
+	}
+
+	r := report{
+		sets:        *sets,
+		sortBy:      set.Sort,
+		generatedAt: time.Now().UTC(),
+		text:        l.text,
+	}
+	var buf bytes.Buffer
+	if err := l.renderer(format.Name).render(&buf, r); err != nil {
+		return &models.ListOfProcessedLinks{
+			Description: fmt.Sprintf("Error generating %s: %v", name, err),
+			Report:      []byte{},
+		}
+	}
+
+	summary := r.summary()
+	return &models.ListOfProcessedLinks{
+		Description: name + " report generated successfully",
+		Report:      buf.Bytes(),
+		ContentType: format.ContentType,
+		Extension:   format.Extension,
+		Total:       summary.total,
+		Failed:      summary.total - summary.up,
+	}
+}
+
+const (
+	reportTimeLayout = "2006-01-02 15:04 UTC"
+	dateLayout       = "2006-01-02"
+)
+
+func (r report) setNums() string {
+	nums := make([]string, len(r.sets))
+	for i, set := range r.sets {
+		nums[i] = strconv.Itoa(set.ListNum)
+	}
+	return strings.Join(nums, ", ")
+}
+
+// setHeading names a set and the time it was checked.
+func (r report) setHeading(set models.ProcessedLinks) string {
+	heading := fmt.Sprintf(r.text.set, set.ListNum)
+	if at := checkedAt(set); !at.IsZero() {
+		heading += " — " + fmt.Sprintf(r.text.checked, at.UTC().Format(reportTimeLayout))
+	}
+	return heading
+}
+
+func statusCode(result models.LinkResult) string {
+	if result.StatusCode > 0 {
+		return strconv.Itoa(result.StatusCode)
+	}
+	return "—"
+}
+
+func (t reportText) latencyOf(result models.LinkResult) string {
+	if result.LatencyMs > 0 {
+		return fmt.Sprintf("%d %s", result.LatencyMs, t.ms)
+	}
+	return "—"
+}
+
+// errorText leaves out HTTP status errors, the status code column already
+// shows them.
+func errorText(result models.LinkResult) string {
+	if result.ErrorKind == "" || result.ErrorKind == models.ErrorKindHTTPStatus {
+		return ""
+	}
+	return result.ErrorKind + ": " + result.Error
+}
+
+// failureReason explains in one line why a link is not plainly available:
+// its flags when degraded, otherwise the error or the HTTP status.
+func failureReason(result models.LinkResult) string {
+	if result.Status == models.StatusDegraded && len(result.Flags) > 0 {
+		return strings.Join(result.Flags, ", ")
+	}
+	if reason := errorText(result); reason != "" {
+		return reason
+	}
+	if result.StatusCode > 0 {
+		return fmt.Sprintf("HTTP %d", result.StatusCode)
+	}
+	return result.Status
+}
+
+type certificateRow struct {
+	url  string
+	info *models.TLSInfo
+}
+
+// certificates returns every inspected certificate, soonest expiry first.
+func (r report) certificates() []certificateRow {
+	var rows []certificateRow
+	for _, set := range r.sets {
+		for url, result := range set.Answer {
+			if result.TLS != nil {
+				rows = append(rows, certificateRow{url: url, info: result.TLS})
+			}
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].info.NotAfter.Equal(rows[j].info.NotAfter) {
+			return rows[i].url < rows[j].url
+		}
+		return rows[i].info.NotAfter.Before(rows[j].info.NotAfter)
+	})
+	return rows
+}
+
+func (t reportText) certificateState(info *models.TLSInfo) string {
+	state := fmt.Sprintf(t.daysLeft, info.DaysLeft)
+	switch {
+	case info.Expired:
+		state = t.expired
+	case info.ExpiresSoon:
+		state += ", " + t.expiresSoon
+	}
+	if info.SelfSigned {
+		state += ", " + t.selfSigned
+	}
+	if !info.SANMatch {
+		state += ", " + t.hostMismatch
+	}
+	return state
+}
+
+func certificateIssuer(info *models.TLSInfo) string {
+	if info.Version != "" {
+		return info.Version + ", " + info.Issuer
+	}
+	return info.Issuer
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"net/url"
+	"sort"
+	"status-links/internal/models"
+	"strings"
+	"time"
+)
+
+type domainSummary struct {
+	domain string
+	total  int
+	up     int
+}
+
+func (d domainSummary) availability() float64 {
+	return percent(d.up, d.total)
+}
+
+// reportSummary is the cover page of a report: totals over every requested
+// set plus a breakdown by domain, largest first.
+type reportSummary struct {
+	total    int
+	up       int
+	byStatus map[string]int
+	domains  []domainSummary
+}
+
+func (s reportSummary) availability() float64 {
+	return percent(s.up, s.total)
+}
+
+// isUp counts degraded links as available: they answer, only their
+// certificate or redirects are suspicious.
+func isUp(status string) bool {
+	return status == models.StatusAvailable || status == models.StatusDegraded
+}
+
+func summarize(sets []models.ProcessedLinks) reportSummary {
+	summary := reportSummary{byStatus: make(map[string]int)}
+	domains := make(map[string]*domainSummary)
+	for _, set := range sets {
+		for link, result := range set.Answer {
+			summary.total++
+			summary.byStatus[result.Status]++
+
+			name := linkDomain(link)
+			d, ok := domains[name]
+			if !ok {
+				d = &domainSummary{domain: name}
+				domains[name] = d
+			}
+			d.total++
+			if isUp(result.Status) {
+				summary.up++
+				d.up++
+			}
+		}
+	}
+
+	for _, d := range domains {
+		summary.domains = append(summary.domains, *d)
+	}
+	sort.Slice(summary.domains, func(i, j int) bool {
+		if summary.domains[i].total != summary.domains[j].total {
+			return summary.domains[i].total > summary.domains[j].total
+		}
+		return summary.domains[i].domain < summary.domains[j].domain
+	})
+	return summary
+}
+
+func linkDomain(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Hostname() == "" {
+		return link
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+// checkedAt falls back to the creation time for sets stored before the check
+// time was recorded.
+func checkedAt(set models.ProcessedLinks) time.Time {
+	if !set.CheckedAt.IsZero() {
+		return set.CheckedAt
+	}
+	return set.CreatedAt
+}
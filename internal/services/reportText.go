@@ -0,0 +1,168 @@
+package services
+
+import "status-links/internal/models"
+
+const (
+	ReportLangRU = "ru"
+	ReportLangEN = "en"
+
+	defaultReportLang = ReportLangRU
+)
+
+// reportStatuses is the order statuses are counted in on the cover page.
+var reportStatuses = []string{
+	models.StatusAvailable,
+	models.StatusDegraded,
+	models.StatusUnavailable,
+	models.StatusBlocked,
+}
+
+// reportText holds every label the human-readable reports print, per
+// language.
+type reportText struct {
+	lang      string
+	title     string
+	generated string
+	sets      string
+
+	summary      string
+	metric       string
+	value        string
+	totalLinks   string
+	availability string
+	byDomain     string
+	domain       string
+	links        string
+	up           string
+	down         string
+
+	set          string
+	checked      string
+	noLinks      string
+	url          string
+	statusColumn string
+	latency      string
+	errorColumn  string
+	ms           string
+
+	certificates string
+	expires      string
+	state        string
+	issuer       string
+	daysLeft     string
+	expired      string
+	expiresSoon  string
+	selfSigned   string
+	hostMismatch string
+
+	statuses map[string]string
+}
+
+var reportTexts = map[string]reportText{
+	ReportLangEN: {
+		lang:      ReportLangEN,
+		title:     "Links Status Report",
+		generated: "Generated",
+		sets:      "Sets",
+
+		summary:      "Summary",
+		metric:       "Metric",
+		value:        "Value",
+		totalLinks:   "Total links",
+		availability: "Availability",
+		byDomain:     "By domain",
+		domain:       "Domain",
+		links:        "Links",
+		up:           "Available",
+		down:         "Failed",
+
+		set:          "Set %d",
+		checked:      "checked %s",
+		noLinks:      "No results yet",
+		url:          "URL",
+		statusColumn: "Status",
+		latency:      "Latency",
+		errorColumn:  "Error",
+		ms:           "ms",
+
+		certificates: "Certificate Expiry",
+		expires:      "Expires",
+		state:        "State",
+		issuer:       "Issuer",
+		daysLeft:     "%d days left",
+		expired:      "EXPIRED",
+		expiresSoon:  "expires soon",
+		selfSigned:   "self-signed",
+		hostMismatch: "host mismatch",
+
+		statuses: map[string]string{
+			models.StatusAvailable:   "Available",
+			models.StatusUnavailable: "Unavailable",
+			models.StatusBlocked:     "Blocked",
+			models.StatusDegraded:    "Degraded",
+		},
+	},
+	ReportLangRU: {
+		lang:      ReportLangRU,
+		title:     "Отчёт о доступности ссылок",
+		generated: "Сформирован",
+		sets:      "Наборы",
+
+		summary:      "Сводка",
+		metric:       "Показатель",
+		value:        "Значение",
+		totalLinks:   "Всего ссылок",
+		availability: "Доступность",
+		byDomain:     "По доменам",
+		domain:       "Домен",
+		links:        "Ссылок",
+		up:           "Доступны",
+		down:         "Недоступны",
+
+		set:          "Набор №%d",
+		checked:      "проверен %s",
+		noLinks:      "Результатов пока нет",
+		url:          "URL",
+		statusColumn: "Статус",
+		latency:      "Задержка",
+		errorColumn:  "Ошибка",
+		ms:           "мс",
+
+		certificates: "Сроки действия сертификатов",
+		expires:      "Истекает",
+		state:        "Состояние",
+		issuer:       "Издатель",
+		daysLeft:     "осталось дней: %d",
+		expired:      "ИСТЁК",
+		expiresSoon:  "скоро истекает",
+		selfSigned:   "самоподписанный",
+		hostMismatch: "выдан другому хосту",
+
+		statuses: map[string]string{
+			models.StatusAvailable:   "Доступна",
+			models.StatusUnavailable: "Недоступна",
+			models.StatusBlocked:     "Заблокирована",
+			models.StatusDegraded:    "Работает с проблемами",
+		},
+	},
+}
+
+func isReportLang(lang string) bool {
+	_, ok := reportTexts[lang]
+	return ok
+}
+
+func reportTextFor(lang string) reportText {
+	if text, ok := reportTexts[lang]; ok {
+		return text
+	}
+	return reportTexts[defaultReportLang]
+}
+
+// status falls back to the unavailable label, as unknown statuses did before.
+func (t reportText) status(status string) string {
+	if label, ok := t.statuses[status]; ok {
+		return label
+	}
+	return t.statuses[models.StatusUnavailable]
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"status-links/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func goldenReport(lang string) report {
+	checked := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	return report{
+		sets: []models.ProcessedLinks{
+			{
+				ListNum:   1,
+				CheckedAt: checked,
+				Order:     []string{"https://example.com/a?x=1&y=<2>", "http://old.example.com", "https://down.example.org/a|b", "https://example.com/missing", "http://10.0.0.1/"},
+				Answer: models.LinksAnswer{
+					"https://example.com/a?x=1&y=<2>": {
+						Status: models.StatusAvailable, StatusCode: 200, LatencyMs: 120,
+						TLS: &models.TLSInfo{Version: "TLS 1.3", Issuer: "Test CA", SANMatch: true, NotAfter: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), DaysLeft: 60},
+					},
+					"http://old.example.com": {
+						Status: models.StatusDegraded, StatusCode: 200, LatencyMs: 340, FinalURL: "https://new.example.com/",
+						Redirects: []models.RedirectHop{{StatusCode: 301, Location: "https://new.example.com/"}},
+						Flags:     []string{models.FlagCrossDomainRedirect},
+					},
+					"https://down.example.org/a|b": {
+						Status: models.StatusUnavailable, ErrorKind: models.ErrorKindTimeout, Error: "context deadline exceeded",
+					},
+					"https://example.com/missing": {
+						Status: models.StatusUnavailable, StatusCode: 404, LatencyMs: 80, ErrorKind: models.ErrorKindHTTPStatus, Error: "unexpected status 404",
+					},
+					"http://10.0.0.1/": {
+						Status: models.StatusBlocked, ErrorKind: models.ErrorKindBlocked, Error: "address 10.0.0.1 is private",
+					},
+				},
+			},
+			{ListNum: 2, CreatedAt: checked, Answer: models.LinksAnswer{}},
+		},
+		generatedAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		text:        reportTextFor(lang),
+	}
+}
+
+func TestReportRenderers(t *testing.T) {
+	renderers := map[string]reportRenderer{
+		"report.csv":       csvRenderer{},
+		"report.json":      jsonRenderer{},
+		"report.html":      htmlRenderer{},
+		"report.md":        markdownRenderer{},
+		"report.xlsx.txt":  xlsxRenderer{},
+		"report.junit.xml": junitRenderer{},
+		"report.sarif":     sarifRenderer{},
+	}
+
+	for name, renderer := range renderers {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := renderer.render(&buf, goldenReport(ReportLangRU)); err != nil {
+				t.Fatalf("render failed: %v", err)
+			}
+			got := buf.Bytes()
+			if _, ok := renderer.(xlsxRenderer); ok {
+				got = dumpZip(t, got)
+			}
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file, run with -update: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s differs from %s:\n%s", name, golden, got)
+			}
+		})
+	}
+
+	t.Run("output is the same on every render", func(t *testing.T) {
+		for name, renderer := range renderers {
+			var first, second bytes.Buffer
+			renderer.render(&first, goldenReport(ReportLangEN))
+			renderer.render(&second, goldenReport(ReportLangEN))
+			if !bytes.Equal(first.Bytes(), second.Bytes()) {
+				t.Errorf("%s is not deterministic", name)
+			}
+		}
+	})
+
+	t.Run("sort applies to every format", func(t *testing.T) {
+		r := goldenReport(ReportLangEN)
+		r.sortBy = models.SortURL
+		var buf bytes.Buffer
+		if err := (csvRenderer{}).render(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(buf.String(), "\n")
+		if !strings.HasPrefix(lines[1], "1,2026-03-01T09:30:00Z,http://10.0.0.1/,") {
+			t.Errorf("Expected URL order, got %q", lines[1])
+		}
+	})
+}
+
+// dumpZip lists each entry of an archive with its contents, so XLSX golden
+// files stay readable.
+func dumpZip(t *testing.T, data []byte) []byte {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a zip archive: %v", err)
+	}
+	var b bytes.Buffer
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&b, "== %s (%s)\n%s\n", f.Name, f.Modified.UTC().Format(time.RFC3339), content)
+	}
+	return b.Bytes()
+}
+
+func TestGenerateReport(t *testing.T) {
+	temp := newMockTempStorage()
+	temp.UploadNewData(&models.ProcessedLinks{Answer: models.LinksAnswer{
+		"https://example.com":      {Status: models.StatusAvailable, StatusCode: 200},
+		"https://down.example.com": {Status: models.StatusUnavailable, ErrorKind: models.ErrorKindTimeout},
+	}})
+	service := NewLinksService(temp, newMockReliableStorage(), Config{})
+
+	t.Run("format selects the renderer", func(t *testing.T) {
+		tests := []struct {
+			format, description, contentType, extension string
+		}{
+			{"", "PDF report generated successfully", "application/pdf", "pdf"},
+			{models.FormatCSV, "CSV report generated successfully", "text/csv; charset=utf-8", "csv"},
+			{models.FormatMarkdown, "MARKDOWN report generated successfully", "text/markdown; charset=utf-8", "md"},
+			{models.FormatJUnit, "JUNIT report generated successfully", "application/xml; charset=utf-8", "xml"},
+			{models.FormatSARIF, "SARIF report generated successfully", "application/sarif+json", "sarif"},
+			{"XLSX", "XLSX report generated successfully", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"},
+		}
+		for _, tt := range tests {
+			result := service.generateReport(models.SetNumsOfLinksGet{NumsLinks: []int{1}, Format: tt.format})
+			if result.Description != tt.description || result.ContentType != tt.contentType || result.Extension != tt.extension {
+				t.Errorf("format %q: unexpected result %q %q %q", tt.format, result.Description, result.ContentType, result.Extension)
+			}
+			if len(result.Report) == 0 {
+				t.Errorf("format %q: empty report", tt.format)
+			}
+			if result.Total != 2 || result.Failed != 1 {
+				t.Errorf("format %q: expected 1 of 2 links failed, got %d of %d", tt.format, result.Failed, result.Total)
+			}
+		}
+	})
+
+	t.Run("unknown format is reported", func(t *testing.T) {
+		result := service.generateReport(models.SetNumsOfLinksGet{NumsLinks: []int{1}, Format: "docx"})
+		if len(result.Report) != 0 || !strings.Contains(result.Description, "docx") {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"log/slog"
+	"time"
+)
+
+const defaultRetentionInterval = 10 * time.Minute
+
+// RetentionPolicy limits how long and how many link sets are kept. A zero
+// MaxAge or MaxSets disables that limit.
+type RetentionPolicy struct {
+	MaxAge   time.Duration
+	MaxSets  int
+	Interval time.Duration
+}
+
+func (p RetentionPolicy) withDefaults() RetentionPolicy {
+	if p.Interval <= 0 {
+		p.Interval = defaultRetentionInterval
+	}
+	return p
+}
+
+func (p RetentionPolicy) enabled() bool {
+	return p.MaxAge > 0 || p.MaxSets > 0
+}
+
+// expireSets drops sets outside the retention policy from both storages.
+// Sets of unfinished jobs are kept until the job is done; failed jobs go
+// together with their sets.
+func (l *LinksService) expireSets() []int {
+	var cutoff time.Time
+	if l.cfg.Retention.MaxAge > 0 {
+		cutoff = time.Now().Add(-l.cfg.Retention.MaxAge)
+	}
+
+	expired := l.temp.Expire(cutoff, l.cfg.Retention.MaxSets, l.jobUnfinished)
+	if len(expired) == 0 {
+		return expired
+	}
+	l.jobsMu.Lock()
+	for _, num := range expired {
+		delete(l.jobs, num)
+	}
+	l.jobsMu.Unlock()
+
+	if err := l.reliable.DeleteSets(expired); err != nil {
+		slog.Error("error in DeleteSets", "error", err)
+	}
+	slog.Info("Expired link sets", "count", len(expired))
+	return expired
+}
+
+func (l *LinksService) expirePeriodically(interval time.Duration) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.expireSets()
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
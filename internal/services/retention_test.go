@@ -0,0 +1,121 @@
+package services
+
+import (
+	"status-links/internal/models"
+	"status-links/internal/storage"
+	"testing"
+	"time"
+)
+
+func TestRetention(t *testing.T) {
+	sets := func(createdAt ...time.Time) *[]models.ProcessedLinks {
+		result := make([]models.ProcessedLinks, len(createdAt))
+		for i, at := range createdAt {
+			result[i] = models.ProcessedLinks{
+				Answer:    models.LinksAnswer{"https://example.com/": {Status: models.StatusAvailable}},
+				ListNum:   i + 1,
+				CreatedAt: at,
+			}
+		}
+		return &result
+	}
+
+	t.Run("sets older than MaxAge are purged from both storages", func(t *testing.T) {
+		now := time.Now()
+		reliable := newMockReliableStorage()
+		reliable.allData = *sets(now.Add(-3*time.Hour), now.Add(-2*time.Hour), now)
+
+		service := NewLinksService(storage.NewTempStorage(), reliable, Config{
+			Retention: RetentionPolicy{MaxAge: time.Hour},
+		})
+		defer service.WaitForCompletion()
+
+		if len(reliable.deleted) != 2 || reliable.deleted[0] != 1 || reliable.deleted[1] != 2 {
+			t.Errorf("Expected sets 1 and 2 deleted, got %v", reliable.deleted)
+		}
+		if _, err := service.GiveLinkAnswer(models.SetNumsOfLinksGet{NumsLinks: []int{3}}); err != nil {
+			t.Errorf("Expected set 3 to be kept, got %v", err)
+		}
+	})
+
+	t.Run("only the newest MaxSets are kept", func(t *testing.T) {
+		reliable := newMockReliableStorage()
+		reliable.allData = *sets(time.Time{}, time.Time{}, time.Time{}, time.Time{})
+
+		service := NewLinksService(storage.NewTempStorage(), reliable, Config{
+			Retention: RetentionPolicy{MaxSets: 3},
+		})
+		defer service.WaitForCompletion()
+
+		if len(reliable.deleted) != 1 || reliable.deleted[0] != 1 {
+			t.Errorf("Expected only set 1 deleted, got %v", reliable.deleted)
+		}
+	})
+
+	t.Run("expired numbers are reported as expired", func(t *testing.T) {
+		reliable := newMockReliableStorage()
+		reliable.allData = *sets(time.Now().Add(-2 * time.Hour))
+
+		service := NewLinksService(storage.NewTempStorage(), reliable, Config{
+			Retention: RetentionPolicy{MaxAge: time.Hour},
+		})
+		defer service.WaitForCompletion()
+
+		if _, err := service.GiveLinkAnswer(models.SetNumsOfLinksGet{NumsLinks: []int{1}}); err != ErrExpired {
+			t.Errorf("Expected ErrExpired, got %v", err)
+		}
+		if _, err := service.GiveLinkAnswer(models.SetNumsOfLinksGet{NumsLinks: []int{2}}); err != ErrTooBigIndex {
+			t.Errorf("Expected ErrTooBigIndex for a number never handed out, got %v", err)
+		}
+	})
+
+	t.Run("numbers of purged sets are not reused after restart", func(t *testing.T) {
+		reliable := newMockReliableStorage()
+		reliable.allData = *sets(time.Now())
+		reliable.lastNum = 7
+
+		temp := storage.NewTempStorage()
+		NewLinksService(temp, reliable, Config{}).WaitForCompletion()
+
+		if temp.ReturnMaxIndex() != 7 {
+			t.Errorf("Expected counter restored to 7, got %d", temp.ReturnMaxIndex())
+		}
+	})
+
+	t.Run("sets of unfinished jobs are kept", func(t *testing.T) {
+		temp := storage.NewTempStorage()
+		service := NewLinksService(temp, newMockReliableStorage(), Config{})
+		defer service.WaitForCompletion()
+
+		temp.UploadAllData(sets(time.Now().Add(-2 * time.Hour)))
+		service.jobs[1] = newJob(1, "job-1", nil, nil, nil, nil, time.Now().Add(-2*time.Hour))
+		service.cfg.Retention = RetentionPolicy{MaxAge: time.Hour}
+
+		if expired := service.expireSets(); len(expired) != 0 {
+			t.Errorf("Expected the running job's set to be kept, got %v", expired)
+		}
+	})
+
+	t.Run("failed jobs expire with their sets", func(t *testing.T) {
+		temp := storage.NewTempStorage()
+		service := NewLinksService(temp, newMockReliableStorage(), Config{})
+		defer service.WaitForCompletion()
+
+		temp.UploadAllData(sets(time.Now().Add(-2*time.Hour), time.Now()))
+		for num := 1; num <= 2; num++ {
+			service.jobs[num] = newJob(num, "", nil, nil, nil, nil, time.Now())
+			service.jobs[num].setState(models.JobFailed, ErrJobNotDurable.Error())
+		}
+		service.cfg.Retention = RetentionPolicy{MaxAge: time.Hour}
+
+		if expired := service.expireSets(); len(expired) != 1 || expired[0] != 1 {
+			t.Fatalf("Expected only set 1 expired, got %v", expired)
+		}
+		if _, ok := service.jobs[1]; ok {
+			t.Error("Expected the failed job of set 1 to be dropped")
+		}
+		if _, ok := service.jobs[2]; !ok {
+			t.Error("Expected the failed job of set 2 to be kept")
+		}
+	})
+}
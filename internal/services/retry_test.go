@@ -0,0 +1,100 @@
+package services
+
+import (
+	"net/http"
+	"status-links/internal/models"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Run("backoff grows exponentially up to MaxDelay", func(t *testing.T) {
+		policy := RetryPolicy{
+			MaxAttempts: 10,
+			BaseDelay:   100 * time.Millisecond,
+			MaxDelay:    time.Second,
+		}.withDefaults()
+
+		expected := []time.Duration{
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			400 * time.Millisecond,
+			800 * time.Millisecond,
+			time.Second,
+			time.Second,
+		}
+		for i, want := range expected {
+			if got := policy.backoff(i + 1); got != want {
+				t.Errorf("backoff(%d) = %v, expected %v", i+1, got, want)
+			}
+		}
+		if got := policy.backoff(100); got != time.Second {
+			t.Errorf("backoff(100) = %v, expected cap %v", got, time.Second)
+		}
+	})
+
+	t.Run("jitter stays within bounds", func(t *testing.T) {
+		policy := RetryPolicy{
+			BaseDelay: 100 * time.Millisecond,
+			MaxDelay:  time.Second,
+			Jitter:    0.5,
+		}.withDefaults()
+
+		for i := 0; i < 100; i++ {
+			got := policy.backoff(1)
+			if got < 50*time.Millisecond || got > 150*time.Millisecond {
+				t.Fatalf("backoff with jitter out of range: %v", got)
+			}
+		}
+	})
+
+	t.Run("Retry-After is honored and capped", func(t *testing.T) {
+		policy := RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second}.withDefaults()
+
+		if got := policy.delay(1, 2*time.Second); got != 2*time.Second {
+			t.Errorf("Expected Retry-After of 2s, got %v", got)
+		}
+		if got := policy.delay(1, time.Minute); got != 5*time.Second {
+			t.Errorf("Expected Retry-After capped at 5s, got %v", got)
+		}
+	})
+
+	t.Run("parseRetryAfter handles seconds and dates", func(t *testing.T) {
+		now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		tests := []struct {
+			value    string
+			expected time.Duration
+		}{
+			{"", 0},
+			{"3", 3 * time.Second},
+			{"-1", 0},
+			{"soon", 0},
+			{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+			{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		}
+		for _, test := range tests {
+			if got := parseRetryAfter(test.value, now); got != test.expected {
+				t.Errorf("parseRetryAfter(%q) = %v, expected %v", test.value, got, test.expected)
+			}
+		}
+	})
+
+	t.Run("only transient results are retryable", func(t *testing.T) {
+		tests := []struct {
+			result   models.LinkResult
+			expected bool
+		}{
+			{models.LinkResult{StatusCode: 429}, true},
+			{models.LinkResult{StatusCode: 502}, true},
+			{models.LinkResult{StatusCode: 503}, true},
+			{models.LinkResult{StatusCode: 404}, false},
+			{models.LinkResult{StatusCode: 200}, false},
+		}
+		for _, test := range tests {
+			if got := retryableResult(test.result, nil); got != test.expected {
+				t.Errorf("retryableResult(%d) = %v, expected %v", test.result.StatusCode, got, test.expected)
+			}
+		}
+	})
+}
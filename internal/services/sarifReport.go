@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"status-links/internal/models"
+	"time"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool      `json:"executionSuccessful"`
+	EndTimeUTC          time.Time `json:"endTimeUtc"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties sarifProperties `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifProperties struct {
+	ListNum    int    `json:"links_num"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code,omitempty"`
+	LatencyMs  int64  `json:"latency_ms"`
+}
+
+// sarifRules maps each status worth reporting to its rule. Down links are
+// errors, degraded ones warnings; available links produce no result.
+var sarifRules = []struct {
+	status string
+	rule   sarifRule
+}{
+	{models.StatusUnavailable, sarifRule{ID: "link-unavailable", ShortDescription: sarifMessage{"Link is unavailable"}, DefaultConfiguration: sarifConfiguration{"error"}}},
+	{models.StatusBlocked, sarifRule{ID: "link-blocked", ShortDescription: sarifMessage{"Link was blocked and not checked"}, DefaultConfiguration: sarifConfiguration{"error"}}},
+	{models.StatusDegraded, sarifRule{ID: "link-degraded", ShortDescription: sarifMessage{"Link works with problems"}, DefaultConfiguration: sarifConfiguration{"warning"}}},
+}
+
+type sarifRenderer struct{}
+
+func (sarifRenderer) render(w io.Writer, r report) error {
+	run := sarifRun{
+		Tool:        sarifTool{Driver: sarifDriver{Name: "status-links"}},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: true, EndTimeUTC: r.generatedAt}},
+		Results:     []sarifResult{},
+	}
+	rules := make(map[string]sarifRule, len(sarifRules))
+	for _, entry := range sarifRules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, entry.rule)
+		rules[entry.status] = entry.rule
+	}
+
+	for _, set := range r.sets {
+		for _, link := range r.links(set) {
+			result := set.Answer[link]
+			rule, ok := rules[result.Status]
+			if !ok {
+				if isUp(result.Status) {
+					continue
+				}
+				rule = rules[models.StatusUnavailable]
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:  rule.ID,
+				Level:   rule.DefaultConfiguration.Level,
+				Message: sarifMessage{Text: link + ": " + failureReason(result)},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: link},
+				}}},
+				Properties: sarifProperties{
+					ListNum:    set.ListNum,
+					Status:     result.Status,
+					StatusCode: result.StatusCode,
+					LatencyMs:  result.LatencyMs,
+				},
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
@@ -4,7 +4,12 @@ import "status-links/internal/models"
 
 type LinkProcessor interface {
 	UploadAllUnfinishedWork() *models.AllUnfinishedWork
-	AddLinkSet(set models.SetLinksGet) *models.ProcessedLinks
+	AddLinkSet(set models.SetLinksGet) (*models.ProcessedLinks, error)
+	SubmitLinkSet(set models.SetLinksGet) (*models.JobStatus, error)
+	GetJobStatus(listNum int) (*models.JobStatus, error)
 	GiveLinkAnswer(list models.SetNumsOfLinksGet) (*models.ListOfProcessedLinks, error)
+	StartCompaction() error
+	CompactionStatus() models.CompactionStats
+	CacheStats() (models.CacheStats, error)
 	WaitForCompletion()
 }
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+type SSRFPolicy struct {
+	Enabled bool
+	Allow   []string
+	Deny    []string
+}
+
+// defaultDeniedRanges are never probed unless explicitly allowed: loopback,
+// private, link-local (incl. cloud metadata), CGNAT, multicast and reserved.
+var defaultDeniedRanges = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+type blockedAddressError struct {
+	addr netip.Addr
+}
+
+func (e *blockedAddressError) Error() string {
+	return fmt.Sprintf("address %s is not allowed", e.addr)
+}
+
+type ipGuard struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+func newIPGuard(policy SSRFPolicy) *ipGuard {
+	return &ipGuard{
+		allow: parsePrefixes(policy.Allow),
+		deny:  parsePrefixes(append(append([]string{}, defaultDeniedRanges...), policy.Deny...)),
+	}
+}
+
+func parsePrefixes(values []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				slog.Warn("Ignoring invalid address in SSRF policy", "value", value, "error", err)
+				continue
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			slog.Warn("Ignoring invalid CIDR in SSRF policy", "value", value, "error", err)
+			continue
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+func (g *ipGuard) allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range g.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range g.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// control runs after DNS resolution for every connection attempt, so the
+// check covers redirects and hosts that re-resolve to a different address.
+func (g *ipGuard) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !g.allowed(addr) {
+		return &blockedAddressError{addr: addr}
+	}
+	return nil
+}
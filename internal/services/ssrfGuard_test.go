@@ -0,0 +1,71 @@
+package services
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestIPGuard(t *testing.T) {
+	t.Run("default ranges are denied", func(t *testing.T) {
+		guard := newIPGuard(SSRFPolicy{Enabled: true})
+
+		tests := []struct {
+			addr     string
+			expected bool
+		}{
+			{"127.0.0.1", false},
+			{"10.1.2.3", false},
+			{"172.20.0.1", false},
+			{"192.168.1.1", false},
+			{"169.254.169.254", false},
+			{"100.64.0.1", false},
+			{"0.0.0.0", false},
+			{"::1", false},
+			{"fe80::1", false},
+			{"fd00::1", false},
+			{"::ffff:127.0.0.1", false},
+			{"93.184.216.34", true},
+			{"2606:4700::1111", true},
+		}
+		for _, test := range tests {
+			if got := guard.allowed(netip.MustParseAddr(test.addr)); got != test.expected {
+				t.Errorf("allowed(%s) = %v, expected %v", test.addr, got, test.expected)
+			}
+		}
+	})
+
+	t.Run("allow list overrides deny list", func(t *testing.T) {
+		guard := newIPGuard(SSRFPolicy{
+			Enabled: true,
+			Allow:   []string{"10.1.0.0/16", "127.0.0.1"},
+			Deny:    []string{"93.184.216.0/24", "not-a-cidr"},
+		})
+
+		if !guard.allowed(netip.MustParseAddr("10.1.2.3")) {
+			t.Error("Expected allowed CIDR to pass")
+		}
+		if guard.allowed(netip.MustParseAddr("10.2.0.1")) {
+			t.Error("Expected address outside allowed CIDR to stay blocked")
+		}
+		if !guard.allowed(netip.MustParseAddr("127.0.0.1")) {
+			t.Error("Expected single allowed address to pass")
+		}
+		if guard.allowed(netip.MustParseAddr("93.184.216.34")) {
+			t.Error("Expected extra deny CIDR to block")
+		}
+	})
+
+	t.Run("control rejects blocked dial addresses", func(t *testing.T) {
+		guard := newIPGuard(SSRFPolicy{Enabled: true})
+
+		if err := guard.control("tcp", "169.254.169.254:80", nil); err == nil {
+			t.Error("Expected metadata address to be rejected")
+		}
+		if err := guard.control("tcp", "[::1]:8080", nil); err == nil {
+			t.Error("Expected IPv6 loopback to be rejected")
+		}
+		if err := guard.control("tcp", "8.8.8.8:443", nil); err != nil {
+			t.Errorf("Expected public address to pass, got %v", err)
+		}
+	})
+}
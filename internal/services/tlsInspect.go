@@ -0,0 +1,86 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"status-links/internal/models"
+	"time"
+)
+
+const defaultCertExpiryWarnDays = 14
+
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// inspectCertificate describes the leaf certificate presented for host and
+// reports its problems as flags.
+func inspectCertificate(leaf *x509.Certificate, host string, now time.Time, warnDays int) (*models.TLSInfo, []string) {
+	info := &models.TLSInfo{
+		Issuer:   leaf.Issuer.String(),
+		Subject:  leaf.Subject.String(),
+		SANMatch: leaf.VerifyHostname(host) == nil,
+		NotAfter: leaf.NotAfter.UTC(),
+		DaysLeft: int(leaf.NotAfter.Sub(now).Hours() / 24),
+	}
+	info.Expired = now.After(leaf.NotAfter)
+	info.SelfSigned = isSelfSigned(leaf)
+	info.ExpiresSoon = !info.Expired && leaf.NotAfter.Sub(now) < time.Duration(warnDays)*24*time.Hour
+
+	var flags []string
+	if info.Expired {
+		flags = append(flags, models.FlagCertExpired)
+	}
+	if info.ExpiresSoon {
+		flags = append(flags, models.FlagCertExpiresSoon)
+	}
+	if info.SelfSigned {
+		flags = append(flags, models.FlagCertSelfSigned)
+	}
+	if !info.SANMatch {
+		flags = append(flags, models.FlagCertHostMismatch)
+	}
+	return info, flags
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	if cert.Issuer.String() != cert.Subject.String() {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+func inspectConnection(state *tls.ConnectionState, host string, now time.Time, warnDays int) (*models.TLSInfo, []string) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil, nil
+	}
+	info, flags := inspectCertificate(state.PeerCertificates[0], host, now, warnDays)
+	info.Version = tlsVersionNames[state.Version]
+	return info, flags
+}
+
+// certificateRejected reports whether a handshake failed only because the
+// server's certificate did not verify: expired, self-signed, issued by an
+// unknown authority or for another host.
+func certificateRejected(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verifyErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// inspectVerificationError extracts the rejected certificate from a failed
+// handshake so expired and self-signed certificates can still be audited.
+func inspectVerificationError(err error, host string, now time.Time, warnDays int) (*models.TLSInfo, []string) {
+	var verifyErr *tls.CertificateVerificationError
+	if !errors.As(err, &verifyErr) || len(verifyErr.UnverifiedCertificates) == 0 {
+		return nil, nil
+	}
+	return inspectCertificate(verifyErr.UnverifiedCertificates[0], host, now, warnDays)
+}
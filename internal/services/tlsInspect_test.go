@@ -0,0 +1,88 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"status-links/internal/models"
+	"testing"
+	"time"
+)
+
+type testIssuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCertificate(t *testing.T, host string, notAfter time.Time, parent *testIssuer) *testIssuer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	signerCert, signerKey := template, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	} else {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return &testIssuer{cert: cert, key: key}
+}
+
+func TestInspectCertificate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("healthy certificate has no flags", func(t *testing.T) {
+		issuer := newTestCertificate(t, "Test CA", now.AddDate(5, 0, 0), nil)
+		leaf := newTestCertificate(t, "example.com", now.AddDate(0, 3, 0), issuer)
+
+		info, flags := inspectCertificate(leaf.cert, "example.com", now, 14)
+		if len(flags) != 0 {
+			t.Errorf("Expected no flags, got %v", flags)
+		}
+		if !info.SANMatch || info.SelfSigned || info.Expired || info.ExpiresSoon {
+			t.Errorf("Unexpected info: %+v", info)
+		}
+		if info.DaysLeft < 89 || info.DaysLeft > 92 {
+			t.Errorf("Expected about 91 days left, got %d", info.DaysLeft)
+		}
+	})
+
+	t.Run("problems are flagged", func(t *testing.T) {
+		soon := newTestCertificate(t, "example.com", now.AddDate(0, 0, 5), nil)
+		_, flags := inspectCertificate(soon.cert, "other.com", now, 14)
+		expected := []string{models.FlagCertExpiresSoon, models.FlagCertSelfSigned, models.FlagCertHostMismatch}
+		if !reflect.DeepEqual(flags, expected) {
+			t.Errorf("Expected %v, got %v", expected, flags)
+		}
+
+		expired := newTestCertificate(t, "example.com", now.AddDate(0, 0, -1), nil)
+		info, flags := inspectCertificate(expired.cert, "example.com", now, 14)
+		if !info.Expired || info.ExpiresSoon {
+			t.Errorf("Expected expired and not expiring soon, got %+v", info)
+		}
+		if flags[0] != models.FlagCertExpired {
+			t.Errorf("Expected expired flag first, got %v", flags)
+		}
+	})
+}
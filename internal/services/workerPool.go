@@ -0,0 +1,70 @@
+package services
+
+import "sync"
+
+const defaultWorkers = 32
+
+// workerPool runs link checks on a fixed set of goroutines shared by every
+// request handled by the service, so the total number of outbound checks is
+// bounded regardless of how many sets are processed at once.
+type workerPool struct {
+	tasks   chan func()
+	workers sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+}
+
+func newWorkerPool(size int) *workerPool {
+	if size <= 0 {
+		size = defaultWorkers
+	}
+	p := &workerPool{
+		tasks: make(chan func()),
+	}
+	p.workers.Add(size)
+	for i := 0; i < size; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *workerPool) worker() {
+	defer p.workers.Done()
+	for task := range p.tasks {
+		task()
+	}
+}
+
+// run calls fn for every index in [0, n) on the pool and blocks until all
+// calls have returned. After close the calls are executed inline.
+func (p *workerPool) run(n int, fn func(i int)) {
+	var done sync.WaitGroup
+	done.Add(n)
+
+	p.mu.RLock()
+	for i := 0; i < n; i++ {
+		task := func() {
+			defer done.Done()
+			fn(i)
+		}
+		if p.closed {
+			task()
+			continue
+		}
+		p.tasks <- task
+	}
+	p.mu.RUnlock()
+
+	done.Wait()
+}
+
+// close stops accepting tasks and waits for the workers to drain.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+	p.workers.Wait()
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	t.Run("run calls fn for every index", func(t *testing.T) {
+		pool := newWorkerPool(4)
+		defer pool.close()
+
+		results := make([]int, 50)
+		pool.run(len(results), func(i int) {
+			results[i] = i * 2
+		})
+
+		for i, v := range results {
+			if v != i*2 {
+				t.Errorf("Expected results[%d] = %d, got %d", i, i*2, v)
+			}
+		}
+	})
+
+	t.Run("concurrency is bounded across callers", func(t *testing.T) {
+		pool := newWorkerPool(3)
+		defer pool.close()
+
+		var current, peak int32
+		var wg sync.WaitGroup
+		for c := 0; c < 5; c++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pool.run(10, func(i int) {
+					n := atomic.AddInt32(&current, 1)
+					for {
+						p := atomic.LoadInt32(&peak)
+						if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+							break
+						}
+					}
+					time.Sleep(2 * time.Millisecond)
+					atomic.AddInt32(&current, -1)
+				})
+			}()
+		}
+		wg.Wait()
+
+		if peak > 3 {
+			t.Errorf("Expected at most 3 concurrent tasks, got %d", peak)
+		}
+	})
+
+	t.Run("run after close executes inline", func(t *testing.T) {
+		pool := newWorkerPool(2)
+		pool.close()
+
+		calls := 0
+		pool.run(3, func(i int) {
+			calls++
+		})
+
+		if calls != 3 {
+			t.Errorf("Expected 3 calls after close, got %d", calls)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const xlsxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// xlsxParts are the fixed parts of a two-sheet workbook: Summary and Results.
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet2.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Summary" sheetId="1" r:id="rId1"/><sheet name="Results" sheetId="2" r:id="rId2"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>` +
+		`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+		`</styleSheet>`},
+}
+
+// xlsxNumeric marks the resultColumns written as numbers rather than text.
+var xlsxNumeric = map[string]bool{"links_num": true, "status_code": true, "latency_ms": true}
+
+// xlsxRenderer writes the CSV columns as a spreadsheet, with a summary sheet
+// in front. Entries carry the report time, so the same report gives the
+// same bytes.
+type xlsxRenderer struct{}
+
+func (xlsxRenderer) render(w io.Writer, r report) error {
+	summary := r.summary()
+	summarySheet := [][]xlsxCell{
+		{xlsxBold("metric"), xlsxBold("value")},
+		{xlsxText("total"), xlsxNumber(strconv.Itoa(summary.total))},
+		{xlsxText("up"), xlsxNumber(strconv.Itoa(summary.up))},
+	}
+	for _, status := range reportStatuses {
+		summarySheet = append(summarySheet, []xlsxCell{xlsxText(status), xlsxNumber(strconv.Itoa(summary.byStatus[status]))})
+	}
+	summarySheet = append(summarySheet,
+		[]xlsxCell{xlsxText("availability"), xlsxNumber(strconv.FormatFloat(roundPercent(summary.availability()), 'f', -1, 64))},
+		nil,
+		[]xlsxCell{xlsxBold("domain"), xlsxBold("total"), xlsxBold("up"), xlsxBold("availability")},
+	)
+	for _, d := range summary.domains {
+		summarySheet = append(summarySheet, []xlsxCell{
+			xlsxText(d.domain),
+			xlsxNumber(strconv.Itoa(d.total)),
+			xlsxNumber(strconv.Itoa(d.up)),
+			xlsxNumber(strconv.FormatFloat(roundPercent(d.availability()), 'f', -1, 64)),
+		})
+	}
+
+	header := make([]xlsxCell, len(resultColumns))
+	for i, column := range resultColumns {
+		header[i] = xlsxBold(column)
+	}
+	resultsSheet := [][]xlsxCell{header}
+	for _, row := range r.resultRows() {
+		cells := make([]xlsxCell, len(row))
+		for i, value := range row {
+			if xlsxNumeric[resultColumns[i]] && value != "" {
+				cells[i] = xlsxNumber(value)
+			} else {
+				cells[i] = xlsxText(value)
+			}
+		}
+		resultsSheet = append(resultsSheet, cells)
+	}
+
+	archive := zip.NewWriter(w)
+	create := func(name string) (io.Writer, error) {
+		return archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: r.generatedAt})
+	}
+	for _, part := range xlsxParts {
+		f, err := create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xlsxHeader+part.body); err != nil {
+			return err
+		}
+	}
+	for i, sheet := range [][][]xlsxCell{summarySheet, resultsSheet} {
+		f, err := create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xlsxSheet(sheet)); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+type xlsxCell struct {
+	value  string
+	number bool
+	bold   bool
+}
+
+func xlsxText(value string) xlsxCell   { return xlsxCell{value: value} }
+func xlsxBold(value string) xlsxCell   { return xlsxCell{value: value, bold: true} }
+func xlsxNumber(value string) xlsxCell { return xlsxCell{value: value, number: true} }
+
+// xlsxSheet writes strings inline, so the workbook needs no shared strings
+// table.
+func xlsxSheet(rows [][]xlsxCell) string {
+	var b strings.Builder
+	b.WriteString(xlsxHeader)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		for j, cell := range row {
+			ref := xlsxColumn(j) + strconv.Itoa(i+1)
+			style := ""
+			if cell.bold {
+				style = ` s="1"`
+			}
+			if cell.number {
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, style, cell.value)
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+			xml.EscapeText(&b, []byte(cell.value))
+			b.WriteString(`</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// xlsxColumn turns a zero-based index into a column name: A, B, ..., Z, AA.
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const backupGenerations = 3
+
+// backupInterval is how old the newest backup must be before a write rotates
+// in a new one. Rotating on every write would leave only the last few
+// writes, seconds apart, to fall back on.
+var backupInterval = 10 * time.Minute
+
+func backupName(filename string, generation int) string {
+	return fmt.Sprintf("%s.bak.%d", filename, generation)
+}
+
+// writeFileAtomic replaces filename so that readers and a crash at any point
+// see either the old or the new content: temp file, fsync, rename, dir fsync.
+// The previous content becomes the newest backup generation once the current
+// one is backupInterval old.
+func writeFileAtomic(filename string, write func(w io.Writer) error) error {
+	return replaceFile(filename, write, backupDue(filename, time.Now()))
+}
+
+func backupDue(filename string, now time.Time) bool {
+	info, err := os.Stat(backupName(filename, 1))
+	if err != nil {
+		return true
+	}
+	return now.Sub(info.ModTime()) >= backupInterval
+}
+
+func replaceFile(filename string, write func(w io.Writer) error, keepBackup bool) error {
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := tmp.Chmod(0o644); err != nil {
+		return fail(err)
+	}
+	if err := write(tmp); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if keepBackup {
+		if err := rotateBackups(filename); err != nil {
+			os.Remove(tmpName)
+			return fmt.Errorf("failed to rotate backups: %w", err)
+		}
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return syncDir(dir)
+}
+
+func rotateBackups(filename string) error {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+
+	for gen := backupGenerations; gen > 1; gen-- {
+		err := os.Rename(backupName(filename, gen-1), backupName(filename, gen))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	newest := backupName(filename, 1)
+	os.Remove(newest)
+	if err := os.Link(filename, newest); err == nil {
+		return nil
+	}
+	return copyFile(filename, newest)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func decodeJSONFile(filename string, keys *Keyring, v any) error {
+	data, err := readSealedFile(filename, keys)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// recoverJSONFile makes sure filename holds a decodable document before the
+// storage starts using it. A broken file is kept as filename.corrupt and
+// replaced by the newest backup that still decodes, or by empty when none
+// does; a missing file is simply created empty. newValue returns a fresh
+// pointer of the document's type.
+func recoverJSONFile(filename string, keys *Keyring, newValue func() any, empty any) error {
+	removeStaleTemps(filename)
+
+	err := decodeJSONFile(filename, keys, newValue())
+	if err == nil {
+		return nil
+	}
+
+	if os.IsNotExist(err) {
+		return writeFileAtomic(filename, keys.sealWriter(func(w io.Writer) error {
+			return encodeJSON(w, empty)
+		}))
+	}
+
+	slog.Error("Storage file is corrupted", "file", filename, "error", err)
+	if err := os.Rename(filename, filename+".corrupt"); err != nil {
+		return fmt.Errorf("failed to keep corrupt copy of %q: %w", filename, err)
+	}
+
+	for gen := 1; gen <= backupGenerations; gen++ {
+		backup := backupName(filename, gen)
+		if err := decodeJSONFile(backup, keys, newValue()); err != nil {
+			if !os.IsNotExist(err) {
+				slog.Warn("Skipping unusable backup", "file", backup, "error", err)
+			}
+			continue
+		}
+		if err := restoreBackup(backup, filename); err != nil {
+			return fmt.Errorf("failed to restore %q from backup: %w", filename, err)
+		}
+		slog.Warn("Storage file restored from backup", "file", filename, "backup", backup)
+		return syncDir(filepath.Dir(filename))
+	}
+
+	slog.Error("No usable backup found, starting with empty storage file", "file", filename)
+	return writeFileAtomic(filename, keys.sealWriter(func(w io.Writer) error {
+		return encodeJSON(w, empty)
+	}))
+}
+
+func restoreBackup(backup, filename string) error {
+	tmpName := filename + ".tmp-restore"
+	if err := copyFile(backup, tmpName); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, filename)
+}
+
+func removeStaleTemps(filename string) {
+	matches, err := filepath.Glob(filename + ".tmp-*")
+	if err != nil {
+		return
+	}
+	for _, name := range matches {
+		os.Remove(name)
+	}
+}
+
+func encodeJSON(w io.Writer, data any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(data)
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"status-links/internal/models"
+	"testing"
+	"time"
+)
+
+func TestAtomicJSONFiles(t *testing.T) {
+	// Rotate on every write unless a test says otherwise, so a few writes
+	// fill every backup generation.
+	defaultInterval := backupInterval
+	backupInterval = 0
+	t.Cleanup(func() { backupInterval = defaultInterval })
+
+	newStorage := func(t *testing.T) (*reliableStorageJsonFile, string) {
+		dir := t.TempDir()
+		all := filepath.Join(dir, "all.json")
+		return NewReliableStorage(all, filepath.Join(dir, "links.json"), filepath.Join(dir, "nums.json")), all
+	}
+
+	t.Run("writes leave no temp files and keep backups", func(t *testing.T) {
+		s, all := newStorage(t)
+		for i := 1; i <= backupGenerations+2; i++ {
+			if err := s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: i}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		temps, _ := filepath.Glob(all + ".tmp-*")
+		if len(temps) != 0 {
+			t.Errorf("Expected no temp files, got %v", temps)
+		}
+		for gen := 1; gen <= backupGenerations; gen++ {
+			if _, err := os.Stat(backupName(all, gen)); err != nil {
+				t.Errorf("Expected backup generation %d: %v", gen, err)
+			}
+		}
+		if _, err := os.Stat(backupName(all, backupGenerations+1)); !os.IsNotExist(err) {
+			t.Errorf("Expected at most %d backup generations", backupGenerations)
+		}
+
+		var newest AllTasksNums
+		if err := decodeJSONFile(backupName(all, 1), nil, &newest); err != nil {
+			t.Fatal(err)
+		}
+		if len(newest.DataAn) != backupGenerations+1 {
+			t.Errorf("Expected newest backup to hold the previous state, got %d sets", len(newest.DataAn))
+		}
+	})
+
+	t.Run("backups rotate only once the newest is old enough", func(t *testing.T) {
+		backupInterval = time.Hour
+		defer func() { backupInterval = 0 }()
+
+		s, all := newStorage(t)
+		for i := 1; i <= 5; i++ {
+			if err := s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: i}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if _, err := os.Stat(backupName(all, 2)); !os.IsNotExist(err) {
+			t.Fatalf("Expected a single backup generation within the interval")
+		}
+		var newest AllTasksNums
+		if err := decodeJSONFile(backupName(all, 1), nil, &newest); err != nil {
+			t.Fatal(err)
+		}
+		if len(newest.DataAn) != 0 {
+			t.Errorf("Expected the backup to predate the recent writes, got %d sets", len(newest.DataAn))
+		}
+
+		old := time.Now().Add(-2 * time.Hour)
+		if err := os.Chtimes(backupName(all, 1), old, old); err != nil {
+			t.Fatal(err)
+		}
+		s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 6})
+		if err := decodeJSONFile(backupName(all, 1), nil, &newest); err != nil {
+			t.Fatal(err)
+		}
+		if len(newest.DataAn) != 5 {
+			t.Errorf("Expected an overdue backup to rotate, got %d sets", len(newest.DataAn))
+		}
+		if _, err := os.Stat(backupName(all, 2)); err != nil {
+			t.Errorf("Expected the previous backup to move down a generation: %v", err)
+		}
+	})
+
+	corruptions := map[string][]byte{
+		"zero length":  {},
+		"half written": []byte(`{"processed_data": [{"links": {}, "links_num": 1}, {"li`),
+	}
+	for name, content := range corruptions {
+		t.Run("corrupted file falls back to backup: "+name, func(t *testing.T) {
+			s, all := newStorage(t)
+			s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 1})
+			s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 2})
+
+			if err := os.WriteFile(all, content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			dir := filepath.Dir(all)
+			restored := NewReliableStorage(all, filepath.Join(dir, "links.json"), filepath.Join(dir, "nums.json"))
+			data, err := restored.ReadAllFile()
+			if err != nil {
+				t.Fatalf("Unexpected error after recovery: %v", err)
+			}
+			if len(*data) != 1 || (*data)[0].ListNum != 1 {
+				t.Errorf("Expected state from the newest backup, got %+v", *data)
+			}
+
+			kept, err := os.ReadFile(all + ".corrupt")
+			if err != nil {
+				t.Fatalf("Expected corrupt copy to be kept: %v", err)
+			}
+			if string(kept) != string(content) {
+				t.Errorf("Corrupt copy differs from the broken file")
+			}
+		})
+	}
+
+	t.Run("corrupted backups are skipped", func(t *testing.T) {
+		s, all := newStorage(t)
+		for i := 1; i <= 3; i++ {
+			s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: i})
+		}
+		os.WriteFile(all, []byte("{"), 0o644)
+		os.WriteFile(backupName(all, 1), []byte("nope"), 0o644)
+
+		dir := filepath.Dir(all)
+		restored := NewReliableStorage(all, filepath.Join(dir, "links.json"), filepath.Join(dir, "nums.json"))
+		data, _ := restored.ReadAllFile()
+		if len(*data) != 1 {
+			t.Errorf("Expected state from the second backup generation, got %+v", *data)
+		}
+	})
+
+	t.Run("no usable backup starts empty", func(t *testing.T) {
+		dir := t.TempDir()
+		all := filepath.Join(dir, "all.json")
+		os.WriteFile(all, []byte("garbage"), 0o644)
+		os.WriteFile(all+".tmp-123", []byte("leftover"), 0o644)
+
+		s := NewReliableStorage(all, filepath.Join(dir, "links.json"), filepath.Join(dir, "nums.json"))
+		data, err := s.ReadAllFile()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(*data) != 0 {
+			t.Errorf("Expected empty storage, got %+v", *data)
+		}
+		if _, err := os.Stat(all + ".corrupt"); err != nil {
+			t.Errorf("Expected corrupt copy: %v", err)
+		}
+		if _, err := os.Stat(all + ".tmp-123"); !os.IsNotExist(err) {
+			t.Errorf("Expected stale temp file to be removed")
+		}
+	})
+}
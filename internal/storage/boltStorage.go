@@ -0,0 +1,498 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"status-links/internal/models"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltSetsBucket      = []byte("sets")
+	boltPendingLinks    = []byte("pending_links")
+	boltPendingNums     = []byte("pending_nums")
+	boltURLSetsIndex    = []byte("url_sets")
+	boltURLPendingIndex = []byte("url_pending")
+	boltMetaBucket      = []byte("meta")
+	boltLastNumKey      = []byte("last_num")
+)
+
+var ErrStorageNotEmpty = errors.New("destination storage is not empty")
+
+// boltStorage keeps link sets keyed by big-endian ListNum and pending tasks
+// keyed by task ID in a single bbolt file. The url_* buckets index both by
+// URL: their keys are url, a zero byte and the set or task key.
+type boltStorage struct {
+	db *bolt.DB
+}
+
+func NewBoltStorage(path string) (*boltStorage, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltSetsBucket, boltPendingLinks, boltPendingNums, boltURLSetsIndex, boltURLPendingIndex, boltMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStorage{db: db}, nil
+}
+
+func (s *boltStorage) Close() error {
+	return s.db.Close()
+}
+
+func boltKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+func urlIndexKey(url string, key []byte) []byte {
+	return append(append([]byte(url), 0), key...)
+}
+
+func (s *boltStorage) ReadAllFile() (*[]models.ProcessedLinks, error) {
+	data := make([]models.ProcessedLinks, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSetsBucket).ForEach(func(_, v []byte) error {
+			var set models.ProcessedLinks
+			if err := json.Unmarshal(v, &set); err != nil {
+				return err
+			}
+			data = append(data, set)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (s *boltStorage) ReadSet(listNum int) (*models.ProcessedLinks, error) {
+	var set models.ProcessedLinks
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltSetsBucket).Get(boltKey(uint64(listNum)))
+		if v == nil {
+			return os.ErrNotExist
+		}
+		return json.Unmarshal(v, &set)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// ListSets decodes only the creation time of each set, so the answers are
+// never held in memory.
+func (s *boltStorage) ListSets() (map[int]time.Time, error) {
+	created := make(map[int]time.Time)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSetsBucket).ForEach(func(k, v []byte) error {
+			var head setHead
+			if err := json.Unmarshal(v, &head); err != nil {
+				return err
+			}
+			created[int(binary.BigEndian.Uint64(k))] = head.CreatedAt
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (s *boltStorage) AddNewLinkPerm(item *models.ProcessedLinks) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putSet(tx, item)
+	})
+}
+
+func putSet(tx *bolt.Tx, item *models.ProcessedLinks) error {
+	value, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	key := boltKey(uint64(item.ListNum))
+	if err := tx.Bucket(boltSetsBucket).Put(key, value); err != nil {
+		return err
+	}
+	index := tx.Bucket(boltURLSetsIndex)
+	for url := range item.Answer {
+		if err := index.Put(urlIndexKey(url, key), nil); err != nil {
+			return err
+		}
+	}
+
+	meta := tx.Bucket(boltMetaBucket)
+	if uint64(item.ListNum) <= lastNum(meta) {
+		return nil
+	}
+	return meta.Put(boltLastNumKey, key)
+}
+
+func lastNum(meta *bolt.Bucket) uint64 {
+	if v := meta.Get(boltLastNumKey); v != nil {
+		return binary.BigEndian.Uint64(v)
+	}
+	return 0
+}
+
+func (s *boltStorage) LastListNum() (int, error) {
+	var num int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		num = int(lastNum(tx.Bucket(boltMetaBucket)))
+		return tx.Bucket(boltPendingLinks).ForEach(func(_, v []byte) error {
+			var task ProcessTasksLinks
+			if err := json.Unmarshal(v, &task); err != nil {
+				return err
+			}
+			num = max(num, task.ListNum)
+			return nil
+		})
+	})
+	return num, err
+}
+
+// DeleteSets removes the sets and their URL index entries. The last_num
+// counter is kept, so the numbers stay used.
+func (s *boltStorage) DeleteSets(listNums []int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		sets := tx.Bucket(boltSetsBucket)
+		index := tx.Bucket(boltURLSetsIndex)
+		for _, num := range listNums {
+			key := boltKey(uint64(num))
+			v := sets.Get(key)
+			if v == nil {
+				continue
+			}
+			var set models.ProcessedLinks
+			if err := json.Unmarshal(v, &set); err != nil {
+				return err
+			}
+			for url := range set.Answer {
+				if err := index.Delete(urlIndexKey(url, key)); err != nil {
+					return err
+				}
+			}
+			if err := sets.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindSetsByURL returns the ListNums of every stored set that checked url.
+func (s *boltStorage) FindSetsByURL(url string) ([]int, error) {
+	result := make([]int, 0)
+	err := s.scanURLIndex(boltURLSetsIndex, url, func(key []byte) {
+		result = append(result, int(binary.BigEndian.Uint64(key)))
+	})
+	return result, err
+}
+
+// FindPendingByURL returns the IDs of pending link tasks that contain url.
+func (s *boltStorage) FindPendingByURL(url string) ([]string, error) {
+	result := make([]string, 0)
+	err := s.scanURLIndex(boltURLPendingIndex, url, func(key []byte) {
+		result = append(result, string(key))
+	})
+	return result, err
+}
+
+func (s *boltStorage) scanURLIndex(name []byte, url string, fn func(key []byte)) error {
+	prefix := append([]byte(url), 0)
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(name).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			fn(k[len(prefix):])
+		}
+		return nil
+	})
+}
+
+func (s *boltStorage) AddLinksProcessList(masLinks *models.SetLinksGet, owner string) (string, error) {
+	return s.addLinksTask(ProcessTasksLinks{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
+	})
+}
+
+func (s *boltStorage) AddLinksJob(listNum int, masLinks *models.SetLinksGet, owner string) (string, error) {
+	return s.addLinksTask(ProcessTasksLinks{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
+		ListNum:     listNum,
+	})
+}
+
+func (s *boltStorage) addLinksTask(task ProcessTasksLinks) (string, error) {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return putLinksTask(tx, task)
+	}); err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+func putLinksTask(tx *bolt.Tx, task ProcessTasksLinks) error {
+	value, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	key := []byte(task.ID)
+	if err := tx.Bucket(boltPendingLinks).Put(key, value); err != nil {
+		return err
+	}
+	index := tx.Bucket(boltURLPendingIndex)
+	for _, url := range task.Data.Links {
+		if err := index.Put(urlIndexKey(url, key), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *boltStorage) AddNumProcessList(masLinks *models.SetNumsOfLinksGet, owner string) (string, error) {
+	task := ProcessTasksNums{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
+	}
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return putNumsTask(tx, task)
+	}); err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+func putNumsTask(tx *bolt.Tx, task ProcessTasksNums) error {
+	value, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltPendingNums).Put([]byte(task.ID), value)
+}
+
+func deleteLinksTask(tx *bolt.Tx, task ProcessTasksLinks) error {
+	key := []byte(task.ID)
+	index := tx.Bucket(boltURLPendingIndex)
+	for _, url := range task.Data.Links {
+		if err := index.Delete(urlIndexKey(url, key)); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(boltPendingLinks).Delete(key)
+}
+
+func (s *boltStorage) RemoveLinksTask(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltPendingLinks).Get([]byte(id))
+		if v == nil {
+			return os.ErrNotExist
+		}
+		var task ProcessTasksLinks
+		if err := json.Unmarshal(v, &task); err != nil {
+			return err
+		}
+		return deleteLinksTask(tx, task)
+	})
+}
+
+func (s *boltStorage) RemoveNumsTask(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(boltPendingNums)
+		if pending.Get([]byte(id)) == nil {
+			return os.ErrNotExist
+		}
+		return pending.Delete([]byte(id))
+	})
+}
+
+func (s *boltStorage) GetPendingLinksData() ([]models.SetLinksGet, error) {
+	result := make([]models.SetLinksGet, 0)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var taken []ProcessTasksLinks
+		err := tx.Bucket(boltPendingLinks).ForEach(func(_, v []byte) error {
+			var task ProcessTasksLinks
+			if err := json.Unmarshal(v, &task); err != nil {
+				return err
+			}
+			if task.ListNum == 0 {
+				taken = append(taken, task)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, task := range taken {
+			if err := deleteLinksTask(tx, task); err != nil {
+				return err
+			}
+			result = append(result, task.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *boltStorage) ClaimPendingJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	return s.claimJobs(owner, func(task *ProcessTasksLinks) bool {
+		return claimable(task, owner, staleBefore)
+	})
+}
+
+func (s *boltStorage) TakeOverJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	return s.claimJobs(owner, func(task *ProcessTasksLinks) bool {
+		return abandoned(task, owner, staleBefore)
+	})
+}
+
+func (s *boltStorage) claimJobs(owner string, match func(*ProcessTasksLinks) bool) ([]models.LinksJob, error) {
+	var result []models.LinksJob
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(boltPendingLinks)
+		var matched []ProcessTasksLinks
+		err := pending.ForEach(func(_, v []byte) error {
+			var task ProcessTasksLinks
+			if err := json.Unmarshal(v, &task); err != nil {
+				return err
+			}
+			if match(&task) {
+				matched = append(matched, task)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		result = claimMatching(matched, owner, claimClock().UTC(), func(*ProcessTasksLinks) bool { return true })
+		for _, task := range matched {
+			value, err := json.Marshal(task)
+			if err != nil {
+				return err
+			}
+			if err := pending.Put([]byte(task.ID), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *boltStorage) GetPendingNumsData() ([]models.SetNumsOfLinksGet, error) {
+	result := make([]models.SetNumsOfLinksGet, 0)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltPendingNums).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.First() {
+			var task ProcessTasksNums
+			if err := json.Unmarshal(v, &task); err != nil {
+				return err
+			}
+			result = append(result, task.Data)
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Import writes sets and pending tasks in one transaction. It refuses to run
+// against a storage that already holds data so a repeated migration cannot
+// duplicate pending tasks.
+func (s *boltStorage) Import(sets []models.ProcessedLinks, links []ProcessTasksLinks, nums []ProcessTasksNums) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltSetsBucket, boltPendingLinks, boltPendingNums} {
+			if k, _ := tx.Bucket(name).Cursor().First(); k != nil {
+				return ErrStorageNotEmpty
+			}
+		}
+
+		for i := range sets {
+			if err := putSet(tx, &sets[i]); err != nil {
+				return err
+			}
+		}
+		for _, task := range links {
+			task.upgradeLegacy()
+			if err := putLinksTask(tx, task); err != nil {
+				return err
+			}
+		}
+		for _, task := range nums {
+			task.upgradeLegacy()
+			if err := putNumsTask(tx, task); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStorage) RenewJobClaims(owner string) error {
+	now := claimClock().UTC()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(boltPendingLinks)
+		var renewed []ProcessTasksLinks
+		err := pending.ForEach(func(_, v []byte) error {
+			var task ProcessTasksLinks
+			if err := json.Unmarshal(v, &task); err != nil {
+				return err
+			}
+			if task.ListNum > 0 && task.Owner == owner {
+				task.ClaimedAt = now
+				renewed = append(renewed, task)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, task := range renewed {
+			value, err := json.Marshal(task)
+			if err != nil {
+				return err
+			}
+			if err := pending.Put([]byte(task.ID), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
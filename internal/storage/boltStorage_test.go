@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"status-links/internal/models"
+	"testing"
+	"time"
+)
+
+func TestBoltStorage(t *testing.T) {
+	openBolt := func(t *testing.T, path string) *boltStorage {
+		t.Helper()
+		s, err := NewBoltStorage(path)
+		if err != nil {
+			t.Fatalf("Unexpected error opening bolt: %v", err)
+		}
+		return s
+	}
+
+	t.Run("state survives reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "links.db")
+		s := openBolt(t, path)
+
+		for _, num := range []int{2, 1} {
+			err := s.AddNewLinkPerm(&models.ProcessedLinks{
+				Answer:  models.LinksAnswer{"https://example.com/": {Status: models.StatusAvailable}},
+				ListNum: num,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		id, _ := s.AddLinksProcessList(&models.SetLinksGet{Links: []string{"a.com"}}, "")
+		s.AddLinksJob(3, &models.SetLinksGet{Links: []string{"b.com"}}, "")
+		s.AddNumProcessList(&models.SetNumsOfLinksGet{NumsLinks: []int{1}}, "")
+		if err := s.RemoveLinksTask(id); err != nil {
+			t.Fatalf("Unexpected error removing links: %v", err)
+		}
+		s.Close()
+
+		reopened := openBolt(t, path)
+		defer reopened.Close()
+
+		data, _ := reopened.ReadAllFile()
+		if len(*data) != 2 || (*data)[0].ListNum != 1 || (*data)[1].ListNum != 2 {
+			t.Errorf("Expected sets ordered by number, got %+v", *data)
+		}
+		links, _ := reopened.GetPendingLinksData()
+		if len(links) != 0 {
+			t.Errorf("Expected removed links to stay removed, got %v", links)
+		}
+		jobs, _ := reopened.ClaimPendingJobs("", time.Time{})
+		if len(jobs) != 1 || jobs[0].ListNum != 3 {
+			t.Errorf("Expected one pending job, got %+v", jobs)
+		}
+		nums, _ := reopened.GetPendingNumsData()
+		if len(nums) != 1 {
+			t.Errorf("Expected one pending nums set, got %v", nums)
+		}
+		nums, _ = reopened.GetPendingNumsData()
+		if len(nums) != 0 {
+			t.Errorf("Expected pending nums to be consumed, got %v", nums)
+		}
+	})
+
+	t.Run("GetPendingLinksData clears sets but keeps jobs", func(t *testing.T) {
+		s := openBolt(t, filepath.Join(t.TempDir(), "links.db"))
+		defer s.Close()
+
+		s.AddLinksProcessList(&models.SetLinksGet{Links: []string{"a.com"}}, "")
+		s.AddLinksJob(3, &models.SetLinksGet{Links: []string{"b.com"}}, "")
+
+		links, err := s.GetPendingLinksData()
+		if err != nil || len(links) != 1 {
+			t.Fatalf("Expected one pending set, got %v (err %v)", links, err)
+		}
+		links, _ = s.GetPendingLinksData()
+		if len(links) != 0 {
+			t.Errorf("Expected pending sets to be cleared, got %v", links)
+		}
+		if jobs, _ := s.ClaimPendingJobs("", time.Time{}); len(jobs) != 1 {
+			t.Errorf("Expected the job to stay pending, got %+v", jobs)
+		}
+	})
+
+	t.Run("URL indexes", func(t *testing.T) {
+		s := openBolt(t, filepath.Join(t.TempDir(), "links.db"))
+		defer s.Close()
+
+		s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{"https://a.com/": {}, "https://b.com/": {}}, ListNum: 1})
+		s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{"https://a.com/": {}}, ListNum: 2})
+		s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{"https://a.com/x": {}}, ListNum: 3})
+
+		nums, err := s.FindSetsByURL("https://a.com/")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(nums) != 2 || nums[0] != 1 || nums[1] != 2 {
+			t.Errorf("Expected sets 1 and 2, got %v", nums)
+		}
+
+		id, _ := s.AddLinksProcessList(&models.SetLinksGet{Links: []string{"https://c.com/"}}, "")
+		if ids, _ := s.FindPendingByURL("https://c.com/"); len(ids) != 1 {
+			t.Errorf("Expected pending task in the index, got %v", ids)
+		}
+		s.RemoveLinksTask(id)
+		if ids, _ := s.FindPendingByURL("https://c.com/"); len(ids) != 0 {
+			t.Errorf("Expected index entry removed with the task, got %v", ids)
+		}
+	})
+
+	t.Run("pending jobs count towards the last number", func(t *testing.T) {
+		s := openBolt(t, filepath.Join(t.TempDir(), "links.db"))
+		defer s.Close()
+
+		s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 3})
+		s.AddLinksJob(7, &models.SetLinksGet{Links: []string{"a.com"}}, "node-b")
+		if last, err := s.LastListNum(); err != nil || last != 7 {
+			t.Errorf("Expected 7 from the pending job, got %d, %v", last, err)
+		}
+	})
+
+	t.Run("remove unknown task", func(t *testing.T) {
+		s := openBolt(t, filepath.Join(t.TempDir(), "links.db"))
+		defer s.Close()
+
+		if err := s.RemoveLinksTask("missing"); !os.IsNotExist(err) {
+			t.Errorf("Expected not-exist error, got %v", err)
+		}
+		if err := s.RemoveNumsTask("missing"); !os.IsNotExist(err) {
+			t.Errorf("Expected not-exist error, got %v", err)
+		}
+	})
+}
+
+func TestMigrateJSONToBolt(t *testing.T) {
+	dir := t.TempDir()
+	all := filepath.Join(dir, "all.json")
+	links := filepath.Join(dir, "links.json")
+	nums := filepath.Join(dir, "nums.json")
+
+	source := NewReliableStorage(all, links, nums)
+	source.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{"https://a.com/": {Status: models.StatusAvailable}}, ListNum: 1})
+	source.Compact()
+	source.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{"https://b.com/": {}}, ListNum: 2})
+	source.AddLinksProcessList(&models.SetLinksGet{Links: []string{"c.com"}}, "")
+	source.AddLinksJob(3, &models.SetLinksGet{Links: []string{"d.com"}}, "")
+	source.AddNumProcessList(&models.SetNumsOfLinksGet{NumsLinks: []int{1, 2}}, "")
+
+	dst := filepath.Join(dir, "links.db")
+	stats, err := MigrateJSONToBolt(all, links, nums, dst, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats != (MigrationStats{Sets: 2, PendingLinks: 2, PendingNums: 1}) {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if _, err := MigrateJSONToBolt(all, links, nums, dst, nil); err != ErrStorageNotEmpty {
+		t.Errorf("Expected second migration to be refused, got %v", err)
+	}
+
+	s, err := NewBoltStorage(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	data, _ := s.ReadAllFile()
+	if len(*data) != 2 || (*data)[0].Answer["https://a.com/"].Status != models.StatusAvailable {
+		t.Errorf("Unexpected migrated sets: %+v", *data)
+	}
+	if jobs, _ := s.ClaimPendingJobs("", time.Time{}); len(jobs) != 1 || jobs[0].ListNum != 3 {
+		t.Errorf("Unexpected migrated jobs: %+v", jobs)
+	}
+	if pending, _ := s.GetPendingLinksData(); len(pending) != 1 {
+		t.Errorf("Unexpected migrated pending links: %+v", pending)
+	}
+
+	var stillThere []ProcessTasksLinks
+	decodeJSONFile(links, nil, &stillThere)
+	if len(stillThere) != 2 {
+		t.Errorf("Expected source files to be left untouched, got %d pending links", len(stillThere))
+	}
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// sealedMagic starts every encrypted file. A JSON document never starts with
+// it, so plaintext files written before encryption was enabled still read.
+var sealedMagic = []byte("SLE1")
+
+const keyIDSize = 4
+
+var ErrUnknownKey = errors.New("file is encrypted with an unknown key")
+
+type sealKey struct {
+	id   [keyIDSize]byte
+	aead cipher.AEAD
+}
+
+// Keyring encrypts with its first key and decrypts with any of them, so a
+// key can be rotated by putting the new one first and keeping the old ones
+// until every file is re-encrypted. A nil Keyring leaves data in plaintext.
+type Keyring struct {
+	keys []sealKey
+}
+
+// NewKeyring takes raw AES keys of 16, 24 or 32 bytes, primary first.
+func NewKeyring(keys [][]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption keys given")
+	}
+	k := &Keyring{}
+	seen := make(map[[keyIDSize]byte]bool)
+	for i, raw := range keys {
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %d: %w", i+1, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %d: %w", i+1, err)
+		}
+		id := keyID(raw)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		k.keys = append(k.keys, sealKey{id: id, aead: aead})
+	}
+	return k, nil
+}
+
+// LoadKeyring builds a keyring from base64 keys and an optional key file with
+// one base64 key per line; blank lines and lines starting with # are skipped.
+// Keys from the list come before those from the file. It returns nil when no
+// key is configured.
+func LoadKeyring(encoded []string, keyFile string) (*Keyring, error) {
+	encoded = append([]string(nil), encoded...)
+	if keyFile != "" {
+		fromFile, err := readKeyFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, fromFile...)
+	}
+	if len(encoded) == 0 {
+		return nil, nil
+	}
+
+	keys := make([][]byte, 0, len(encoded))
+	for i, s := range encoded {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %d is not valid base64: %w", i+1, err)
+		}
+		keys = append(keys, raw)
+	}
+	return NewKeyring(keys)
+}
+
+func readKeyFile(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+	defer file.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, scanner.Err()
+}
+
+// keyID names a key in the file header without revealing it.
+func keyID(raw []byte) [keyIDSize]byte {
+	sum := sha256.Sum256(raw)
+	var id [keyIDSize]byte
+	copy(id[:], sum[:])
+	return id
+}
+
+func isSealed(data []byte) bool {
+	return bytes.HasPrefix(data, sealedMagic)
+}
+
+// seal encrypts data with the primary key as magic, key id, nonce and
+// ciphertext. The header is authenticated along with the content.
+func (k *Keyring) seal(data []byte) ([]byte, error) {
+	if k == nil {
+		return data, nil
+	}
+	key := k.keys[0]
+	header := append(append([]byte{}, sealedMagic...), key.id[:]...)
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(header, nonce...)
+	return key.aead.Seal(out, nonce, data, header), nil
+}
+
+// open decrypts a sealed file and passes plaintext through unchanged.
+func (k *Keyring) open(data []byte) ([]byte, error) {
+	if !isSealed(data) {
+		return data, nil
+	}
+	if k == nil {
+		return nil, errors.New("file is encrypted but no encryption key is configured")
+	}
+
+	headerSize := len(sealedMagic) + keyIDSize
+	if len(data) < headerSize {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	key, ok := k.find(data[len(sealedMagic):headerSize])
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	nonceSize := key.aead.NonceSize()
+	if len(data) < headerSize+nonceSize {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	header, nonce := data[:headerSize], data[headerSize:headerSize+nonceSize]
+	plain, err := key.aead.Open(nil, nonce, data[headerSize+nonceSize:], header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt file: %w", err)
+	}
+	return plain, nil
+}
+
+func (k *Keyring) find(id []byte) (sealKey, bool) {
+	for _, key := range k.keys {
+		if bytes.Equal(key.id[:], id) {
+			return key, true
+		}
+	}
+	return sealKey{}, false
+}
+
+// sealedByPrimary reports whether data is already encrypted with the key new
+// writes use, so re-encryption can skip it.
+func (k *Keyring) sealedByPrimary(data []byte) bool {
+	headerSize := len(sealedMagic) + keyIDSize
+	return isSealed(data) && len(data) >= headerSize &&
+		bytes.Equal(data[len(sealedMagic):headerSize], k.keys[0].id[:])
+}
+
+// sealWriter buffers what write produces and writes it sealed.
+func (k *Keyring) sealWriter(write func(w io.Writer) error) func(w io.Writer) error {
+	if k == nil {
+		return write
+	}
+	return func(w io.Writer) error {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			return err
+		}
+		sealed, err := k.seal(buf.Bytes())
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(sealed)
+		return err
+	}
+}
+
+func readSealedFile(filename string, keys *Keyring) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return keys.open(data)
+}
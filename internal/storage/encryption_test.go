@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"status-links/internal/models"
+	"testing"
+)
+
+func TestEncryptedReliableStorage(t *testing.T) {
+	newKey := func(t *testing.T) []byte {
+		t.Helper()
+		key := make([]byte, 32)
+		rand.Read(key)
+		return key
+	}
+	newKeyring := func(t *testing.T, keys ...[]byte) *Keyring {
+		t.Helper()
+		k, err := NewKeyring(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	paths := func(t *testing.T) (string, string, string) {
+		dir := t.TempDir()
+		return filepath.Join(dir, "all.json"), filepath.Join(dir, "links.json"), filepath.Join(dir, "nums.json")
+	}
+	const secret = "https://intranet.example/check?token=s3cret"
+	secretSet := &models.ProcessedLinks{
+		Answer:  models.LinksAnswer{secret: {Status: models.StatusAvailable}},
+		ListNum: 1,
+	}
+	assertNoPlaintext := func(t *testing.T, names ...string) {
+		t.Helper()
+		for _, name := range names {
+			matches, _ := filepath.Glob(name + "*")
+			for _, match := range matches {
+				raw, _ := os.ReadFile(match)
+				if bytes.Contains(raw, []byte("s3cret")) {
+					t.Errorf("Expected %s to be encrypted", match)
+				}
+			}
+		}
+	}
+
+	t.Run("files are sealed and read back", func(t *testing.T) {
+		all, links, nums := paths(t)
+		keys := newKeyring(t, newKey(t))
+		s := NewEncryptedReliableStorage(all, links, nums, keys)
+		if err := s.AddNewLinkPerm(secretSet); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.AddLinksProcessList(&models.SetLinksGet{Links: []string{secret}}, "a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Compact(); err != nil {
+			t.Fatal(err)
+		}
+		assertNoPlaintext(t, all, links, nums)
+
+		reopened := NewEncryptedReliableStorage(all, links, nums, keys)
+		set, err := reopened.ReadSet(1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, ok := set.Answer[secret]; !ok {
+			t.Errorf("Expected the set to decrypt, got %+v", set)
+		}
+		pending, err := reopened.GetPendingLinksData()
+		if err != nil || len(pending) != 1 || pending[0].Links[0] != secret {
+			t.Errorf("Expected the pending task to decrypt, got %v, %v", pending, err)
+		}
+	})
+
+	t.Run("plaintext files are read and sealed on the next write", func(t *testing.T) {
+		all, links, nums := paths(t)
+		if err := NewReliableStorage(all, links, nums).AddNewLinkPerm(secretSet); err != nil {
+			t.Fatal(err)
+		}
+
+		s := NewEncryptedReliableStorage(all, links, nums, newKeyring(t, newKey(t)))
+		if _, err := s.ReadSet(1); err != nil {
+			t.Fatalf("Expected the plaintext set to read, got %v", err)
+		}
+		if err := s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 2}); err != nil {
+			t.Fatal(err)
+		}
+		if raw, _ := os.ReadFile(all); !isSealed(raw) {
+			t.Error("Expected the rewritten file to be sealed")
+		}
+	})
+
+	t.Run("tampered and foreign files do not decrypt", func(t *testing.T) {
+		all, links, nums := paths(t)
+		keys := newKeyring(t, newKey(t))
+		if err := NewEncryptedReliableStorage(all, links, nums, keys).AddNewLinkPerm(secretSet); err != nil {
+			t.Fatal(err)
+		}
+
+		raw, _ := os.ReadFile(all)
+		tampered := append([]byte{}, raw...)
+		tampered[len(tampered)-1] ^= 1
+		if _, err := keys.open(tampered); err == nil {
+			t.Error("Expected a tampered file to fail authentication")
+		}
+		if _, err := newKeyring(t, newKey(t)).open(raw); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey, got %v", err)
+		}
+		if _, err := (*Keyring)(nil).open(raw); err == nil {
+			t.Error("Expected an error without a key")
+		}
+	})
+
+	t.Run("rotation and re-encryption retire the old key", func(t *testing.T) {
+		all, links, nums := paths(t)
+		oldKey, newKeyBytes := newKey(t), newKey(t)
+		s := NewEncryptedReliableStorage(all, links, nums, newKeyring(t, oldKey))
+		for i := 1; i <= 3; i++ {
+			if err := s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{secret: {}}, ListNum: i}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		rotated := newKeyring(t, newKeyBytes, oldKey)
+		stats, err := ReencryptFiles(rotated, all, links, nums)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if stats.Rewritten == 0 {
+			t.Fatalf("Expected files to be rewritten, got %+v", stats)
+		}
+		if again, _ := ReencryptFiles(rotated, all, links, nums); again.Rewritten != 0 {
+			t.Errorf("Expected a second run to skip every file, got %+v", again)
+		}
+
+		onlyNew := newKeyring(t, newKeyBytes)
+		for _, name := range []string{all, backupName(all, 1), links, nums} {
+			var v any
+			if err := decodeJSONFile(name, onlyNew, &v); err != nil {
+				t.Errorf("Expected %s to decrypt with the new key alone: %v", name, err)
+			}
+		}
+		data, err := NewEncryptedReliableStorage(all, links, nums, onlyNew).ReadAllFile()
+		if err != nil || len(*data) != 3 {
+			t.Errorf("Expected 3 sets after rotation, got %v, %v", data, err)
+		}
+	})
+
+	t.Run("LoadKeyring reads env keys and a key file", func(t *testing.T) {
+		first, second := newKey(t), newKey(t)
+		keyFile := filepath.Join(t.TempDir(), "keys")
+		content := "# rotated 2026-10\n\n" + base64.StdEncoding.EncodeToString(second) + "\n"
+		if err := os.WriteFile(keyFile, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		keys, err := LoadKeyring([]string{base64.StdEncoding.EncodeToString(first)}, keyFile)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(keys.keys) != 2 || keys.keys[0].id != keyID(first) {
+			t.Errorf("Expected the env key first, got %d keys", len(keys.keys))
+		}
+
+		if keys, err := LoadKeyring(nil, ""); keys != nil || err != nil {
+			t.Errorf("Expected no keyring without keys, got %v, %v", keys, err)
+		}
+		if _, err := LoadKeyring([]string{base64.StdEncoding.EncodeToString([]byte("short"))}, ""); err == nil {
+			t.Error("Expected an error for a key of invalid length")
+		}
+	})
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"status-links/internal/models"
+	"sync"
+	"time"
+)
+
+// SetReader is the part of ReliableStorage the LRU cache reads through to.
+type SetReader interface {
+	ReadSet(listNum int) (*models.ProcessedLinks, error)
+}
+
+// LRUOptions bound the cache by entry count, by approximate size in bytes, or
+// both. A zero value disables that bound.
+type LRUOptions struct {
+	MaxEntries int
+	MaxBytes   int64
+}
+
+type lruEntry struct {
+	set  models.ProcessedLinks
+	size int64
+}
+
+// lruTempStorage keeps the most recently used sets in memory and reads the
+// rest from the reliable storage on demand. The creation time of every live
+// set is kept apart from the cache, so numbering, expiry and retention work
+// for evicted sets too.
+type lruTempStorage struct {
+	reliable SetReader
+	opts     LRUOptions
+
+	mu      sync.Mutex
+	order   *list.List
+	items   map[int]*list.Element
+	created map[int]time.Time
+	bytes   int64
+	lastNum int
+	// expiredUpTo is the highest number Expire removed.
+	expiredUpTo int
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func NewLRUTempStorage(reliable SetReader, opts LRUOptions) *lruTempStorage {
+	return &lruTempStorage{
+		reliable: reliable,
+		opts:     opts,
+		order:    list.New(),
+		items:    make(map[int]*list.Element),
+		created:  make(map[int]time.Time),
+	}
+}
+
+func (s *lruTempStorage) UploadAllData(bs *[]models.ProcessedLinks) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, set := range *bs {
+		s.put(set)
+		s.lastNum = max(s.lastNum, set.ListNum)
+	}
+}
+
+// UploadIndex registers every stored set and reads only the newest ones into
+// the cache, as many as fit its bounds. The rest are read on first use.
+func (s *lruTempStorage) UploadIndex(created map[int]time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nums := make([]int, 0, len(created))
+	for num, at := range created {
+		s.created[num] = at
+		s.lastNum = max(s.lastNum, num)
+		nums = append(nums, num)
+	}
+	slices.SortFunc(nums, func(a, b int) int { return b - a })
+
+	for _, num := range nums {
+		if s.opts.MaxEntries > 0 && s.order.Len() >= s.opts.MaxEntries {
+			break
+		}
+		if _, ok := s.items[num]; ok {
+			continue
+		}
+		set, err := s.reliable.ReadSet(num)
+		if err != nil {
+			return fmt.Errorf("failed to read set %d: %w", num, err)
+		}
+		entry := &lruEntry{set: *set, size: setSize(*set)}
+		if s.opts.MaxBytes > 0 && s.order.Len() > 0 && s.bytes+entry.size > s.opts.MaxBytes {
+			break
+		}
+		// Older sets go behind newer ones, so they are evicted first.
+		s.items[num] = s.order.PushBack(entry)
+		s.bytes += entry.size
+	}
+	return nil
+}
+
+func (s *lruTempStorage) UploadNewData(bs *models.ProcessedLinks) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastNum++
+	set := *bs
+	set.ListNum = s.lastNum
+	s.put(set)
+	return s.lastNum
+}
+
+func (s *lruTempStorage) UpdateData(bs *models.ProcessedLinks) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(*bs)
+	s.lastNum = max(s.lastNum, bs.ListNum)
+}
+
+func (s *lruTempStorage) FindKeys(list *models.SetNumsOfLinksGet) (*[]models.LinksAnswer, error) {
+	return answersOf(s.FindSets(list))
+}
+
+func (s *lruTempStorage) FindSets(list *models.SetNumsOfLinksGet) (*[]models.ProcessedLinks, error) {
+	bs := make([]models.ProcessedLinks, len(list.NumsLinks))
+	var missing []int
+
+	s.mu.Lock()
+	for i, num := range list.NumsLinks {
+		if elem, ok := s.items[num]; ok {
+			s.hits++
+			s.order.MoveToFront(elem)
+			bs[i] = elem.Value.(*lruEntry).set
+			continue
+		}
+		if _, ok := s.created[num]; !ok {
+			expiredUpTo := s.expiredUpTo
+			s.mu.Unlock()
+			if num > 0 && num <= expiredUpTo {
+				return nil, fmt.Errorf("key %d: %w", num, ErrSetExpired)
+			}
+			return nil, fmt.Errorf("key %d does not exist", num)
+		}
+		s.misses++
+		missing = append(missing, i)
+	}
+	s.mu.Unlock()
+
+	for _, i := range missing {
+		num := list.NumsLinks[i]
+		set, err := s.reliable.ReadSet(num)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("key %d does not exist", num)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read set %d: %w", num, err)
+		}
+		bs[i] = *set
+
+		s.mu.Lock()
+		if _, live := s.created[num]; live {
+			if _, cached := s.items[num]; !cached {
+				s.put(*set)
+			}
+		}
+		s.mu.Unlock()
+	}
+	return &bs, nil
+}
+
+func (s *lruTempStorage) ReturnMaxIndex() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastNum
+}
+
+func (s *lruTempStorage) RestoreMaxIndex(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastNum = max(s.lastNum, n)
+}
+
+func (s *lruTempStorage) Expire(cutoff time.Time, maxSets int, keep func(listNum int) bool) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := selectExpired(s.created, func(t time.Time) time.Time { return t }, cutoff, maxSets, keep)
+	for _, num := range expired {
+		delete(s.created, num)
+		if elem, ok := s.items[num]; ok {
+			s.remove(elem)
+		}
+		s.expiredUpTo = max(s.expiredUpTo, num)
+	}
+	return expired
+}
+
+func (s *lruTempStorage) CacheStats() models.CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return models.CacheStats{
+		Hits:       s.hits,
+		Misses:     s.misses,
+		Evictions:  s.evictions,
+		Entries:    s.order.Len(),
+		Bytes:      s.bytes,
+		MaxEntries: s.opts.MaxEntries,
+		MaxBytes:   s.opts.MaxBytes,
+	}
+}
+
+func (s *lruTempStorage) put(set models.ProcessedLinks) {
+	s.created[set.ListNum] = set.CreatedAt
+	entry := &lruEntry{set: set, size: setSize(set)}
+
+	if elem, ok := s.items[set.ListNum]; ok {
+		s.bytes += entry.size - elem.Value.(*lruEntry).size
+		elem.Value = entry
+		s.order.MoveToFront(elem)
+	} else {
+		s.items[set.ListNum] = s.order.PushFront(entry)
+		s.bytes += entry.size
+	}
+
+	// The newest entry stays even if it alone is over the byte budget.
+	for s.order.Len() > 1 && s.overBudget() {
+		s.remove(s.order.Back())
+		s.evictions++
+	}
+}
+
+func (s *lruTempStorage) overBudget() bool {
+	return (s.opts.MaxEntries > 0 && s.order.Len() > s.opts.MaxEntries) ||
+		(s.opts.MaxBytes > 0 && s.bytes > s.opts.MaxBytes)
+}
+
+func (s *lruTempStorage) remove(elem *list.Element) {
+	entry := s.order.Remove(elem).(*lruEntry)
+	delete(s.items, entry.set.ListNum)
+	s.bytes -= entry.size
+}
+
+// setSize approximates the memory a set takes by its JSON size.
+func setSize(set models.ProcessedLinks) int64 {
+	raw, err := json.Marshal(set)
+	if err != nil {
+		return 0
+	}
+	return int64(len(raw))
+}
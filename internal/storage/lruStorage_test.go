@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"status-links/internal/models"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLRUTempStorage(t *testing.T) {
+	newReliable := func(t *testing.T, nums ...int) *reliableStorageJsonFile {
+		t.Helper()
+		dir := t.TempDir()
+		reliable := NewReliableStorage(filepath.Join(dir, "all.json"), filepath.Join(dir, "links.json"), filepath.Join(dir, "nums.json"))
+		for _, num := range nums {
+			if err := reliable.AddNewLinkPerm(testSet(num)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return reliable
+	}
+
+	t.Run("evicts least recently used beyond MaxEntries", func(t *testing.T) {
+		reliable := newReliable(t, 1, 2, 3)
+		s := NewLRUTempStorage(reliable, LRUOptions{MaxEntries: 2})
+		data, _ := reliable.ReadAllFile()
+		s.UploadAllData(data)
+
+		if _, cached := s.items[1]; cached {
+			t.Error("Expected set 1 to be evicted")
+		}
+		if stats := s.CacheStats(); stats.Entries != 2 || stats.Evictions != 1 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+
+		// Touching 2 makes 3 the least recently used.
+		s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{2}})
+		s.UploadNewData(testSet(0))
+		if _, cached := s.items[3]; cached {
+			t.Error("Expected set 3 to be evicted after 2 was used")
+		}
+	})
+
+	t.Run("misses read through to the reliable storage", func(t *testing.T) {
+		reliable := newReliable(t, 1, 2)
+		s := NewLRUTempStorage(reliable, LRUOptions{MaxEntries: 1})
+		data, _ := reliable.ReadAllFile()
+		s.UploadAllData(data)
+
+		result, err := s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{1, 2}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if (*result)[0]["https://example.com/1"].Status != models.StatusAvailable {
+			t.Errorf("Expected set 1 from the reliable storage, got %+v", (*result)[0])
+		}
+		if stats := s.CacheStats(); stats.Hits != 1 || stats.Misses != 1 {
+			t.Errorf("Expected one hit and one miss, got %+v", stats)
+		}
+		if _, cached := s.items[1]; !cached {
+			t.Error("Expected set 1 to be cached after the miss")
+		}
+	})
+
+	t.Run("MaxBytes bounds the approximate size", func(t *testing.T) {
+		size := setSize(*testSet(1))
+		s := NewLRUTempStorage(newReliable(t), LRUOptions{MaxBytes: 2*size + size/2})
+		for i := 0; i < 5; i++ {
+			s.UploadNewData(testSet(0))
+		}
+		if stats := s.CacheStats(); stats.Entries != 2 || stats.Bytes > 2*size+size/2 {
+			t.Errorf("Expected two entries within budget, got %+v", stats)
+		}
+	})
+
+	t.Run("starting from an index caches only the newest sets", func(t *testing.T) {
+		bolt, err := NewBoltStorage(filepath.Join(t.TempDir(), "links.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer bolt.Close()
+		old := testSet(1)
+		old.CreatedAt = time.Now().Add(-time.Hour)
+		bolt.AddNewLinkPerm(old)
+		for num := 2; num <= 4; num++ {
+			bolt.AddNewLinkPerm(testSet(num))
+		}
+
+		created, err := bolt.ListSets()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		s := NewLRUTempStorage(bolt, LRUOptions{MaxEntries: 2})
+		if err := s.UploadIndex(created); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if stats := s.CacheStats(); stats.Entries != 2 || stats.Evictions != 0 {
+			t.Errorf("Expected two sets read at start, got %+v", stats)
+		}
+		for _, num := range []int{3, 4} {
+			if _, cached := s.items[num]; !cached {
+				t.Errorf("Expected newest set %d to be cached", num)
+			}
+		}
+		if s.ReturnMaxIndex() != 4 {
+			t.Errorf("Expected max index 4, got %d", s.ReturnMaxIndex())
+		}
+		if _, err := s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{2}}); err != nil {
+			t.Errorf("Expected an uncached set to be read on demand, got %v", err)
+		}
+		if expired := s.Expire(time.Now().Add(-time.Minute), 0, nil); len(expired) != 1 || expired[0] != 1 {
+			t.Errorf("Expected the uncached old set to expire, got %v", expired)
+		}
+	})
+
+	t.Run("expired and unknown numbers", func(t *testing.T) {
+		s := NewLRUTempStorage(newReliable(t), LRUOptions{MaxEntries: 1})
+		old := testSet(0)
+		old.CreatedAt = time.Now().Add(-time.Hour)
+		s.UploadNewData(old)
+		s.UploadNewData(testSet(0))
+
+		if expired := s.Expire(time.Now().Add(-time.Minute), 0, nil); len(expired) != 1 || expired[0] != 1 {
+			t.Fatalf("Expected evicted set 1 to expire, got %v", expired)
+		}
+		if _, err := s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{1}}); !errors.Is(err, ErrSetExpired) {
+			t.Errorf("Expected ErrSetExpired, got %v", err)
+		}
+		if _, err := s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{3}}); err == nil || errors.Is(err, ErrSetExpired) {
+			t.Errorf("Expected a plain missing-key error, got %v", err)
+		}
+
+		s.UpdateData(testSet(5))
+		if _, err := s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{4}}); err == nil || errors.Is(err, ErrSetExpired) {
+			t.Errorf("Expected a never stored number to not exist, got %v", err)
+		}
+	})
+}
+
+func testSet(num int) *models.ProcessedLinks {
+	return &models.ProcessedLinks{
+		Answer:    models.LinksAnswer{"https://example.com/" + strconv.Itoa(num): {Status: models.StatusAvailable}},
+		ListNum:   num,
+		CreatedAt: time.Now(),
+	}
+}
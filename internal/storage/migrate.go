@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+)
+
+type MigrationStats struct {
+	Sets         int
+	PendingLinks int
+	PendingNums  int
+}
+
+// MigrateJSONToBolt imports the JSON storage files, including a compaction
+// snapshot, into a new bbolt file. The source files are only read; keys
+// decrypts them and may be nil for plaintext files.
+func MigrateJSONToBolt(allTasks, processLinks, processNums, boltFile string, keys *Keyring) (MigrationStats, error) {
+	var stats MigrationStats
+	source := &reliableStorageJsonFile{
+		NameFileAllTasks:          allTasks,
+		NameFileProcessTasksLinks: processLinks,
+		NameFileProcessTasksNums:  processNums,
+		keys:                      keys,
+	}
+
+	sets, err := source.ReadAllFile()
+	if err != nil {
+		return stats, err
+	}
+	var links []ProcessTasksLinks
+	if err := decodeJSONFile(processLinks, keys, &links); err != nil && !os.IsNotExist(err) {
+		return stats, fmt.Errorf("failed to read %q: %w", processLinks, err)
+	}
+	var nums []ProcessTasksNums
+	if err := decodeJSONFile(processNums, keys, &nums); err != nil && !os.IsNotExist(err) {
+		return stats, fmt.Errorf("failed to read %q: %w", processNums, err)
+	}
+
+	dst, err := NewBoltStorage(boltFile)
+	if err != nil {
+		return stats, err
+	}
+	defer dst.Close()
+
+	if err := dst.Import(*sets, links, nums); err != nil {
+		return stats, err
+	}
+	return MigrationStats{Sets: len(*sets), PendingLinks: len(links), PendingNums: len(nums)}, nil
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+type ReencryptStats struct {
+	Rewritten int
+	Skipped   int
+}
+
+// ReencryptFiles rewrites every given storage file, its compaction snapshot
+// and their backups with the primary key of keys, so old keys and plaintext
+// copies can be retired. Files already sealed by that key are left alone.
+// The storage must not be running while this happens.
+func ReencryptFiles(keys *Keyring, filenames ...string) (ReencryptStats, error) {
+	var stats ReencryptStats
+	if keys == nil {
+		return stats, errors.New("no encryption key configured")
+	}
+
+	for _, name := range filenames {
+		for _, file := range []string{name, snapshotName(name)} {
+			candidates := []string{file}
+			for gen := 1; gen <= backupGenerations; gen++ {
+				candidates = append(candidates, backupName(file, gen))
+			}
+			for _, candidate := range candidates {
+				rewritten, err := reencryptFile(candidate, keys)
+				if err != nil {
+					return stats, fmt.Errorf("failed to re-encrypt %q: %w", candidate, err)
+				}
+				if rewritten {
+					stats.Rewritten++
+				} else if fileExists(candidate) {
+					stats.Skipped++
+				}
+			}
+		}
+	}
+	return stats, nil
+}
+
+func reencryptFile(filename string, keys *Keyring) (bool, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if keys.sealedByPrimary(data) {
+		return false, nil
+	}
+
+	plain, err := keys.open(data)
+	if err != nil {
+		return false, err
+	}
+	sealed, err := keys.seal(plain)
+	if err != nil {
+		return false, err
+	}
+	// Backups of the old content would keep exactly what is being retired.
+	return true, replaceFile(filename, func(w io.Writer) error {
+		_, err := w.Write(sealed)
+		return err
+	}, false)
+}
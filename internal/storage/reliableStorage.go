@@ -1,22 +1,43 @@
 package storage
 
 import (
-	"crypto/md5"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"status-links/internal/models"
 	"sync"
+	"time"
 )
 
+type PendingTask struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Attempts  int       `json:"attempts"`
+	Owner     string    `json:"owner,omitempty"`
+	// ClaimedAt is when Owner last claimed or renewed the task.
+	ClaimedAt time.Time `json:"claimed_at,omitzero"`
+	// Hash identified tasks written before IDs existed; such tasks use it
+	// as their ID.
+	Hash string `json:"hash,omitempty"`
+}
+
+func (t *PendingTask) upgradeLegacy() {
+	if t.ID == "" {
+		t.ID = t.Hash
+	}
+}
+
 type ProcessTasksLinks struct {
-	Data models.SetLinksGet `json:"data"`
-	Hash string             `json:"hash"`
+	PendingTask
+	Data    models.SetLinksGet `json:"data"`
+	ListNum int                `json:"links_num,omitempty"`
 }
 type ProcessTasksNums struct {
+	PendingTask
 	Data models.SetNumsOfLinksGet `json:"data"`
-	Hash string                   `json:"hash"`
 }
 type AllTasksNums struct {
 	DataAn  []models.ProcessedLinks `json:"processed_data"`
@@ -29,357 +50,391 @@ type reliableStorageJsonFile struct {
 	muAllTasks                sync.Mutex
 	muTasksLinks              sync.Mutex
 	muTasksNums               sync.Mutex
+	muCompact                 sync.Mutex
+	keys                      *Keyring
 }
 
 func NewReliableStorage(NameFileAllTasks string, NameFileProcessTasksLinks string, NameFileProcessTasksNums string) *reliableStorageJsonFile {
+	return NewEncryptedReliableStorage(NameFileAllTasks, NameFileProcessTasksLinks, NameFileProcessTasksNums, nil)
+}
+
+// NewEncryptedReliableStorage seals every file it writes, snapshots included,
+// with keys. Files still in plaintext are read as they are and encrypted on
+// their next write.
+func NewEncryptedReliableStorage(NameFileAllTasks string, NameFileProcessTasksLinks string, NameFileProcessTasksNums string, keys *Keyring) *reliableStorageJsonFile {
 	s := &reliableStorageJsonFile{
 		NameFileAllTasks:          NameFileAllTasks,
 		NameFileProcessTasksLinks: NameFileProcessTasksLinks,
 		NameFileProcessTasksNums:  NameFileProcessTasksNums,
+		keys:                      keys,
 	}
-	if _, err := os.Stat(s.NameFileAllTasks); os.IsNotExist(err) {
-		s.writeJSON(s.NameFileAllTasks, &AllTasksNums{DataAn: []models.ProcessedLinks{}, LastNum: 0})
+	type storageFile struct {
+		name     string
+		newValue func() any
+		empty    any
 	}
-	if _, err := os.Stat(s.NameFileProcessTasksLinks); os.IsNotExist(err) {
-		s.writeJSON(s.NameFileProcessTasksLinks, []ProcessTasksLinks{})
+	files := []storageFile{
+		{s.NameFileAllTasks, func() any { return &AllTasksNums{} }, &AllTasksNums{DataAn: []models.ProcessedLinks{}}},
+		{s.NameFileProcessTasksLinks, func() any { return &[]ProcessTasksLinks{} }, []ProcessTasksLinks{}},
+		{s.NameFileProcessTasksNums, func() any { return &[]ProcessTasksNums{} }, []ProcessTasksNums{}},
 	}
-	if _, err := os.Stat(s.NameFileProcessTasksNums); os.IsNotExist(err) {
-		s.writeJSON(s.NameFileProcessTasksNums, []ProcessTasksNums{})
+	if snapshot := snapshotName(s.NameFileAllTasks); fileExists(snapshot) {
+		files = append(files, storageFile{snapshot, func() any { return &AllTasksNums{} }, &AllTasksNums{DataAn: []models.ProcessedLinks{}}})
+	}
+	for _, f := range files {
+		if err := recoverJSONFile(f.name, s.keys, f.newValue, f.empty); err != nil {
+			slog.Error("error in recoverJSONFile", "file", f.name, "error", err)
+		}
 	}
 	return s
 }
 func (s *reliableStorageJsonFile) writeJSON(filename string, data interface{}) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	encoder.SetEscapeHTML(false)
-	return encoder.Encode(data)
+	return writeFileAtomic(filename, s.keys.sealWriter(func(w io.Writer) error {
+		return encodeJSON(w, data)
+	}))
 }
 func (s *reliableStorageJsonFile) ReadAllFile() (*[]models.ProcessedLinks, error) {
 	s.muAllTasks.Lock()
 	defer s.muAllTasks.Unlock()
-	file, err := os.Open(s.NameFileAllTasks)
+
+	snapshot, err := s.readSnapshot()
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("failed to open storage file: %w", err)
-		}
-		empty := make([]models.ProcessedLinks, 0)
-		return &empty, nil
+		return nil, err
+	}
+	data, err := s.readAllTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	merged := mergeSets(snapshot.DataAn, data.DataAn)
+	return &merged, nil
+}
+
+// ReadSet returns a single stored set, or os.ErrNotExist.
+// ReadSet decodes the whole file for one set, which is why the LRU cache is
+// only offered on top of the other storages.
+func (s *reliableStorageJsonFile) ReadSet(listNum int) (*models.ProcessedLinks, error) {
+	data, err := s.ReadAllFile()
+	if err != nil {
+		return nil, err
+	}
+	return findSet(*data, listNum)
+}
+
+func (s *reliableStorageJsonFile) readSnapshot() (*AllTasksNums, error) {
+	var data AllTasksNums
+	if err := decodeJSONFile(snapshotName(s.NameFileAllTasks), s.keys, &data); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to decode snapshot of %q: %w", s.NameFileAllTasks, err)
 	}
-	defer file.Close()
+	return &data, nil
+}
 
+func (s *reliableStorageJsonFile) readAllTasks() (*AllTasksNums, error) {
 	var data AllTasksNums
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
+	if err := decodeJSONFile(s.NameFileAllTasks, s.keys, &data); err != nil {
+		if os.IsNotExist(err) {
+			return &data, nil
+		}
 		return nil, fmt.Errorf("failed to decode storage file %q: %w", s.NameFileAllTasks, err)
 	}
-	return &data.DataAn, nil
+	return &data, nil
 }
+
 func (s *reliableStorageJsonFile) AddNewLinkPerm(item *models.ProcessedLinks) error {
 	s.muAllTasks.Lock()
 	defer s.muAllTasks.Unlock()
-	file, err := os.Open(s.NameFileAllTasks)
-	if err != nil {
-		if os.IsNotExist(err) {
-			data := AllTasksNums{
-				DataAn:  []models.ProcessedLinks{*item},
-				LastNum: 1,
-			}
-			return s.writeAllTasks(&data)
-		}
-		return err
-	}
-	defer file.Close()
 
-	var data AllTasksNums
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&data); err != nil {
+	data, err := s.readAllTasks()
+	if err != nil {
 		return err
 	}
 
 	data.DataAn = append(data.DataAn, *item)
-	data.LastNum++
+	data.LastNum = max(data.LastNum, item.ListNum)
 
-	return s.writeAllTasks(&data)
+	return s.writeAllTasks(data)
 }
 
-func (s *reliableStorageJsonFile) writeAllTasks(data *AllTasksNums) error {
-	file, err := os.Create(s.NameFileAllTasks)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+// DeleteSets removes the given sets from both the snapshot and the live file.
+// LastNum is left as is, so the numbers stay used.
+func (s *reliableStorageJsonFile) DeleteSets(listNums []int) error {
+	s.muCompact.Lock()
+	defer s.muCompact.Unlock()
+	s.muAllTasks.Lock()
+	defer s.muAllTasks.Unlock()
+
+	remove := make(map[int]bool, len(listNums))
+	for _, num := range listNums {
+		remove[num] = true
+	}
+	keep := func(sets []models.ProcessedLinks) ([]models.ProcessedLinks, bool) {
+		kept := make([]models.ProcessedLinks, 0, len(sets))
+		for _, set := range sets {
+			if !remove[set.ListNum] {
+				kept = append(kept, set)
+			}
 		}
-		return err
+		return kept, len(kept) != len(sets)
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	encoder.SetEscapeHTML(false)
-	return encoder.Encode(data)
-}
+	snapshotFile := snapshotName(s.NameFileAllTasks)
+	if fileExists(snapshotFile) {
+		snapshot, err := s.readSnapshot()
+		if err != nil {
+			return err
+		}
+		var changed bool
+		if snapshot.DataAn, changed = keep(snapshot.DataAn); changed {
+			if _, err := writeSnapshot(snapshotFile, s.keys, snapshot); err != nil {
+				return fmt.Errorf("failed to write snapshot: %w", err)
+			}
+		}
+	}
 
-func joinStringsToBytes(strings []string) []byte {
-	var result []byte
-	for _, s := range strings {
-		result = append(result, s...)
+	data, err := s.readAllTasks()
+	if err != nil {
+		return err
 	}
-	return result
+	var changed bool
+	if data.DataAn, changed = keep(data.DataAn); changed {
+		return s.writeAllTasks(data)
+	}
+	return nil
 }
 
-func (s *reliableStorageJsonFile) AddLinksProcessList(masLinks *models.SetLinksGet) (string, error) {
+func (s *reliableStorageJsonFile) LastListNum() (int, error) {
+	last, err := s.lastSetNum()
+	if err != nil {
+		return 0, err
+	}
+
 	s.muTasksLinks.Lock()
 	defer s.muTasksLinks.Unlock()
-	var data []ProcessTasksLinks
-
-	file, err := os.Open(s.NameFileProcessTasksLinks)
+	tasks, err := s.readPendingLinks()
 	if err != nil {
-		if os.IsNotExist(err) {
-			data = []ProcessTasksLinks{}
+		return 0, err
+	}
+	return max(last, lastJobNum(tasks)), nil
+}
 
-		} else {
-			return "", err
-		}
+func (s *reliableStorageJsonFile) lastSetNum() (int, error) {
+	s.muAllTasks.Lock()
+	defer s.muAllTasks.Unlock()
+
+	snapshot, err := s.readSnapshot()
+	if err != nil {
+		return 0, err
 	}
-	defer file.Close()
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&data)
+	data, err := s.readAllTasks()
 	if err != nil {
-		if err == io.EOF {
-			data = []ProcessTasksLinks{}
-		} else {
-			return "", err
-		}
+		return 0, err
 	}
+	return max(snapshot.LastNum, data.LastNum), nil
+}
 
-	NewNode := ProcessTasksLinks{
-		Data: *masLinks,
-		Hash: fmt.Sprintf("%x", md5.Sum(joinStringsToBytes(masLinks.Links))),
-	}
-	data = append(data, NewNode)
+// Compact moves every set from AllTasks.json into the snapshot, leaving the
+// live file small. AddNewLinkPerm is only held up while the tail is read and
+// trimmed, not while the snapshot is written.
+func (s *reliableStorageJsonFile) Compact() (SnapshotInfo, error) {
+	s.muCompact.Lock()
+	defer s.muCompact.Unlock()
 
-	file, err = os.Create(s.NameFileProcessTasksLinks)
+	s.muAllTasks.Lock()
+	tail, err := s.readAllTasks()
+	s.muAllTasks.Unlock()
 	if err != nil {
-		return "", err
+		return SnapshotInfo{}, err
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	encoder.SetEscapeHTML(false)
-	err = encoder.Encode(data)
+	snapshot, err := s.readSnapshot()
 	if err != nil {
-		return "", err
+		return SnapshotInfo{}, err
+	}
+	snapshot.DataAn = mergeSets(snapshot.DataAn, tail.DataAn)
+	if tail.LastNum > snapshot.LastNum {
+		snapshot.LastNum = tail.LastNum
 	}
 
-	return NewNode.Hash, nil
-}
+	size, err := writeSnapshot(snapshotName(s.NameFileAllTasks), s.keys, snapshot)
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("failed to write snapshot: %w", err)
+	}
 
-func intArrayToBytes(arr []int) []byte {
-	result := make([]byte, len(arr)*8)
-	for i, v := range arr {
-		for j := 0; j < 8; j++ {
-			result[i*8+j] = byte(v >> uint(j*8))
-		}
+	s.muAllTasks.Lock()
+	defer s.muAllTasks.Unlock()
+	current, err := s.readAllTasks()
+	if err != nil {
+		return SnapshotInfo{}, err
 	}
-	return result
+	current.DataAn = mergeSets(snapshot.DataAn, current.DataAn)[len(snapshot.DataAn):]
+	if err := s.writeAllTasks(current); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("failed to trim storage file: %w", err)
+	}
+
+	return SnapshotInfo{Bytes: size, Sets: len(snapshot.DataAn)}, nil
 }
 
-func (s *reliableStorageJsonFile) AddNumProcessList(masLinks *models.SetNumsOfLinksGet) (string, error) {
-	s.muTasksNums.Lock()
-	defer s.muTasksNums.Unlock()
-	var data []ProcessTasksNums
+func (s *reliableStorageJsonFile) writeAllTasks(data *AllTasksNums) error {
+	return s.writeJSON(s.NameFileAllTasks, data)
+}
 
-	file, err := os.Open(s.NameFileProcessTasksNums)
-	if err != nil {
-		if os.IsNotExist(err) {
-			data = []ProcessTasksNums{}
-		} else {
-			return "", err
-		}
-	} else {
-		defer file.Close()
+func (s *reliableStorageJsonFile) AddLinksProcessList(masLinks *models.SetLinksGet, owner string) (string, error) {
+	return s.addLinksTask(ProcessTasksLinks{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
+	})
+}
 
-		decoder := json.NewDecoder(file)
-		err = decoder.Decode(&data)
-		if err != nil {
-			if err == io.EOF {
-				data = []ProcessTasksNums{}
-			} else {
-				return "", err
-			}
-		}
-	}
+func (s *reliableStorageJsonFile) AddLinksJob(listNum int, masLinks *models.SetLinksGet, owner string) (string, error) {
+	return s.addLinksTask(ProcessTasksLinks{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
+		ListNum:     listNum,
+	})
+}
 
-	NewNode := ProcessTasksNums{
-		Data: *masLinks,
-		Hash: fmt.Sprintf("%x", md5.Sum(intArrayToBytes(masLinks.NumsLinks))),
-	}
-	data = append(data, NewNode)
+func (s *reliableStorageJsonFile) addLinksTask(NewNode ProcessTasksLinks) (string, error) {
+	s.muTasksLinks.Lock()
+	defer s.muTasksLinks.Unlock()
 
-	file, err = os.Create(s.NameFileProcessTasksNums)
+	data, err := s.readPendingLinks()
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
+	data = append(data, NewNode)
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	encoder.SetEscapeHTML(false)
-	err = encoder.Encode(data)
-	if err != nil {
+	if err := s.writeJSON(s.NameFileProcessTasksLinks, data); err != nil {
 		return "", err
 	}
-
-	return NewNode.Hash, nil
+	return NewNode.ID, nil
 }
 
-func (s *reliableStorageJsonFile) RemoveLinksProcessByHash(targetHash string) error {
-	s.muTasksLinks.Lock()
-	defer s.muTasksLinks.Unlock()
-	file, err := os.Open(s.NameFileProcessTasksLinks)
+func (s *reliableStorageJsonFile) AddNumProcessList(masLinks *models.SetNumsOfLinksGet, owner string) (string, error) {
+	s.muTasksNums.Lock()
+	defer s.muTasksNums.Unlock()
+
+	data, err := s.readPendingNums()
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer file.Close()
 
-	var data []ProcessTasksLinks
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&data); err != nil {
-		return err
+	NewNode := ProcessTasksNums{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
 	}
+	data = append(data, NewNode)
 
-	found := false
-	filtered := make([]ProcessTasksLinks, 0, len(data))
-	for _, item := range data {
-		if item.Hash != targetHash {
-			filtered = append(filtered, item)
-		} else {
-			found = true
-		}
+	if err := s.writeJSON(s.NameFileProcessTasksNums, data); err != nil {
+		return "", err
 	}
+	return NewNode.ID, nil
+}
 
-	if !found {
-		return os.ErrNotExist
-	}
+func (s *reliableStorageJsonFile) RemoveLinksTask(id string) error {
+	s.muTasksLinks.Lock()
+	defer s.muTasksLinks.Unlock()
 
-	file, err = os.Create(s.NameFileProcessTasksLinks)
+	data, err := s.readPendingLinks()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	encoder.SetEscapeHTML(false)
-	return encoder.Encode(filtered)
+	for i, item := range data {
+		if item.ID == id {
+			return s.writeJSON(s.NameFileProcessTasksLinks, append(data[:i], data[i+1:]...))
+		}
+	}
+	return os.ErrNotExist
 }
 
-func (s *reliableStorageJsonFile) RemoveNumsProcessByHash(targetHash string) error {
+func (s *reliableStorageJsonFile) RemoveNumsTask(id string) error {
 	s.muTasksNums.Lock()
 	defer s.muTasksNums.Unlock()
-	file, err := os.Open(s.NameFileProcessTasksNums)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
 
-	var data []ProcessTasksNums
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&data); err != nil {
+	data, err := s.readPendingNums()
+	if err != nil {
 		return err
 	}
-
-	found := false
-	filtered := make([]ProcessTasksNums, 0, len(data))
-	for _, item := range data {
-		if item.Hash != targetHash {
-			filtered = append(filtered, item)
-		} else {
-			found = true
+	for i, item := range data {
+		if item.ID == id {
+			return s.writeJSON(s.NameFileProcessTasksNums, append(data[:i], data[i+1:]...))
 		}
 	}
-
-	if !found {
-		return os.ErrNotExist
-	}
-
-	file, err = os.Create(s.NameFileProcessTasksNums)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	encoder.SetEscapeHTML(false)
-	return encoder.Encode(filtered)
+	return os.ErrNotExist
 }
+
 func (s *reliableStorageJsonFile) getPendingLinks() ([]ProcessTasksLinks, error) {
 	s.muTasksLinks.Lock()
 	defer s.muTasksLinks.Unlock()
+	return s.readPendingLinks()
+}
 
-	file, err := os.Open(s.NameFileProcessTasksLinks)
+func (s *reliableStorageJsonFile) readPendingLinks() ([]ProcessTasksLinks, error) {
+	raw, err := readSealedFile(s.NameFileProcessTasksLinks, s.keys)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return []ProcessTasksLinks{}, nil
 		}
-		return nil, fmt.Errorf("не удалось открыть файл ожидающих ссылок: %w", err)
+		return nil, fmt.Errorf("не удалось прочитать файл ожидающих ссылок: %w", err)
 	}
-	defer file.Close()
 
 	var tasks []ProcessTasksLinks
-	if err := json.NewDecoder(file).Decode(&tasks); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(raw)).Decode(&tasks); err != nil {
 		if err == io.EOF {
 			return []ProcessTasksLinks{}, nil
 		}
 		return nil, fmt.Errorf("ошибка десериализации файла ожидающих ссылок: %w", err)
 	}
 
+	for i := range tasks {
+		tasks[i].upgradeLegacy()
+	}
 	return tasks, nil
 }
 
-func (s *reliableStorageJsonFile) getPendingNums() ([]ProcessTasksNums, error) {
-	s.muTasksNums.Lock()
-	defer s.muTasksNums.Unlock()
-
-	file, err := os.Open(s.NameFileProcessTasksNums)
+func (s *reliableStorageJsonFile) readPendingNums() ([]ProcessTasksNums, error) {
+	raw, err := readSealedFile(s.NameFileProcessTasksNums, s.keys)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return []ProcessTasksNums{}, nil
 		}
-		return nil, fmt.Errorf("не удалось открыть файл ожидающих номеров: %w", err)
+		return nil, fmt.Errorf("не удалось прочитать файл ожидающих номеров: %w", err)
 	}
-	defer file.Close()
 
 	var tasks []ProcessTasksNums
-	if err := json.NewDecoder(file).Decode(&tasks); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(raw)).Decode(&tasks); err != nil {
 		if err == io.EOF {
 			return []ProcessTasksNums{}, nil
 		}
 		return nil, fmt.Errorf("ошибка десериализации файла ожидающих номеров: %w", err)
 	}
 
+	for i := range tasks {
+		tasks[i].upgradeLegacy()
+	}
 	return tasks, nil
 }
 
 func (s *reliableStorageJsonFile) GetPendingLinksData() ([]models.SetLinksGet, error) {
-	tasks, err := s.getPendingLinks()
+	s.muTasksLinks.Lock()
+	defer s.muTasksLinks.Unlock()
+
+	tasks, err := s.readPendingLinks()
 	if err != nil {
 		return nil, err
 	}
 
-	result := make([]models.SetLinksGet, len(tasks))
-	for i, task := range tasks {
-		result[i] = task.Data
+	result := make([]models.SetLinksGet, 0, len(tasks))
+	jobs := make([]ProcessTasksLinks, 0)
+	for _, task := range tasks {
+		if task.ListNum > 0 {
+			jobs = append(jobs, task)
+			continue
+		}
+		result = append(result, task.Data)
 	}
 
-	if len(tasks) > 0 {
-		if err := s.writeJSON(s.NameFileProcessTasksLinks, []ProcessTasksLinks{}); err != nil {
+	if len(result) > 0 {
+		if err := s.writeJSON(s.NameFileProcessTasksLinks, jobs); err != nil {
 			return nil, fmt.Errorf("failed to clear links file: %w", err)
 		}
 	}
@@ -387,8 +442,122 @@ func (s *reliableStorageJsonFile) GetPendingLinksData() ([]models.SetLinksGet, e
 	return result, nil
 }
 
+// ClaimPendingJobs hands out the jobs that are unowned, already belong to
+// owner or were abandoned, recording the owner and counting the attempt.
+func (s *reliableStorageJsonFile) ClaimPendingJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	return s.claimJobs(owner, func(task *ProcessTasksLinks) bool {
+		return claimable(task, owner, staleBefore)
+	})
+}
+
+func (s *reliableStorageJsonFile) TakeOverJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	return s.claimJobs(owner, func(task *ProcessTasksLinks) bool {
+		return abandoned(task, owner, staleBefore)
+	})
+}
+
+func (s *reliableStorageJsonFile) claimJobs(owner string, match func(*ProcessTasksLinks) bool) ([]models.LinksJob, error) {
+	s.muTasksLinks.Lock()
+	defer s.muTasksLinks.Unlock()
+
+	tasks, err := s.readPendingLinks()
+	if err != nil {
+		return nil, err
+	}
+
+	result := claimMatching(tasks, owner, claimClock().UTC(), match)
+	if len(result) > 0 {
+		if err := s.writeJSON(s.NameFileProcessTasksLinks, tasks); err != nil {
+			return nil, fmt.Errorf("failed to claim jobs: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func (s *reliableStorageJsonFile) RenewJobClaims(owner string) error {
+	s.muTasksLinks.Lock()
+	defer s.muTasksLinks.Unlock()
+
+	tasks, err := s.readPendingLinks()
+	if err != nil {
+		return err
+	}
+	if !renewClaims(tasks, owner, claimClock().UTC()) {
+		return nil
+	}
+	if err := s.writeJSON(s.NameFileProcessTasksLinks, tasks); err != nil {
+		return fmt.Errorf("failed to renew job claims: %w", err)
+	}
+	return nil
+}
+
+// claimable reports whether task is a job owner may take: its own, or one
+// abandoned by everyone else.
+func claimable(task *ProcessTasksLinks, owner string, staleBefore time.Time) bool {
+	return task.ListNum > 0 && (task.Owner == owner || abandoned(task, owner, staleBefore))
+}
+
+// abandoned reports whether task is a job of no one but owner could be
+// running: it is unowned, or another owner's claim is older than
+// staleBefore, since that owner has stopped renewing it.
+func abandoned(task *ProcessTasksLinks, owner string, staleBefore time.Time) bool {
+	return task.ListNum > 0 && task.Owner != owner && (task.Owner == "" || task.ClaimedAt.Before(staleBefore))
+}
+
+// claimMatching hands owner the tasks match selects, recording the owner and
+// counting the attempt.
+func claimMatching(tasks []ProcessTasksLinks, owner string, now time.Time, match func(*ProcessTasksLinks) bool) []models.LinksJob {
+	result := make([]models.LinksJob, 0)
+	for i := range tasks {
+		if !match(&tasks[i]) {
+			continue
+		}
+		tasks[i].Owner = owner
+		tasks[i].ClaimedAt = now
+		tasks[i].Attempts++
+		result = append(result, linksJob(tasks[i]))
+	}
+	return result
+}
+
+// lastJobNum is the highest number held by a pending job.
+func lastJobNum(tasks []ProcessTasksLinks) int {
+	last := 0
+	for _, task := range tasks {
+		last = max(last, task.ListNum)
+	}
+	return last
+}
+
+// renewClaims moves the claims of owner's jobs to now and reports whether
+// there were any.
+func renewClaims(tasks []ProcessTasksLinks, owner string, now time.Time) bool {
+	renewed := false
+	for i := range tasks {
+		if tasks[i].ListNum > 0 && tasks[i].Owner == owner {
+			tasks[i].ClaimedAt = now
+			renewed = true
+		}
+	}
+	return renewed
+}
+
+func linksJob(task ProcessTasksLinks) models.LinksJob {
+	return models.LinksJob{
+		ID:        task.ID,
+		ListNum:   task.ListNum,
+		Set:       task.Data,
+		CreatedAt: task.CreatedAt,
+		Attempts:  task.Attempts,
+		Owner:     task.Owner,
+	}
+}
+
 func (s *reliableStorageJsonFile) GetPendingNumsData() ([]models.SetNumsOfLinksGet, error) {
-	tasks, err := s.getPendingNums()
+	s.muTasksNums.Lock()
+	defer s.muTasksNums.Unlock()
+
+	tasks, err := s.readPendingNums()
 	if err != nil {
 		return nil, err
 	}
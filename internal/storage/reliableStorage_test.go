@@ -2,8 +2,10 @@ package storage
 
 import (
 	"os"
+	"path/filepath"
 	"status-links/internal/models"
 	"testing"
+	"time"
 )
 
 func TestReliableStorageJsonFile(t *testing.T) {
@@ -15,18 +17,21 @@ func TestReliableStorageJsonFile(t *testing.T) {
 
 	defer func() {
 		for _, file := range tempFiles {
-			os.Remove(file)
+			matches, _ := filepath.Glob(file + "*")
+			for _, name := range matches {
+				os.Remove(name)
+			}
 		}
 	}()
 
-	t.Run("AddLinksProcessList and RemoveLinksProcessByHash", func(t *testing.T) {
+	t.Run("AddLinksProcessList and RemoveLinksTask", func(t *testing.T) {
 		storage := NewReliableStorage(tempFiles[0], tempFiles[1], tempFiles[2])
 
 		set := models.SetLinksGet{
 			Links: []string{"https://example.com", "https://google.com"},
 		}
 
-		hash, err := storage.AddLinksProcessList(&set)
+		hash, err := storage.AddLinksProcessList(&set, "")
 		if err != nil {
 			t.Errorf("Unexpected error adding links: %v", err)
 		}
@@ -34,20 +39,20 @@ func TestReliableStorageJsonFile(t *testing.T) {
 			t.Error("Expected non-empty hash")
 		}
 
-		err = storage.RemoveLinksProcessByHash(hash)
+		err = storage.RemoveLinksTask(hash)
 		if err != nil {
 			t.Errorf("Unexpected error removing links: %v", err)
 		}
 	})
 
-	t.Run("AddNumProcessList and RemoveNumsProcessByHash", func(t *testing.T) {
+	t.Run("AddNumProcessList and RemoveNumsTask", func(t *testing.T) {
 		storage := NewReliableStorage(tempFiles[0], tempFiles[1], tempFiles[2])
 
 		set := models.SetNumsOfLinksGet{
 			NumsLinks: []int{1, 2, 3},
 		}
 
-		hash, err := storage.AddNumProcessList(&set)
+		hash, err := storage.AddNumProcessList(&set, "")
 		if err != nil {
 			t.Errorf("Unexpected error adding nums: %v", err)
 		}
@@ -55,7 +60,7 @@ func TestReliableStorageJsonFile(t *testing.T) {
 			t.Error("Expected non-empty hash")
 		}
 
-		err = storage.RemoveNumsProcessByHash(hash)
+		err = storage.RemoveNumsTask(hash)
 		if err != nil {
 			t.Errorf("Unexpected error removing nums: %v", err)
 		}
@@ -66,7 +71,7 @@ func TestReliableStorageJsonFile(t *testing.T) {
 
 		item := &models.ProcessedLinks{
 			Answer: models.LinksAnswer{
-				"https://test.com": "available",
+				"https://test.com": {Status: "available"},
 			},
 			ListNum: 1,
 		}
@@ -85,7 +90,7 @@ func TestReliableStorageJsonFile(t *testing.T) {
 			t.Errorf("Expected 1 item, got %d", len(*data))
 		}
 
-		if (*data)[0].Answer["https://test.com"] != "available" {
+		if (*data)[0].Answer["https://test.com"].Status != "available" {
 			t.Error("Expected stored data to match")
 		}
 	})
@@ -119,12 +124,12 @@ func TestReliableStorageJsonFile(t *testing.T) {
 	t.Run("Remove non-existent hash returns error", func(t *testing.T) {
 		storage := NewReliableStorage(tempFiles[0], tempFiles[1], tempFiles[2])
 
-		err := storage.RemoveLinksProcessByHash("non-existent-hash")
+		err := storage.RemoveLinksTask("non-existent-hash")
 		if err == nil {
 			t.Error("Expected error when removing non-existent hash")
 		}
 
-		err = storage.RemoveNumsProcessByHash("non-existent-hash")
+		err = storage.RemoveNumsTask("non-existent-hash")
 		if err == nil {
 			t.Error("Expected error when removing non-existent hash")
 		}
@@ -136,17 +141,17 @@ func TestReliableStorageJsonFile(t *testing.T) {
 		set1 := models.SetLinksGet{Links: []string{"link1", "link2"}}
 		set2 := models.SetLinksGet{Links: []string{"link3"}}
 
-		hash1, err := storage.AddLinksProcessList(&set1)
+		hash1, err := storage.AddLinksProcessList(&set1, "")
 		if err != nil {
 			t.Errorf("Error adding set1: %v", err)
 		}
 
-		hash2, err := storage.AddLinksProcessList(&set2)
+		hash2, err := storage.AddLinksProcessList(&set2, "")
 		if err != nil {
 			t.Errorf("Error adding set2: %v", err)
 		}
 
-		err = storage.RemoveLinksProcessByHash(hash1)
+		err = storage.RemoveLinksTask(hash1)
 		if err != nil {
 			t.Errorf("Error removing hash1: %v", err)
 		}
@@ -160,30 +165,228 @@ func TestReliableStorageJsonFile(t *testing.T) {
 			t.Errorf("Expected 1 pending task after removal, got %d", len(tasks))
 		}
 
-		if len(tasks) > 0 && tasks[0].Hash != hash2 {
-			t.Errorf("Expected remaining task to have hash %s, got %s", hash2, tasks[0].Hash)
+		if len(tasks) > 0 && tasks[0].ID != hash2 {
+			t.Errorf("Expected remaining task to have ID %s, got %s", hash2, tasks[0].ID)
 		}
 	})
 
-	t.Run("Hash generation is consistent", func(t *testing.T) {
+	t.Run("Same input gets distinct task IDs", func(t *testing.T) {
+		os.Remove(tempFiles[1])
 		storage := NewReliableStorage(tempFiles[0], tempFiles[1], tempFiles[2])
 
 		set := models.SetLinksGet{
 			Links: []string{"https://example.com", "https://google.com"},
 		}
 
-		hash1, err := storage.AddLinksProcessList(&set)
+		id1, err := storage.AddLinksProcessList(&set, "")
 		if err != nil {
 			t.Errorf("Error first add: %v", err)
 		}
 
-		hash2, err := storage.AddLinksProcessList(&set)
+		id2, err := storage.AddLinksProcessList(&set, "")
 		if err != nil {
 			t.Errorf("Error second add: %v", err)
 		}
 
-		if hash1 != hash2 {
-			t.Errorf("Expected same hash for same input, got %s and %s", hash1, hash2)
+		if id1 == id2 {
+			t.Errorf("Expected different IDs for repeated input, got %s twice", id1)
+		}
+
+		if err := storage.RemoveLinksTask(id1); err != nil {
+			t.Fatalf("Error removing first task: %v", err)
+		}
+		tasks, _ := storage.getPendingLinks()
+		if len(tasks) != 1 || tasks[0].ID != id2 {
+			t.Errorf("Expected only the second task to remain, got %+v", tasks)
+		}
+	})
+
+	t.Run("Concatenation-equal inputs do not collide", func(t *testing.T) {
+		os.Remove(tempFiles[1])
+		os.Remove(tempFiles[2])
+		storage := NewReliableStorage(tempFiles[0], tempFiles[1], tempFiles[2])
+
+		id1, _ := storage.AddLinksProcessList(&models.SetLinksGet{Links: []string{"ab", "c"}}, "")
+		id2, _ := storage.AddLinksProcessList(&models.SetLinksGet{Links: []string{"a", "bc"}}, "")
+		if id1 == id2 {
+			t.Fatalf("Expected different IDs, got %s twice", id1)
+		}
+		storage.RemoveLinksTask(id1)
+		tasks, _ := storage.getPendingLinks()
+		if len(tasks) != 1 || tasks[0].Data.Links[0] != "a" {
+			t.Errorf("Expected [a bc] to remain, got %+v", tasks)
+		}
+
+		num1, _ := storage.AddNumProcessList(&models.SetNumsOfLinksGet{NumsLinks: []int{1, 23}}, "")
+		num2, _ := storage.AddNumProcessList(&models.SetNumsOfLinksGet{NumsLinks: []int{12, 3}}, "")
+		storage.RemoveNumsTask(num2)
+		nums, _ := storage.readPendingNums()
+		if len(nums) != 1 || nums[0].ID != num1 {
+			t.Errorf("Expected only [1 23] to remain, got %+v", nums)
+		}
+	})
+
+	t.Run("Jobs are claimed by their owner", func(t *testing.T) {
+		os.Remove(tempFiles[1])
+		storage := NewReliableStorage(tempFiles[0], tempFiles[1], tempFiles[2])
+
+		set := models.SetLinksGet{Links: []string{"https://example.com"}}
+		storage.AddLinksJob(1, &set, "node-a")
+		storage.AddLinksJob(2, &set, "node-b")
+		storage.AddLinksJob(3, &set, "")
+
+		jobs, err := storage.ClaimPendingJobs("node-a", time.Time{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(jobs) != 2 || jobs[0].ListNum != 1 || jobs[1].ListNum != 3 {
+			t.Fatalf("Expected jobs 1 and 3, got %+v", jobs)
+		}
+		if jobs[1].Owner != "node-a" || jobs[1].Attempts != 1 {
+			t.Errorf("Expected unowned job to be claimed once, got %+v", jobs[1])
+		}
+
+		jobs, _ = storage.ClaimPendingJobs("node-a", time.Time{})
+		if len(jobs) != 2 || jobs[0].Attempts != 2 {
+			t.Errorf("Expected attempts to grow on each claim, got %+v", jobs)
+		}
+		if jobs, _ := storage.ClaimPendingJobs("node-b", time.Time{}); len(jobs) != 1 || jobs[0].ListNum != 2 {
+			t.Errorf("Expected node-b to claim only job 2, got %+v", jobs)
+		}
+	})
+	t.Run("Abandoned jobs are taken over once their lease runs out", func(t *testing.T) {
+		os.Remove(tempFiles[1])
+		storage := NewReliableStorage(tempFiles[0], tempFiles[1], tempFiles[2])
+
+		set := models.SetLinksGet{Links: []string{"https://example.com"}}
+		setClaimClock(t, leaseStart)
+		storage.AddLinksJob(1, &set, "old-host")
+		storage.AddLinksJob(2, &set, "node-b")
+
+		if jobs, _ := storage.ClaimPendingJobs("node-a", leaseStart.Add(-time.Hour)); len(jobs) != 0 {
+			t.Fatalf("Expected freshly claimed jobs to be left alone, got %+v", jobs)
+		}
+
+		setClaimClock(t, leaseStart.Add(2*time.Hour))
+		if err := storage.RenewJobClaims("node-b"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		jobs, err := storage.ClaimPendingJobs("node-a", leaseStart.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(jobs) != 1 || jobs[0].ListNum != 1 || jobs[0].Owner != "node-a" {
+			t.Errorf("Expected node-a to take over only the abandoned job 1, got %+v", jobs)
+		}
+	})
+	t.Run("Pending jobs count towards the last number", func(t *testing.T) {
+		os.Remove(tempFiles[0])
+		os.Remove(tempFiles[1])
+		storage := NewReliableStorage(tempFiles[0], tempFiles[1], tempFiles[2])
+
+		storage.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 3})
+		storage.AddLinksJob(7, &models.SetLinksGet{Links: []string{"https://example.com"}}, "node-b")
+		if last, err := storage.LastListNum(); err != nil || last != 7 {
+			t.Errorf("Expected 7 from the pending job, got %d, %v", last, err)
+		}
+	})
+	t.Run("Jobs are kept apart from unfinished link sets", func(t *testing.T) {
+		os.Remove(tempFiles[1])
+		storage := NewReliableStorage(tempFiles[0], tempFiles[1], tempFiles[2])
+
+		plain := models.SetLinksGet{Links: []string{"https://example.com"}}
+		if _, err := storage.AddLinksProcessList(&plain, ""); err != nil {
+			t.Fatalf("Error adding links: %v", err)
+		}
+		hash1, err := storage.AddLinksJob(10, &plain, "")
+		if err != nil {
+			t.Fatalf("Error adding job: %v", err)
+		}
+		hash2, err := storage.AddLinksJob(11, &plain, "")
+		if err != nil {
+			t.Fatalf("Error adding job: %v", err)
+		}
+		if hash1 == hash2 {
+			t.Error("Expected jobs to have different IDs")
+		}
+
+		pending, err := storage.GetPendingLinksData()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(pending) != 1 {
+			t.Errorf("Expected 1 unfinished link set, got %d", len(pending))
+		}
+
+		jobs, err := storage.ClaimPendingJobs("", time.Time{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(jobs) != 2 {
+			t.Fatalf("Expected 2 pending jobs, got %d", len(jobs))
+		}
+		if jobs[0].ListNum != 10 || jobs[0].ID != hash1 {
+			t.Errorf("Unexpected first job: %+v", jobs[0])
+		}
+
+		if err := storage.RemoveLinksTask(hash1); err != nil {
+			t.Errorf("Error removing job: %v", err)
+		}
+		jobs, _ = storage.ClaimPendingJobs("", time.Time{})
+		if len(jobs) != 1 || jobs[0].ListNum != 11 {
+			t.Errorf("Expected only job 11 to remain, got %+v", jobs)
+		}
+	})
+	t.Run("ReadAllFile loads legacy string statuses", func(t *testing.T) {
+		legacy := `{
+  "processed_data": [
+    {"links": {"https://old.com": "available", "https://gone.com": "unavailable"}, "links_num": 1}
+  ],
+  "lastNum": 1
+}`
+		if err := os.WriteFile(tempFiles[0], []byte(legacy), 0644); err != nil {
+			t.Fatalf("Failed to write legacy file: %v", err)
+		}
+		storage := NewReliableStorage(tempFiles[0], tempFiles[1], tempFiles[2])
+
+		data, err := storage.ReadAllFile()
+		if err != nil {
+			t.Fatalf("Unexpected error reading legacy file: %v", err)
+		}
+		if len(*data) != 1 {
+			t.Fatalf("Expected 1 item, got %d", len(*data))
+		}
+		answer := (*data)[0].Answer
+		if answer["https://old.com"].Status != models.StatusAvailable {
+			t.Errorf("Expected available, got %+v", answer["https://old.com"])
+		}
+		if answer["https://gone.com"].Status != models.StatusUnavailable {
+			t.Errorf("Expected unavailable, got %+v", answer["https://gone.com"])
+		}
+
+		item := &models.ProcessedLinks{
+			Answer: models.LinksAnswer{
+				"https://new.com": {Status: models.StatusAvailable, StatusCode: 204, LatencyMs: 12},
+			},
+			ListNum: 2,
+		}
+		if err := storage.AddNewLinkPerm(item); err != nil {
+			t.Fatalf("Unexpected error adding task: %v", err)
+		}
+		data, _ = storage.ReadAllFile()
+		if got := (*data)[1].Answer["https://new.com"]; got.StatusCode != 204 || got.LatencyMs != 12 {
+			t.Errorf("Expected structured result to round-trip, got %+v", got)
 		}
 	})
 }
+
+// leaseStart is an arbitrary fixed time the lease tests move claims around.
+var leaseStart = time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// setClaimClock stamps job claims with at until the test ends.
+func setClaimClock(t *testing.T, at time.Time) {
+	t.Helper()
+	previous := claimClock
+	claimClock = func() time.Time { return at }
+	t.Cleanup(func() { claimClock = previous })
+}
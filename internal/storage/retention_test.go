@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"path/filepath"
+	"status-links/internal/models"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestDeleteSets(t *testing.T) {
+	backends := map[string]func(t *testing.T, dir string) ReliableStorage{
+		"json": func(t *testing.T, dir string) ReliableStorage {
+			return NewReliableStorage(filepath.Join(dir, "all.json"), filepath.Join(dir, "links.json"), filepath.Join(dir, "nums.json"))
+		},
+		"wal": func(t *testing.T, dir string) ReliableStorage {
+			s, err := NewWALStorage(filepath.Join(dir, "tasks.wal"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+		"bolt": func(t *testing.T, dir string) ReliableStorage {
+			s, err := NewBoltStorage(filepath.Join(dir, "links.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+		"sql": func(t *testing.T, dir string) ReliableStorage {
+			s, err := NewSQLStorage(SQLOptions{
+				Driver:          "sqlite",
+				DSN:             "file:" + filepath.Join(dir, "links.sqlite") + "?_pragma=busy_timeout(5000)",
+				MaxOpenConns:    1,
+				ConnMaxLifetime: time.Minute,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			s := open(t, t.TempDir())
+			for num := 1; num <= 3; num++ {
+				err := s.AddNewLinkPerm(&models.ProcessedLinks{
+					Answer:  models.LinksAnswer{"https://example.com/": {Status: models.StatusAvailable}},
+					ListNum: num,
+				})
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			if compactor, ok := s.(Compactor); ok {
+				if _, err := compactor.Compact(); err != nil {
+					t.Fatalf("Unexpected error compacting: %v", err)
+				}
+			}
+
+			if err := s.DeleteSets([]int{1, 3}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			data, err := s.ReadAllFile()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(*data) != 1 || (*data)[0].ListNum != 2 {
+				t.Errorf("Expected only set 2 left, got %+v", *data)
+			}
+			if last, err := s.LastListNum(); err != nil || last != 3 {
+				t.Errorf("Expected last number 3 after deleting it, got %d (err %v)", last, err)
+			}
+		})
+	}
+}
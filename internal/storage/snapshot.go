@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"status-links/internal/models"
+	"time"
+)
+
+// Compactor is implemented by reliable storages that can fold their history
+// into a snapshot, so startup reads the snapshot plus a short tail.
+type Compactor interface {
+	Compact() (SnapshotInfo, error)
+}
+
+type SnapshotInfo struct {
+	Bytes int64
+	Sets  int
+}
+
+func snapshotName(filename string) string {
+	return filename + ".snapshot"
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+func writeSnapshot(filename string, keys *Keyring, data any) (int64, error) {
+	if err := writeFileAtomic(filename, keys.sealWriter(func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(data)
+	})); err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// mergeSets appends the tail to the snapshot, skipping sets the snapshot
+// already holds: a crash between writing a snapshot and trimming the tail
+// leaves them in both.
+func mergeSets(snapshot, tail []models.ProcessedLinks) []models.ProcessedLinks {
+	seen := make(map[int]bool, len(snapshot))
+	merged := make([]models.ProcessedLinks, 0, len(snapshot)+len(tail))
+	for _, set := range snapshot {
+		seen[set.ListNum] = true
+		merged = append(merged, set)
+	}
+	for _, set := range tail {
+		if !seen[set.ListNum] {
+			merged = append(merged, set)
+		}
+	}
+	return merged
+}
+
+// setHead is the part of a stored set that ListSets needs.
+type setHead struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// findSet returns the last set with listNum, so a later write wins.
+func findSet(sets []models.ProcessedLinks, listNum int) (*models.ProcessedLinks, error) {
+	for i := len(sets) - 1; i >= 0; i-- {
+		if sets[i].ListNum == listNum {
+			set := sets[i]
+			return &set, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
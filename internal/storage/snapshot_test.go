@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"status-links/internal/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJSONCompaction(t *testing.T) {
+	open := func(dir string) *reliableStorageJsonFile {
+		return NewReliableStorage(filepath.Join(dir, "all.json"), filepath.Join(dir, "links.json"), filepath.Join(dir, "nums.json"))
+	}
+	add := func(t *testing.T, s ReliableStorage, from, to int) {
+		for i := from; i <= to; i++ {
+			if err := s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: i}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+	}
+
+	t.Run("moves sets into the snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+		s := open(dir)
+		add(t, s, 1, 5)
+
+		info, err := s.Compact()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if info.Sets != 5 || info.Bytes == 0 {
+			t.Errorf("Unexpected snapshot info: %+v", info)
+		}
+
+		tail, _ := s.readAllTasks()
+		if len(tail.DataAn) != 0 || tail.LastNum != 5 {
+			t.Errorf("Expected empty tail keeping the counter, got %+v", tail)
+		}
+
+		add(t, s, 6, 7)
+		data, _ := open(dir).ReadAllFile()
+		if len(*data) != 7 || (*data)[6].ListNum != 7 {
+			t.Errorf("Expected snapshot plus tail, got %+v", *data)
+		}
+
+		info, _ = s.Compact()
+		if info.Sets != 7 {
+			t.Errorf("Expected second compaction to fold the tail, got %+v", info)
+		}
+	})
+
+	t.Run("sets in both snapshot and tail are read once", func(t *testing.T) {
+		dir := t.TempDir()
+		s := open(dir)
+		add(t, s, 1, 3)
+		tail, _ := os.ReadFile(s.NameFileAllTasks)
+
+		s.Compact()
+		os.WriteFile(s.NameFileAllTasks, tail, 0o644)
+
+		data, _ := open(dir).ReadAllFile()
+		if len(*data) != 3 {
+			t.Errorf("Expected 3 sets, got %d", len(*data))
+		}
+	})
+
+	t.Run("appends during compaction are kept", func(t *testing.T) {
+		dir := t.TempDir()
+		s := open(dir)
+		add(t, s, 1, 20)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			add(t, s, 21, 40)
+		}()
+		if _, err := s.Compact(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		wg.Wait()
+
+		data, _ := open(dir).ReadAllFile()
+		if len(*data) != 40 {
+			t.Errorf("Expected 40 sets, got %d", len(*data))
+		}
+	})
+}
+
+func TestWALCompaction(t *testing.T) {
+	t.Run("state survives compaction and reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tasks.wal")
+		s, err := NewWALStorage(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 5; i++ {
+			s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: i})
+		}
+		id, _ := s.AddLinksProcessList(&models.SetLinksGet{Links: []string{"a.com"}}, "")
+		s.RemoveLinksTask(id)
+		s.AddLinksJob(6, &models.SetLinksGet{Links: []string{"b.com"}}, "")
+
+		info, err := s.Compact()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if info.Sets != 5 {
+			t.Errorf("Unexpected snapshot info: %+v", info)
+		}
+		if s.size != 0 {
+			t.Errorf("Expected empty log after compaction, got %d bytes", s.size)
+		}
+
+		s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 7})
+		s.Close()
+
+		reopened, err := NewWALStorage(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.Close()
+		data, _ := reopened.ReadAllFile()
+		if len(*data) != 6 {
+			t.Errorf("Expected 6 sets, got %d", len(*data))
+		}
+		jobs, _ := reopened.ClaimPendingJobs("", time.Time{})
+		if len(jobs) != 1 || jobs[0].ListNum != 6 {
+			t.Errorf("Expected the pending job to survive, got %+v", jobs)
+		}
+	})
+
+	t.Run("records covered by the snapshot are not applied twice", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tasks.wal")
+		s, _ := NewWALStorage(path)
+		for i := 1; i <= 3; i++ {
+			s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: i})
+		}
+		fullLog, _ := os.ReadFile(path)
+		s.Compact()
+		s.Close()
+
+		// Crash after the snapshot was written but before the log was cut.
+		os.WriteFile(path, fullLog, 0o644)
+
+		reopened, err := NewWALStorage(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.Close()
+		data, _ := reopened.ReadAllFile()
+		if len(*data) != 3 {
+			t.Errorf("Expected 3 sets, got %d", len(*data))
+		}
+	})
+}
@@ -0,0 +1,517 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"status-links/internal/models"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+type SQLOptions struct {
+	Driver          string
+	DSN             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// sqlMigrations are applied in order and recorded in schema_migrations. The
+// statements stay within the subset PostgreSQL and SQLite share.
+var sqlMigrations = []struct {
+	version    int
+	statements []string
+}{
+	{1, []string{
+		`CREATE TABLE link_sets (
+			list_num   BIGINT PRIMARY KEY,
+			data       TEXT NOT NULL,
+			created_at BIGINT NOT NULL
+		)`,
+		`CREATE TABLE pending_links (
+			id         TEXT PRIMARY KEY,
+			hash       TEXT NOT NULL,
+			list_num   BIGINT NOT NULL DEFAULT 0,
+			data       TEXT NOT NULL,
+			created_at BIGINT NOT NULL
+		)`,
+		`CREATE TABLE pending_nums (
+			id         TEXT PRIMARY KEY,
+			hash       TEXT NOT NULL,
+			data       TEXT NOT NULL,
+			created_at BIGINT NOT NULL
+		)`,
+	}},
+	{2, []string{
+		`CREATE INDEX pending_links_hash ON pending_links (hash)`,
+		`CREATE INDEX pending_nums_hash ON pending_nums (hash)`,
+	}},
+	{3, []string{
+		`ALTER TABLE pending_links ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE pending_links ADD COLUMN owner TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE pending_nums ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE pending_nums ADD COLUMN owner TEXT NOT NULL DEFAULT ''`,
+	}},
+	{4, []string{
+		`CREATE TABLE storage_meta (
+			name  TEXT PRIMARY KEY,
+			value BIGINT NOT NULL
+		)`,
+	}},
+	{5, []string{
+		`INSERT INTO storage_meta (name, value)
+			SELECT 'list_num_seq', COALESCE(MAX(value), 0) FROM (
+				SELECT MAX(list_num) AS value FROM link_sets
+				UNION ALL
+				SELECT MAX(list_num) FROM pending_links
+				UNION ALL
+				SELECT value FROM storage_meta WHERE name = 'last_num'
+			) AS nums`,
+	}},
+	{6, []string{
+		`ALTER TABLE pending_links ADD COLUMN claimed_at BIGINT NOT NULL DEFAULT 0`,
+	}},
+}
+
+// migrationLockID keys the PostgreSQL advisory lock that serialises
+// migrations of replicas starting at the same time.
+const migrationLockID int64 = 0x6c696e6b73
+
+type sqlStorage struct {
+	db *sql.DB
+}
+
+func NewSQLStorage(opts SQLOptions) (*sqlStorage, error) {
+	db, err := sql.Open(opts.Driver, opts.DSN)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+
+	s := &sqlStorage{db: db}
+	if err := s.migrate(context.Background(), opts.Driver); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqlStorage) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies the missing migrations on one connection. On PostgreSQL
+// it holds an advisory lock for the whole run, so a replica waits for
+// another one's migrations and then finds them applied. SQLite serialises
+// writers on the database file itself.
+func (s *sqlStorage) migrate(ctx context.Context, driver string) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch driver {
+	case "pgx", "pgx/v5", "postgres":
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return fmt.Errorf("failed to lock migrations: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range sqlMigrations {
+		err := runTx(ctx, conn, func(tx *sql.Tx) error {
+			var applied int
+			if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = $1`, m.version).Scan(&applied); err != nil {
+				return err
+			}
+			if applied > 0 {
+				return nil
+			}
+			for _, stmt := range m.statements {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`, m.version, time.Now().Unix())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", m.version, err)
+		}
+	}
+	return nil
+}
+
+func (s *sqlStorage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return runTx(ctx, s.db, fn)
+}
+
+// runTx runs fn in a transaction of a *sql.DB or a pinned *sql.Conn.
+func runTx(ctx context.Context, db interface {
+	BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error)
+}, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStorage) ReadAllFile() (*[]models.ProcessedLinks, error) {
+	rows, err := s.db.Query(`SELECT data FROM link_sets ORDER BY list_num`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	data := make([]models.ProcessedLinks, 0)
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var set models.ProcessedLinks
+		if err := json.Unmarshal([]byte(raw), &set); err != nil {
+			return nil, err
+		}
+		data = append(data, set)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (s *sqlStorage) ReadSet(listNum int) (*models.ProcessedLinks, error) {
+	var raw string
+	err := s.db.QueryRow(`SELECT data FROM link_sets WHERE list_num = $1`, listNum).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	var set models.ProcessedLinks
+	if err := json.Unmarshal([]byte(raw), &set); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// ListSets decodes only the creation time of each set, one row at a time.
+func (s *sqlStorage) ListSets() (map[int]time.Time, error) {
+	rows, err := s.db.Query(`SELECT list_num, data FROM link_sets`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	created := make(map[int]time.Time)
+	for rows.Next() {
+		var num int
+		var raw []byte
+		if err := rows.Scan(&num, &raw); err != nil {
+			return nil, err
+		}
+		var head setHead
+		if err := json.Unmarshal(raw, &head); err != nil {
+			return nil, err
+		}
+		created[num] = head.CreatedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertSet(ctx context.Context, db execer, item *models.ProcessedLinks) error {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO link_sets (list_num, data, created_at) VALUES ($1, $2, $3)`,
+		item.ListNum, string(raw), time.Now().UnixNano())
+	return err
+}
+
+// AllocateListNum hands out the next set number from the database, so
+// replicas sharing it never give two sets the same number.
+func (s *sqlStorage) AllocateListNum() (int, error) {
+	var num int
+	err := s.db.QueryRow(`UPDATE storage_meta SET value = value + 1 WHERE name = 'list_num_seq' RETURNING value`).Scan(&num)
+	return num, err
+}
+
+func (s *sqlStorage) AddNewLinkPerm(item *models.ProcessedLinks) error {
+	return insertSet(context.Background(), s.db, item)
+}
+
+// DeleteSets removes the sets in one transaction. The highest number ever
+// stored is first saved in storage_meta, so it is not handed out again.
+func (s *sqlStorage) DeleteSets(listNums []int) error {
+	ctx := context.Background()
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO storage_meta (name, value)
+			VALUES ('last_num', (SELECT COALESCE(MAX(list_num), 0) FROM link_sets))
+			ON CONFLICT (name) DO UPDATE SET value = excluded.value WHERE storage_meta.value < excluded.value`); err != nil {
+			return err
+		}
+		for _, num := range listNums {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM link_sets WHERE list_num = $1`, num); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqlStorage) LastListNum() (int, error) {
+	var num int
+	err := s.db.QueryRow(`SELECT MAX(value) FROM (
+		SELECT COALESCE(MAX(list_num), 0) AS value FROM link_sets
+		UNION ALL
+		SELECT COALESCE(MAX(list_num), 0) FROM pending_links
+		UNION ALL
+		SELECT value FROM storage_meta WHERE name IN ('last_num', 'list_num_seq')
+	) AS nums`).Scan(&num)
+	return num, err
+}
+
+func (s *sqlStorage) AddLinksProcessList(masLinks *models.SetLinksGet, owner string) (string, error) {
+	return s.addLinksTask(ProcessTasksLinks{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
+	})
+}
+
+func (s *sqlStorage) AddLinksJob(listNum int, masLinks *models.SetLinksGet, owner string) (string, error) {
+	return s.addLinksTask(ProcessTasksLinks{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
+		ListNum:     listNum,
+	})
+}
+
+// The hash column is left empty for new tasks; it only identifies rows
+// written before task IDs existed.
+func (s *sqlStorage) addLinksTask(task ProcessTasksLinks) (string, error) {
+	raw, err := json.Marshal(task.Data)
+	if err != nil {
+		return "", err
+	}
+	_, err = s.db.Exec(`INSERT INTO pending_links (id, hash, list_num, data, created_at, owner, claimed_at) VALUES ($1, '', $2, $3, $4, $5, $6)`,
+		task.ID, task.ListNum, string(raw), task.CreatedAt.UnixNano(), task.Owner, task.ClaimedAt.UnixNano())
+	if err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+func (s *sqlStorage) AddNumProcessList(masLinks *models.SetNumsOfLinksGet, owner string) (string, error) {
+	task := newPendingTask(owner)
+	raw, err := json.Marshal(masLinks)
+	if err != nil {
+		return "", err
+	}
+	_, err = s.db.Exec(`INSERT INTO pending_nums (id, hash, data, created_at, owner) VALUES ($1, '', $2, $3, $4)`,
+		task.ID, string(raw), task.CreatedAt.UnixNano(), task.Owner)
+	if err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+func removeTask(ctx context.Context, db execer, table, id string) error {
+	res, err := db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, table), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+func (s *sqlStorage) RemoveLinksTask(id string) error {
+	return removeTask(context.Background(), s.db, "pending_links", id)
+}
+
+func (s *sqlStorage) RemoveNumsTask(id string) error {
+	return removeTask(context.Background(), s.db, "pending_nums", id)
+}
+
+// CompleteLinksTask stores the result and clears its pending task in one
+// transaction, so another replica never sees one without the other.
+func (s *sqlStorage) CompleteLinksTask(id string, item *models.ProcessedLinks) error {
+	ctx := context.Background()
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := insertSet(ctx, tx, item); err != nil {
+			return err
+		}
+		if err := removeTask(ctx, tx, "pending_links", id); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// GetPendingLinksData claims the unfinished sets with DELETE ... RETURNING,
+// so concurrent replicas never pick up the same set twice.
+func (s *sqlStorage) GetPendingLinksData() ([]models.SetLinksGet, error) {
+	rows, err := s.db.Query(`DELETE FROM pending_links WHERE list_num = 0 RETURNING data, created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type claimed struct {
+		set       models.SetLinksGet
+		createdAt int64
+	}
+	var tasks []claimed
+	for rows.Next() {
+		var raw string
+		var c claimed
+		if err := rows.Scan(&raw, &c.createdAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(raw), &c.set); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortByCreated(tasks, func(c claimed) int64 { return c.createdAt })
+	result := make([]models.SetLinksGet, len(tasks))
+	for i, c := range tasks {
+		result[i] = c.set
+	}
+	return result, nil
+}
+
+// ClaimPendingJobs takes ownership of unowned and abandoned jobs in a single
+// UPDATE, so two replicas resuming at once never both run the same job.
+func (s *sqlStorage) ClaimPendingJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	return s.claimJobs(`owner = '' OR owner = $1 OR claimed_at < $3`, owner, staleBefore)
+}
+
+func (s *sqlStorage) TakeOverJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	return s.claimJobs(`owner <> $1 AND (owner = '' OR claimed_at < $3)`, owner, staleBefore)
+}
+
+// claimJobs claims the jobs cond selects; cond sees the owner as $1 and
+// staleBefore as $3.
+func (s *sqlStorage) claimJobs(cond, owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	var stale int64
+	if !staleBefore.IsZero() {
+		stale = staleBefore.UnixNano()
+	}
+	rows, err := s.db.Query(`UPDATE pending_links SET owner = $1, attempts = attempts + 1, claimed_at = $2
+		WHERE list_num > 0 AND (`+cond+`)
+		RETURNING id, list_num, data, created_at, attempts, owner`, owner, claimClock().UnixNano(), stale)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]models.LinksJob, 0)
+	for rows.Next() {
+		var job models.LinksJob
+		var raw string
+		var createdAt int64
+		if err := rows.Scan(&job.ID, &job.ListNum, &raw, &createdAt, &job.Attempts, &job.Owner); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(raw), &job.Set); err != nil {
+			return nil, err
+		}
+		job.CreatedAt = time.Unix(0, createdAt).UTC()
+		result = append(result, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortByCreated(result, func(j models.LinksJob) int64 { return j.CreatedAt.UnixNano() })
+	return result, nil
+}
+
+func (s *sqlStorage) RenewJobClaims(owner string) error {
+	_, err := s.db.Exec(`UPDATE pending_links SET claimed_at = $1 WHERE list_num > 0 AND owner = $2`,
+		claimClock().UnixNano(), owner)
+	return err
+}
+
+func (s *sqlStorage) GetPendingNumsData() ([]models.SetNumsOfLinksGet, error) {
+	rows, err := s.db.Query(`DELETE FROM pending_nums RETURNING data, created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type claimed struct {
+		set       models.SetNumsOfLinksGet
+		createdAt int64
+	}
+	var tasks []claimed
+	for rows.Next() {
+		var raw string
+		var c claimed
+		if err := rows.Scan(&raw, &c.createdAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(raw), &c.set); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortByCreated(tasks, func(c claimed) int64 { return c.createdAt })
+	result := make([]models.SetNumsOfLinksGet, len(tasks))
+	for i, c := range tasks {
+		result[i] = c.set
+	}
+	return result, nil
+}
+
+func sortByCreated[T any](items []T, createdAt func(T) int64) {
+	slices.SortStableFunc(items, func(a, b T) int {
+		return cmp.Compare(createdAt(a), createdAt(b))
+	})
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"status-links/internal/models"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLStorage(t *testing.T) {
+	openSQL := func(t *testing.T, path string) *sqlStorage {
+		t.Helper()
+		s, err := NewSQLStorage(SQLOptions{
+			Driver:          "sqlite",
+			DSN:             "file:" + path + "?_pragma=busy_timeout(5000)",
+			MaxOpenConns:    1,
+			ConnMaxLifetime: time.Minute,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error opening database: %v", err)
+		}
+		return s
+	}
+
+	t.Run("migrations are applied once", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "links.sqlite")
+		openSQL(t, path).Close()
+
+		s := openSQL(t, path)
+		defer s.Close()
+		var versions int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&versions); err != nil {
+			t.Fatal(err)
+		}
+		if versions != len(sqlMigrations) {
+			t.Errorf("Expected %d applied migrations, got %d", len(sqlMigrations), versions)
+		}
+	})
+
+	t.Run("state survives reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "links.sqlite")
+		s := openSQL(t, path)
+
+		for _, num := range []int{2, 1} {
+			err := s.AddNewLinkPerm(&models.ProcessedLinks{
+				Answer:  models.LinksAnswer{"https://example.com/": {Status: models.StatusAvailable}},
+				ListNum: num,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		id, _ := s.AddLinksProcessList(&models.SetLinksGet{Links: []string{"a.com"}}, "")
+		s.AddLinksJob(3, &models.SetLinksGet{Links: []string{"b.com"}}, "")
+		s.AddNumProcessList(&models.SetNumsOfLinksGet{NumsLinks: []int{1}}, "")
+		if err := s.RemoveLinksTask(id); err != nil {
+			t.Fatalf("Unexpected error removing links: %v", err)
+		}
+		s.Close()
+
+		reopened := openSQL(t, path)
+		defer reopened.Close()
+
+		data, _ := reopened.ReadAllFile()
+		if len(*data) != 2 || (*data)[0].ListNum != 1 || (*data)[0].Answer["https://example.com/"].Status != models.StatusAvailable {
+			t.Errorf("Expected sets ordered by number, got %+v", *data)
+		}
+		if links, _ := reopened.GetPendingLinksData(); len(links) != 0 {
+			t.Errorf("Expected removed links to stay removed, got %v", links)
+		}
+		if jobs, _ := reopened.ClaimPendingJobs("", time.Time{}); len(jobs) != 1 || jobs[0].ListNum != 3 || jobs[0].Set.Links[0] != "b.com" {
+			t.Errorf("Expected one pending job, got %+v", jobs)
+		}
+		if nums, _ := reopened.GetPendingNumsData(); len(nums) != 1 || nums[0].NumsLinks[0] != 1 {
+			t.Errorf("Expected one pending nums set, got %v", nums)
+		}
+		if nums, _ := reopened.GetPendingNumsData(); len(nums) != 0 {
+			t.Errorf("Expected pending nums to be consumed, got %v", nums)
+		}
+	})
+
+	t.Run("pending sets are returned in order and claimed once", func(t *testing.T) {
+		s := openSQL(t, filepath.Join(t.TempDir(), "links.sqlite"))
+		defer s.Close()
+
+		for _, link := range []string{"a.com", "b.com", "c.com"} {
+			s.AddLinksProcessList(&models.SetLinksGet{Links: []string{link}}, "")
+		}
+		s.AddLinksJob(7, &models.SetLinksGet{Links: []string{"d.com"}}, "")
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var claimed []models.SetLinksGet
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sets, err := s.GetPendingLinksData()
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				mu.Lock()
+				claimed = append(claimed, sets...)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if len(claimed) != 3 {
+			t.Errorf("Expected every set claimed exactly once, got %v", claimed)
+		}
+		if jobs, _ := s.ClaimPendingJobs("", time.Time{}); len(jobs) != 1 {
+			t.Errorf("Expected the job to stay pending, got %+v", jobs)
+		}
+	})
+
+	t.Run("CompleteLinksTask stores result and clears pending together", func(t *testing.T) {
+		s := openSQL(t, filepath.Join(t.TempDir(), "links.sqlite"))
+		defer s.Close()
+
+		id, _ := s.AddLinksJob(5, &models.SetLinksGet{Links: []string{"a.com"}}, "")
+		item := &models.ProcessedLinks{Answer: models.LinksAnswer{"https://a.com/": {}}, ListNum: 5}
+		if err := s.CompleteLinksTask(id, item); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if jobs, _ := s.ClaimPendingJobs("", time.Time{}); len(jobs) != 0 {
+			t.Errorf("Expected pending job cleared, got %+v", jobs)
+		}
+		if data, _ := s.ReadAllFile(); len(*data) != 1 {
+			t.Errorf("Expected the result stored, got %+v", *data)
+		}
+
+		s.db.Exec(`DROP TABLE pending_links`)
+		err := s.CompleteLinksTask(id, &models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 6})
+		if err == nil {
+			t.Fatal("Expected an error when clearing the pending task fails")
+		}
+		if data, _ := s.ReadAllFile(); len(*data) != 1 {
+			t.Errorf("Expected the failed completion to be rolled back, got %+v", *data)
+		}
+	})
+
+	t.Run("concurrent replicas claim each job once", func(t *testing.T) {
+		s := openSQL(t, filepath.Join(t.TempDir(), "links.sqlite"))
+		defer s.Close()
+
+		for num := 1; num <= 3; num++ {
+			s.AddLinksJob(num, &models.SetLinksGet{Links: []string{"a.com"}}, "")
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		owners := make(map[int]string)
+		for _, owner := range []string{"node-a", "node-b"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				jobs, err := s.ClaimPendingJobs(owner, time.Time{})
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, job := range jobs {
+					if prev, ok := owners[job.ListNum]; ok && prev != owner {
+						t.Errorf("Job %d claimed by %s and %s", job.ListNum, prev, owner)
+					}
+					owners[job.ListNum] = owner
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(owners) != 3 {
+			t.Errorf("Expected all jobs claimed, got %v", owners)
+		}
+	})
+
+	t.Run("abandoned jobs are taken over once their lease runs out", func(t *testing.T) {
+		s := openSQL(t, filepath.Join(t.TempDir(), "links.sqlite"))
+		defer s.Close()
+
+		setClaimClock(t, leaseStart)
+		s.AddLinksJob(1, &models.SetLinksGet{Links: []string{"a.com"}}, "old-host")
+		s.AddLinksJob(2, &models.SetLinksGet{Links: []string{"b.com"}}, "node-b")
+		if jobs, _ := s.ClaimPendingJobs("node-a", leaseStart.Add(-time.Hour)); len(jobs) != 0 {
+			t.Fatalf("Expected freshly claimed jobs to be left alone, got %+v", jobs)
+		}
+
+		setClaimClock(t, leaseStart.Add(2*time.Hour))
+		if err := s.RenewJobClaims("node-b"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		jobs, err := s.ClaimPendingJobs("node-a", leaseStart.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(jobs) != 1 || jobs[0].ListNum != 1 || jobs[0].Owner != "node-a" {
+			t.Errorf("Expected node-a to take over only the abandoned job 1, got %+v", jobs)
+		}
+	})
+
+	t.Run("replicas sharing a database get distinct numbers", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "links.sqlite")
+		a := openSQL(t, path)
+		defer a.Close()
+		b := openSQL(t, path)
+		defer b.Close()
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		seen := make(map[int]bool)
+		for _, s := range []*sqlStorage{a, b} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 10 {
+					num, err := s.AllocateListNum()
+					if err != nil {
+						t.Errorf("Unexpected error: %v", err)
+						return
+					}
+					mu.Lock()
+					if seen[num] {
+						t.Errorf("Number %d handed out twice", num)
+					}
+					seen[num] = true
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if last, _ := a.LastListNum(); last != 20 {
+			t.Errorf("Expected last number 20, got %d", last)
+		}
+	})
+
+	t.Run("storing a taken number fails instead of overwriting", func(t *testing.T) {
+		s := openSQL(t, filepath.Join(t.TempDir(), "links.sqlite"))
+		defer s.Close()
+
+		first := &models.ProcessedLinks{Answer: models.LinksAnswer{"https://a.com/": {Status: models.StatusAvailable}}, ListNum: 1}
+		if err := s.AddNewLinkPerm(first); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		second := &models.ProcessedLinks{Answer: models.LinksAnswer{"https://b.com/": {Status: models.StatusAvailable}}, ListNum: 1}
+		if err := s.AddNewLinkPerm(second); err == nil {
+			t.Error("Expected an error for a number already stored")
+		}
+
+		set, err := s.ReadSet(1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, ok := set.Answer["https://a.com/"]; !ok {
+			t.Errorf("Expected the first set to be kept, got %+v", set.Answer)
+		}
+	})
+
+	t.Run("remove unknown task", func(t *testing.T) {
+		s := openSQL(t, filepath.Join(t.TempDir(), "links.sqlite"))
+		defer s.Close()
+
+		if err := s.RemoveLinksTask("missing"); !os.IsNotExist(err) {
+			t.Errorf("Expected not-exist error, got %v", err)
+		}
+		if err := s.RemoveNumsTask("missing"); !os.IsNotExist(err) {
+			t.Errorf("Expected not-exist error, got %v", err)
+		}
+	})
+}
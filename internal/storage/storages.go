@@ -1,20 +1,75 @@
 package storage
 
-import "status-links/internal/models"
+import (
+	"status-links/internal/models"
+	"time"
+)
 
 type TempStorage interface {
 	UploadAllData(bs *[]models.ProcessedLinks)
 	UploadNewData(bs *models.ProcessedLinks) int
+	UpdateData(bs *models.ProcessedLinks)
 	FindKeys(list *models.SetNumsOfLinksGet) (*[]models.LinksAnswer, error)
+	// FindSets is FindKeys returning whole sets, for callers that need their
+	// numbers and timestamps too.
+	FindSets(list *models.SetNumsOfLinksGet) (*[]models.ProcessedLinks, error)
 	ReturnMaxIndex() int
+	RestoreMaxIndex(n int)
+	Expire(cutoff time.Time, maxSets int, keep func(listNum int) bool) []int
 }
 type ReliableStorage interface {
 	ReadAllFile() (*[]models.ProcessedLinks, error)
+	ReadSet(listNum int) (*models.ProcessedLinks, error)
 	AddNewLinkPerm(item *models.ProcessedLinks) error
-	AddLinksProcessList(masLinks *models.SetLinksGet) (string, error)
-	AddNumProcessList(masLinks *models.SetNumsOfLinksGet) (string, error)
-	RemoveLinksProcessByHash(targetHash string) error
-	RemoveNumsProcessByHash(targetHash string) error
+	AddLinksProcessList(masLinks *models.SetLinksGet, owner string) (string, error)
+	AddLinksJob(listNum int, masLinks *models.SetLinksGet, owner string) (string, error)
+	AddNumProcessList(masLinks *models.SetNumsOfLinksGet, owner string) (string, error)
+	RemoveLinksTask(id string) error
+	RemoveNumsTask(id string) error
 	GetPendingLinksData() ([]models.SetLinksGet, error)
 	GetPendingNumsData() ([]models.SetNumsOfLinksGet, error)
+	// ClaimPendingJobs hands owner the jobs that are unowned, already its
+	// own, or held by an owner whose claim is older than staleBefore.
+	ClaimPendingJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error)
+	// TakeOverJobs is ClaimPendingJobs without owner's own jobs, for an
+	// owner that is already running those.
+	TakeOverJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error)
+	// RenewJobClaims refreshes the claims of owner's jobs, so they do not
+	// look abandoned while it is still running them.
+	RenewJobClaims(owner string) error
+	DeleteSets(listNums []int) error
+	// LastListNum is the highest number held by a set, stored or deleted,
+	// or by a pending job.
+	LastListNum() (int, error)
+}
+
+// CacheReporter is implemented by temp storages that cache a subset of the
+// sets and can report how well the cache works.
+type CacheReporter interface {
+	CacheStats() models.CacheStats
+}
+
+// TaskCompleter is implemented by storages that can store a job's result and
+// clear its pending entry atomically.
+type TaskCompleter interface {
+	CompleteLinksTask(id string, item *models.ProcessedLinks) error
+}
+
+// NumberAllocator is implemented by reliable storages shared between
+// replicas. They hand out set numbers themselves instead of leaving it to
+// each replica's temp storage.
+type NumberAllocator interface {
+	AllocateListNum() (int, error)
+}
+
+// SetLister is implemented by reliable storages that can list the numbers
+// and creation times of their sets without reading the sets themselves.
+type SetLister interface {
+	ListSets() (map[int]time.Time, error)
+}
+
+// IndexLoader is implemented by temp storages that can start from a list of
+// sets and read the sets themselves on demand.
+type IndexLoader interface {
+	UploadIndex(created map[int]time.Time) error
 }
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"fmt"
+	"status-links/internal/models"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultTempShards = 32
+
+type tempShard struct {
+	mu   sync.RWMutex
+	sets map[int]models.ProcessedLinks
+}
+
+// stripedTempStorage spreads sets over shards by number, each behind its own
+// RWMutex, so report generation reading many sets in parallel does not
+// contend on one lock. The counter is atomic and never takes a lock.
+type stripedTempStorage struct {
+	shards  []tempShard
+	lastNum atomic.Int64
+	// expiredUpTo is the highest number Expire removed.
+	expiredUpTo atomic.Int64
+}
+
+func NewStripedTempStorage(shards int) *stripedTempStorage {
+	if shards <= 0 {
+		shards = defaultTempShards
+	}
+	s := &stripedTempStorage{shards: make([]tempShard, shards)}
+	for i := range s.shards {
+		s.shards[i].sets = make(map[int]models.ProcessedLinks)
+	}
+	return s
+}
+
+func (s *stripedTempStorage) shard(num int) *tempShard {
+	return &s.shards[uint(num)%uint(len(s.shards))]
+}
+
+func (s *stripedTempStorage) put(set models.ProcessedLinks) {
+	shard := s.shard(set.ListNum)
+	shard.mu.Lock()
+	shard.sets[set.ListNum] = set
+	shard.mu.Unlock()
+}
+
+// advance moves the counter up to n unless it is already past it.
+func (s *stripedTempStorage) advance(n int) {
+	for {
+		current := s.lastNum.Load()
+		if int64(n) <= current || s.lastNum.CompareAndSwap(current, int64(n)) {
+			return
+		}
+	}
+}
+
+func (s *stripedTempStorage) UploadAllData(bs *[]models.ProcessedLinks) {
+	for _, set := range *bs {
+		s.put(set)
+		s.advance(set.ListNum)
+	}
+}
+
+func (s *stripedTempStorage) UploadNewData(bs *models.ProcessedLinks) int {
+	num := int(s.lastNum.Add(1))
+	set := *bs
+	set.ListNum = num
+	s.put(set)
+	return num
+}
+
+func (s *stripedTempStorage) UpdateData(bs *models.ProcessedLinks) {
+	s.put(*bs)
+	s.advance(bs.ListNum)
+}
+
+func (s *stripedTempStorage) FindKeys(list *models.SetNumsOfLinksGet) (*[]models.LinksAnswer, error) {
+	return answersOf(s.FindSets(list))
+}
+
+func (s *stripedTempStorage) FindSets(list *models.SetNumsOfLinksGet) (*[]models.ProcessedLinks, error) {
+	bs := make([]models.ProcessedLinks, len(list.NumsLinks))
+	for i, num := range list.NumsLinks {
+		shard := s.shard(num)
+		shard.mu.RLock()
+		v, ok := shard.sets[num]
+		shard.mu.RUnlock()
+		if !ok {
+			if num > 0 && int64(num) <= s.expiredUpTo.Load() {
+				return nil, fmt.Errorf("key %d: %w", num, ErrSetExpired)
+			}
+			return nil, fmt.Errorf("key %d does not exist", num)
+		}
+		bs[i] = v
+	}
+	return &bs, nil
+}
+
+func (s *stripedTempStorage) ReturnMaxIndex() int {
+	return int(s.lastNum.Load())
+}
+
+func (s *stripedTempStorage) RestoreMaxIndex(n int) {
+	s.advance(n)
+}
+
+// Expire locks every shard, so the count limit sees a consistent view.
+func (s *stripedTempStorage) Expire(cutoff time.Time, maxSets int, keep func(listNum int) bool) []int {
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		defer s.shards[i].mu.Unlock()
+	}
+
+	created := make(map[int]time.Time)
+	for i := range s.shards {
+		for num, set := range s.shards[i].sets {
+			created[num] = set.CreatedAt
+		}
+	}
+
+	expired := selectExpired(created, func(t time.Time) time.Time { return t }, cutoff, maxSets, keep)
+	for _, num := range expired {
+		delete(s.shard(num).sets, num)
+	}
+	if len(expired) > 0 && int64(expired[len(expired)-1]) > s.expiredUpTo.Load() {
+		s.expiredUpTo.Store(int64(expired[len(expired)-1]))
+	}
+	return expired
+}
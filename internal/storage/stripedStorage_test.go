@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"status-links/internal/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStripedTempStorage(t *testing.T) {
+	t.Run("numbers continue after uploaded data", func(t *testing.T) {
+		s := NewStripedTempStorage(4)
+		s.UploadAllData(&[]models.ProcessedLinks{*testSet(5), *testSet(2)})
+
+		if num := s.UploadNewData(testSet(0)); num != 6 {
+			t.Errorf("Expected 6, got %d", num)
+		}
+		s.UpdateData(testSet(3))
+		if s.ReturnMaxIndex() != 6 {
+			t.Errorf("Expected max index to stay 6, got %d", s.ReturnMaxIndex())
+		}
+		s.RestoreMaxIndex(9)
+		if num := s.UploadNewData(testSet(0)); num != 10 {
+			t.Errorf("Expected 10 after restore, got %d", num)
+		}
+	})
+
+	t.Run("FindKeys returns sets across shards", func(t *testing.T) {
+		s := NewStripedTempStorage(4)
+		for num := 1; num <= 9; num++ {
+			s.UpdateData(testSet(num))
+		}
+
+		result, err := s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{9, 1, 4}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, ok := (*result)[0]["https://example.com/9"]; !ok {
+			t.Errorf("Expected set 9 first, got %+v", *result)
+		}
+		if _, err := s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{10}}); err == nil {
+			t.Error("Expected an error for an unknown number")
+		}
+	})
+
+	t.Run("Expire sees every shard", func(t *testing.T) {
+		s := NewStripedTempStorage(4)
+		for num := 1; num <= 6; num++ {
+			s.UpdateData(testSet(num))
+		}
+
+		expired := s.Expire(time.Time{}, 2, func(num int) bool { return num == 1 })
+		if !reflect.DeepEqual(expired, []int{2, 3, 4, 5}) {
+			t.Errorf("Expected sets 2-5 expired, got %v", expired)
+		}
+		if _, err := s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{3}}); !errors.Is(err, ErrSetExpired) {
+			t.Errorf("Expected ErrSetExpired, got %v", err)
+		}
+
+		s.UpdateData(testSet(8))
+		if _, err := s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{7}}); err == nil || errors.Is(err, ErrSetExpired) {
+			t.Errorf("Expected a never stored number to not exist, got %v", err)
+		}
+	})
+
+	t.Run("concurrent writers get distinct numbers", func(t *testing.T) {
+		s := NewStripedTempStorage(8)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		seen := make(map[int]bool)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				num := s.UploadNewData(testSet(0))
+				s.ReturnMaxIndex()
+				s.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{num}})
+				mu.Lock()
+				seen[num] = true
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if len(seen) != 100 || s.ReturnMaxIndex() != 100 {
+			t.Errorf("Expected 100 distinct numbers, got %d (max %d)", len(seen), s.ReturnMaxIndex())
+		}
+	})
+}
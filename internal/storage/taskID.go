@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newTaskID returns a ULID: 48 bits of Unix milliseconds followed by 80
+// random bits in Crockford base32. IDs sort by creation time.
+func newTaskID() string {
+	return newTaskIDAt(time.Now())
+}
+
+func newTaskIDAt(t time.Time) string {
+	var raw [16]byte
+	binary.BigEndian.PutUint64(raw[:8], uint64(t.UnixMilli())<<16)
+	rand.Read(raw[6:])
+
+	// 128 bits as 26 base32 digits, the first holding only the top 3 bits.
+	hi := binary.BigEndian.Uint64(raw[:8])
+	lo := binary.BigEndian.Uint64(raw[8:])
+	var id [26]byte
+	for i := 25; i >= 0; i-- {
+		id[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(id[:])
+}
+
+// claimClock stamps job claims and their renewals; tests replace it to
+// move claims in time.
+var claimClock = time.Now
+
+func newPendingTask(owner string) PendingTask {
+	return PendingTask{
+		ID:        newTaskID(),
+		CreatedAt: time.Now().UTC(),
+		Owner:     owner,
+		ClaimedAt: claimClock().UTC(),
+	}
+}
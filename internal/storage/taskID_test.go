@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskID(t *testing.T) {
+	t.Run("IDs are unique", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for i := 0; i < 1000; i++ {
+			id := newTaskID()
+			if len(id) != 26 {
+				t.Fatalf("Expected 26 characters, got %q", id)
+			}
+			if seen[id] {
+				t.Fatalf("Duplicate ID %s", id)
+			}
+			seen[id] = true
+		}
+	})
+
+	t.Run("IDs sort by creation time", func(t *testing.T) {
+		now := time.Now()
+		earlier := newTaskIDAt(now)
+		later := newTaskIDAt(now.Add(time.Millisecond))
+		if strings.Compare(earlier, later) >= 0 {
+			t.Errorf("Expected %s < %s", earlier, later)
+		}
+	})
+}
@@ -1,15 +1,26 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"status-links/internal/models"
 	"sync"
+	"time"
 )
 
+// ErrSetExpired is returned for a link set number that retention removed.
+// Other missing numbers, e.g. ones another replica handed out, are reported
+// as not existing.
+var ErrSetExpired = errors.New("link set expired")
+
 type tempStorageMap struct {
 	sets    map[int]models.ProcessedLinks
 	lastNum int
-	mu      sync.Mutex
+	// expiredUpTo is the highest number Expire removed; missing numbers up
+	// to it are expired.
+	expiredUpTo int
+	mu          sync.Mutex
 }
 
 func NewTempStorage() *tempStorageMap {
@@ -40,21 +51,100 @@ func (s *tempStorageMap) UploadNewData(bs *models.ProcessedLinks) int {
 	return s.lastNum
 }
 
+func (s *tempStorageMap) UpdateData(bs *models.ProcessedLinks) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sets[bs.ListNum] = *bs
+	if bs.ListNum > s.lastNum {
+		s.lastNum = bs.ListNum
+	}
+}
+
 func (s *tempStorageMap) FindKeys(list *models.SetNumsOfLinksGet) (*[]models.LinksAnswer, error) {
+	return answersOf(s.FindSets(list))
+}
+
+func (s *tempStorageMap) FindSets(list *models.SetNumsOfLinksGet) (*[]models.ProcessedLinks, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	bs := make([]models.LinksAnswer, len(list.NumsLinks))
+	bs := make([]models.ProcessedLinks, len(list.NumsLinks))
 	for i, num := range list.NumsLinks {
 		v, ok := s.sets[num]
 		if !ok {
+			if num > 0 && num <= s.expiredUpTo {
+				return nil, fmt.Errorf("key %d: %w", num, ErrSetExpired)
+			}
 			return nil, fmt.Errorf("key %d does not exist", num)
 		}
-		bs[i] = v.Answer
+		bs[i] = v
 	}
 	return &bs, nil
 }
 
+func answersOf(sets *[]models.ProcessedLinks, err error) (*[]models.LinksAnswer, error) {
+	if err != nil {
+		return nil, err
+	}
+	answers := make([]models.LinksAnswer, len(*sets))
+	for i, set := range *sets {
+		answers[i] = set.Answer
+	}
+	return &answers, nil
+}
+
 func (s *tempStorageMap) ReturnMaxIndex() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.lastNum
 }
+
+// RestoreMaxIndex moves the counter up to n, so numbers of sets removed
+// before a restart are not handed out again.
+func (s *tempStorageMap) RestoreMaxIndex(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > s.lastNum {
+		s.lastNum = n
+	}
+}
+
+// Expire removes sets created before cutoff and, when maxSets is positive,
+// the oldest sets beyond that count. Sets without a creation time are only
+// removed by count, and sets for which keep returns true are never removed.
+// The removed numbers are returned in ascending order.
+func (s *tempStorageMap) Expire(cutoff time.Time, maxSets int, keep func(listNum int) bool) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := selectExpired(s.sets, func(set models.ProcessedLinks) time.Time { return set.CreatedAt }, cutoff, maxSets, keep)
+	for _, num := range expired {
+		delete(s.sets, num)
+		s.expiredUpTo = max(s.expiredUpTo, num)
+	}
+	return expired
+}
+
+func selectExpired[T any](sets map[int]T, createdAt func(T) time.Time, cutoff time.Time, maxSets int, keep func(listNum int) bool) []int {
+	nums := make([]int, 0, len(sets))
+	for num := range sets {
+		nums = append(nums, num)
+	}
+	slices.Sort(nums)
+
+	expired := make([]int, 0)
+	remaining := len(nums)
+	for _, num := range nums {
+		if keep != nil && keep(num) {
+			continue
+		}
+		created := createdAt(sets[num])
+		tooOld := !cutoff.IsZero() && !created.IsZero() && created.Before(cutoff)
+		tooMany := maxSets > 0 && remaining > maxSets
+		if tooOld || tooMany {
+			expired = append(expired, num)
+			remaining--
+		}
+	}
+	return expired
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"math/rand/v2"
+	"status-links/internal/models"
+	"strconv"
+	"testing"
+)
+
+const (
+	benchSets       = 10000
+	benchReportSize = 20
+)
+
+// BenchmarkFindKeysParallel simulates many reports being generated at once,
+// each reading benchReportSize random sets. The "with writers" variants add
+// one new set per ten reports.
+func BenchmarkFindKeysParallel(b *testing.B) {
+	impls := []struct {
+		name string
+		new  func() TempStorage
+	}{
+		{"map", func() TempStorage { return NewTempStorage() }},
+		{"striped", func() TempStorage { return NewStripedTempStorage(defaultTempShards) }},
+		{"lru", func() TempStorage { return NewLRUTempStorage(nil, LRUOptions{MaxEntries: 2 * benchSets}) }},
+	}
+
+	for _, impl := range impls {
+		for _, writers := range []bool{false, true} {
+			name := impl.name
+			if writers {
+				name += "/with_writers"
+			}
+			b.Run(name, func(b *testing.B) {
+				s := impl.new()
+				sets := make([]models.ProcessedLinks, benchSets)
+				for i := range sets {
+					sets[i] = models.ProcessedLinks{
+						Answer:  models.LinksAnswer{"https://example.com/" + strconv.Itoa(i): {Status: models.StatusAvailable}},
+						ListNum: i + 1,
+					}
+				}
+				s.UploadAllData(&sets)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					req := &models.SetNumsOfLinksGet{NumsLinks: make([]int, benchReportSize)}
+					for i := 0; pb.Next(); i++ {
+						if writers && i%10 == 0 {
+							s.UploadNewData(&sets[0])
+							continue
+						}
+						for j := range req.NumsLinks {
+							req.NumsLinks[j] = rand.IntN(benchSets) + 1
+						}
+						if _, err := s.FindKeys(req); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			})
+		}
+	}
+}
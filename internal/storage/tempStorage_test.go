@@ -1,10 +1,14 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
+	"reflect"
 	"status-links/internal/models"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestTempStorageMap(t *testing.T) {
@@ -13,7 +17,7 @@ func TestTempStorageMap(t *testing.T) {
 
 		data := &models.ProcessedLinks{
 			Answer: models.LinksAnswer{
-				"https://example.com": "available",
+				"https://example.com": {Status: "available"},
 			},
 			ListNum: 0,
 		}
@@ -34,7 +38,7 @@ func TestTempStorageMap(t *testing.T) {
 		if !exists {
 			t.Error("Expected data to be stored with key 1")
 		}
-		if stored.Answer["https://example.com"] != "available" {
+		if stored.Answer["https://example.com"].Status != "available" {
 			t.Errorf("Expected stored data to match input")
 		}
 	})
@@ -44,11 +48,11 @@ func TestTempStorageMap(t *testing.T) {
 
 		batch := []models.ProcessedLinks{
 			{
-				Answer:  models.LinksAnswer{"link1": "available"},
+				Answer:  models.LinksAnswer{"link1": {Status: "available"}},
 				ListNum: 1,
 			},
 			{
-				Answer:  models.LinksAnswer{"link2": "unavailable"},
+				Answer:  models.LinksAnswer{"link2": {Status: "unavailable"}},
 				ListNum: 2,
 			},
 		}
@@ -67,10 +71,10 @@ func TestTempStorageMap(t *testing.T) {
 			t.Errorf("Expected 2 results, got %d", len(*result))
 		}
 
-		if (*result)[0]["link1"] != "available" {
+		if (*result)[0]["link1"].Status != "available" {
 			t.Error("Expected first result to contain link1 with status 'available'")
 		}
-		if (*result)[1]["link2"] != "unavailable" {
+		if (*result)[1]["link2"].Status != "unavailable" {
 			t.Error("Expected second result to contain link2 with status 'unavailable'")
 		}
 	})
@@ -80,14 +84,14 @@ func TestTempStorageMap(t *testing.T) {
 
 		batch := []models.ProcessedLinks{
 			{
-				Answer:  models.LinksAnswer{"link1": "available"},
+				Answer:  models.LinksAnswer{"link1": {Status: "available"}},
 				ListNum: 5,
 			},
 		}
 		storage.UploadAllData(&batch)
 
 		newData := &models.ProcessedLinks{
-			Answer: models.LinksAnswer{"newlink": "unavailable"},
+			Answer: models.LinksAnswer{"newlink": {Status: "unavailable"}},
 		}
 
 		num := storage.UploadNewData(newData)
@@ -100,14 +104,37 @@ func TestTempStorageMap(t *testing.T) {
 		}
 	})
 
+	t.Run("UpdateData stores by number and moves counter forward", func(t *testing.T) {
+		storage := NewTempStorage()
+
+		storage.UpdateData(&models.ProcessedLinks{
+			Answer:  models.LinksAnswer{"link": {Status: "available"}},
+			ListNum: 4,
+		})
+		if storage.ReturnMaxIndex() != 4 {
+			t.Errorf("Expected max index 4, got %d", storage.ReturnMaxIndex())
+		}
+
+		storage.UpdateData(&models.ProcessedLinks{
+			Answer:  models.LinksAnswer{"link": {Status: "unavailable"}},
+			ListNum: 2,
+		})
+		if storage.ReturnMaxIndex() != 4 {
+			t.Errorf("Expected max index to stay 4, got %d", storage.ReturnMaxIndex())
+		}
+		if storage.sets[2].Answer["link"].Status != "unavailable" {
+			t.Error("Expected set 2 to be stored")
+		}
+	})
+
 	t.Run("Mixed statuses work correctly", func(t *testing.T) {
 		storage := NewTempStorage()
 
 		data := &models.ProcessedLinks{
 			Answer: models.LinksAnswer{
-				"https://google.com":  "available",
-				"https://invalid.com": "unavailable",
-				"http://example.com":  "available",
+				"https://google.com":  {Status: "available"},
+				"https://invalid.com": {Status: "unavailable"},
+				"http://example.com":  {Status: "available"},
 			},
 		}
 
@@ -119,13 +146,13 @@ func TestTempStorageMap(t *testing.T) {
 		}
 
 		answer := (*result)[0]
-		if answer["https://google.com"] != "available" {
+		if answer["https://google.com"].Status != "available" {
 			t.Error("Expected google.com to be available")
 		}
-		if answer["https://invalid.com"] != "unavailable" {
+		if answer["https://invalid.com"].Status != "unavailable" {
 			t.Error("Expected invalid.com to be unavailable")
 		}
-		if answer["http://example.com"] != "available" {
+		if answer["http://example.com"].Status != "available" {
 			t.Error("Expected example.com to be available")
 		}
 	})
@@ -139,7 +166,7 @@ func TestTempStorageMap(t *testing.T) {
 			go func(n int) {
 				defer wg.Done()
 				data := &models.ProcessedLinks{
-					Answer: models.LinksAnswer{fmt.Sprintf("link%d", n): "available"},
+					Answer: models.LinksAnswer{fmt.Sprintf("link%d", n): {Status: "available"}},
 				}
 				storage.UploadNewData(data)
 			}(i)
@@ -154,4 +181,89 @@ func TestTempStorageMap(t *testing.T) {
 			t.Errorf("Expected 100 items, got %d", len(storage.sets))
 		}
 	})
+
+	t.Run("Expire removes old sets and keeps the newest", func(t *testing.T) {
+		storage := NewTempStorage()
+		now := time.Now()
+		storage.UploadAllData(&[]models.ProcessedLinks{
+			{ListNum: 1, CreatedAt: now.Add(-3 * time.Hour)},
+			{ListNum: 2},
+			{ListNum: 3, CreatedAt: now.Add(-2 * time.Hour)},
+			{ListNum: 4, CreatedAt: now},
+			{ListNum: 5, CreatedAt: now},
+		})
+
+		expired := storage.Expire(now.Add(-time.Hour), 0, func(num int) bool { return num == 3 })
+		if !reflect.DeepEqual(expired, []int{1}) {
+			t.Errorf("Expected only set 1 expired by age, got %v", expired)
+		}
+
+		expired = storage.Expire(time.Time{}, 2, nil)
+		if !reflect.DeepEqual(expired, []int{2, 3}) {
+			t.Errorf("Expected sets 2 and 3 expired by count, got %v", expired)
+		}
+		if len(storage.sets) != 2 {
+			t.Errorf("Expected 2 sets left, got %d", len(storage.sets))
+		}
+	})
+
+	t.Run("FindKeys reports expired numbers", func(t *testing.T) {
+		storage := NewTempStorage()
+		storage.UploadNewData(&models.ProcessedLinks{CreatedAt: time.Now()})
+		storage.Expire(time.Now().Add(time.Hour), 0, nil)
+
+		_, err := storage.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{1}})
+		if !errors.Is(err, ErrSetExpired) {
+			t.Errorf("Expected ErrSetExpired, got %v", err)
+		}
+		_, err = storage.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{2}})
+		if err == nil || errors.Is(err, ErrSetExpired) {
+			t.Errorf("Expected a plain missing-key error, got %v", err)
+		}
+
+		// A number handed out elsewhere, above what retention removed.
+		storage.UpdateData(&models.ProcessedLinks{ListNum: 4, CreatedAt: time.Now()})
+		_, err = storage.FindKeys(&models.SetNumsOfLinksGet{NumsLinks: []int{3}})
+		if err == nil || errors.Is(err, ErrSetExpired) {
+			t.Errorf("Expected a never stored number to not exist, got %v", err)
+		}
+	})
+
+	t.Run("RestoreMaxIndex only moves the counter forward", func(t *testing.T) {
+		storage := NewTempStorage()
+		storage.RestoreMaxIndex(5)
+		storage.RestoreMaxIndex(3)
+		if num := storage.UploadNewData(&models.ProcessedLinks{}); num != 6 {
+			t.Errorf("Expected next number 6, got %d", num)
+		}
+	})
+}
+
+func TestFindSets(t *testing.T) {
+	storages := map[string]func() TempStorage{
+		"map":     func() TempStorage { return NewTempStorage() },
+		"striped": func() TempStorage { return NewStripedTempStorage(4) },
+		"lru": func() TempStorage {
+			return NewLRUTempStorage(NewReliableStorage(filepath.Join(t.TempDir(), "all.json"), filepath.Join(t.TempDir(), "links.json"), filepath.Join(t.TempDir(), "nums.json")), LRUOptions{})
+		},
+	}
+	for name, newStorage := range storages {
+		t.Run(name+" returns whole sets in request order", func(t *testing.T) {
+			s := newStorage()
+			for num := 1; num <= 3; num++ {
+				s.UpdateData(testSet(num))
+			}
+
+			sets, err := s.FindSets(&models.SetNumsOfLinksGet{NumsLinks: []int{3, 1}})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(*sets) != 2 || (*sets)[0].ListNum != 3 || (*sets)[1].ListNum != 1 || (*sets)[0].CreatedAt.IsZero() {
+				t.Errorf("Unexpected sets: %+v", *sets)
+			}
+			if _, err := s.FindSets(&models.SetNumsOfLinksGet{NumsLinks: []int{7}}); err == nil {
+				t.Error("Expected an error for an unknown number")
+			}
+		})
+	}
 }
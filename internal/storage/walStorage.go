@@ -0,0 +1,633 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"status-links/internal/models"
+	"sync"
+	"time"
+)
+
+// Each record is a little-endian uint32 payload length, a CRC-32C of the
+// payload and the JSON-encoded walRecord itself.
+const (
+	walHeaderSize    = 8
+	walMaxRecordSize = 64 << 20
+)
+
+const (
+	walOpAddSet      = "add_set"
+	walOpAddLinks    = "add_links"
+	walOpAddNums     = "add_nums"
+	walOpRemoveLinks = "remove_links"
+	walOpRemoveNums  = "remove_nums"
+	walOpClearLinks  = "clear_links"
+	walOpClearNums   = "clear_nums"
+	walOpClaimJobs   = "claim_jobs"
+	walOpRenewJobs   = "renew_jobs"
+	walOpTakeOver    = "take_over_jobs"
+	walOpDeleteSets  = "delete_sets"
+)
+
+var walTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	errWALCorrupt = errors.New("corrupt wal record")
+	// errWALTorn is a record cut short by the end of the file, which is what
+	// a crash in the middle of an append leaves behind.
+	errWALTorn = errors.New("torn wal record")
+)
+
+type walRecord struct {
+	Seq   uint64                 `json:"seq"`
+	Op    string                 `json:"op"`
+	Set   *models.ProcessedLinks `json:"set,omitempty"`
+	Links *ProcessTasksLinks     `json:"links,omitempty"`
+	Nums  *ProcessTasksNums      `json:"nums,omitempty"`
+	ID    string                 `json:"id,omitempty"`
+	Owner string                 `json:"owner,omitempty"`
+	Sets  []int                  `json:"sets,omitempty"`
+	// At is when a claim or renewal was made; claims also record the
+	// StaleBefore they were made with, so replay takes the same jobs.
+	At          time.Time `json:"at,omitzero"`
+	StaleBefore time.Time `json:"stale_before,omitzero"`
+	// Hash is how remove records named their task before task IDs.
+	Hash string `json:"hash,omitempty"`
+}
+
+type walSnapshot struct {
+	Seq          uint64                  `json:"seq"`
+	LastNum      int                     `json:"last_num"`
+	Sets         []models.ProcessedLinks `json:"sets"`
+	PendingLinks []ProcessTasksLinks     `json:"pending_links"`
+	PendingNums  []ProcessTasksNums      `json:"pending_nums"`
+}
+
+type walStorage struct {
+	path      string
+	mu        sync.Mutex
+	muCompact sync.Mutex
+	file      *os.File
+	size      int64
+	seq       uint64
+	lastNum   int
+
+	sets         []models.ProcessedLinks
+	pendingLinks []ProcessTasksLinks
+	pendingNums  []ProcessTasksNums
+}
+
+func NewWALStorage(path string) (*walStorage, error) {
+	s := &walStorage{path: path}
+
+	_, statErr := os.Stat(path)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.file = file
+
+	if os.IsNotExist(statErr) {
+		if err := syncDir(filepath.Dir(path)); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := s.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *walStorage) loadSnapshot() error {
+	name := snapshotName(s.path)
+	if !fileExists(name) {
+		return nil
+	}
+	if err := recoverJSONFile(name, nil, func() any { return &walSnapshot{} }, &walSnapshot{}); err != nil {
+		return err
+	}
+
+	var snap walSnapshot
+	if err := decodeJSONFile(name, nil, &snap); err != nil {
+		return fmt.Errorf("failed to decode wal snapshot: %w", err)
+	}
+	s.seq = snap.Seq
+	s.lastNum = snap.LastNum
+	s.sets = snap.Sets
+	s.pendingLinks = snap.PendingLinks
+	s.pendingNums = snap.PendingNums
+	for i := range s.pendingLinks {
+		s.pendingLinks[i].upgradeLegacy()
+	}
+	for i := range s.pendingNums {
+		s.pendingNums[i].upgradeLegacy()
+	}
+	return nil
+}
+
+func (s *walStorage) replay() error {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var offset int64
+	for {
+		rec, n, err := readWALRecord(s.file)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if err == errWALCorrupt {
+				// Only the last record can be half written; a bad record
+				// with more data after it is damage the log can not repair.
+				tail, tailErr := s.isTail(offset + n)
+				if tailErr != nil {
+					return tailErr
+				}
+				if !tail {
+					return fmt.Errorf("%w at offset %d of %s, followed by more records", errWALCorrupt, offset, s.path)
+				}
+			}
+			slog.Warn("Truncating torn WAL tail", "file", s.path, "offset", offset, "error", err)
+			if err := s.file.Truncate(offset); err != nil {
+				return fmt.Errorf("failed to truncate wal: %w", err)
+			}
+			if err := s.file.Sync(); err != nil {
+				return err
+			}
+			break
+		}
+		// Records up to the snapshot's sequence survive a crash between
+		// writing the snapshot and rewriting the log; they are already applied.
+		if rec.Seq == 0 || rec.Seq > s.seq {
+			s.apply(rec)
+			if rec.Seq > s.seq {
+				s.seq = rec.Seq
+			}
+		}
+		offset += n
+	}
+
+	s.size = offset
+	_, err := s.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// isTail reports whether nothing but zeros, which some file systems leave
+// after a crash, follows offset.
+func (s *walStorage) isTail(offset int64) (bool, error) {
+	info, err := s.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if offset >= info.Size() {
+		return true, nil
+	}
+
+	buf := make([]byte, 32<<10)
+	rest := io.NewSectionReader(s.file, offset, info.Size()-offset)
+	for {
+		n, err := rest.Read(buf)
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false, nil
+			}
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// readWALRecord returns errWALTorn when the file ends inside the record and
+// errWALCorrupt, with the bytes the record claims, when it is complete but
+// invalid.
+func readWALRecord(r io.Reader) (walRecord, int64, error) {
+	var rec walRecord
+	header := make([]byte, walHeaderSize)
+	if n, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF && n == 0 {
+			return rec, 0, io.EOF
+		}
+		return rec, 0, errWALTorn
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	if length == 0 || length > walMaxRecordSize {
+		return rec, walHeaderSize, errWALCorrupt
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return rec, 0, errWALTorn
+	}
+	n := int64(walHeaderSize + len(payload))
+	if crc32.Checksum(payload, walTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return rec, n, errWALCorrupt
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, n, errWALCorrupt
+	}
+	return rec, n, nil
+}
+
+func (r walRecord) taskID() string {
+	if r.ID == "" {
+		return r.Hash
+	}
+	return r.ID
+}
+
+func (s *walStorage) apply(rec walRecord) {
+	switch rec.Op {
+	case walOpAddSet:
+		if rec.Set != nil {
+			s.sets = append(s.sets, *rec.Set)
+			s.lastNum = max(s.lastNum, rec.Set.ListNum)
+		}
+	case walOpDeleteSets:
+		s.sets = slices.DeleteFunc(s.sets, func(set models.ProcessedLinks) bool {
+			return slices.Contains(rec.Sets, set.ListNum)
+		})
+	case walOpAddLinks:
+		if rec.Links != nil {
+			rec.Links.upgradeLegacy()
+			s.pendingLinks = append(s.pendingLinks, *rec.Links)
+		}
+	case walOpAddNums:
+		if rec.Nums != nil {
+			rec.Nums.upgradeLegacy()
+			s.pendingNums = append(s.pendingNums, *rec.Nums)
+		}
+	case walOpRemoveLinks:
+		s.pendingLinks, _ = removeLinksTask(s.pendingLinks, rec.taskID())
+	case walOpRemoveNums:
+		s.pendingNums, _ = removeNumsTask(s.pendingNums, rec.taskID())
+	case walOpClaimJobs:
+		claimMatching(s.pendingLinks, rec.Owner, rec.At, func(task *ProcessTasksLinks) bool {
+			return claimable(task, rec.Owner, rec.StaleBefore)
+		})
+	case walOpTakeOver:
+		claimMatching(s.pendingLinks, rec.Owner, rec.At, func(task *ProcessTasksLinks) bool {
+			return abandoned(task, rec.Owner, rec.StaleBefore)
+		})
+	case walOpRenewJobs:
+		renewClaims(s.pendingLinks, rec.Owner, rec.At)
+	case walOpClearLinks:
+		jobs := make([]ProcessTasksLinks, 0, len(s.pendingLinks))
+		for _, task := range s.pendingLinks {
+			if task.ListNum > 0 {
+				jobs = append(jobs, task)
+			}
+		}
+		s.pendingLinks = jobs
+	case walOpClearNums:
+		s.pendingNums = nil
+	default:
+		slog.Warn("Unknown WAL record skipped", "op", rec.Op)
+	}
+}
+
+// append writes rec and syncs it before the in-memory state changes, so a
+// failed write never becomes visible to readers.
+func (s *walStorage) append(rec walRecord) error {
+	rec.Seq = s.seq + 1
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(payload, walTable))
+	copy(buf[walHeaderSize:], payload)
+
+	if _, err := s.file.Write(buf); err != nil {
+		s.rollback()
+		return fmt.Errorf("failed to append to wal: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		s.rollback()
+		return fmt.Errorf("failed to sync wal: %w", err)
+	}
+
+	s.size += int64(len(buf))
+	s.seq = rec.Seq
+	s.apply(rec)
+	return nil
+}
+
+// Compact writes the current state as a snapshot and cuts the log down to the
+// records appended while the snapshot was being written. Appends only wait
+// for the state copy and the final tail copy.
+func (s *walStorage) Compact() (SnapshotInfo, error) {
+	s.muCompact.Lock()
+	defer s.muCompact.Unlock()
+
+	s.mu.Lock()
+	snap := walSnapshot{
+		Seq:          s.seq,
+		LastNum:      s.lastNum,
+		Sets:         append([]models.ProcessedLinks{}, s.sets...),
+		PendingLinks: append([]ProcessTasksLinks{}, s.pendingLinks...),
+		PendingNums:  append([]ProcessTasksNums{}, s.pendingNums...),
+	}
+	covered := s.size
+	s.mu.Unlock()
+
+	size, err := writeSnapshot(snapshotName(s.path), nil, &snap)
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("failed to write wal snapshot: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.truncateHead(covered); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("failed to rewrite wal: %w", err)
+	}
+	return SnapshotInfo{Bytes: size, Sets: len(snap.Sets)}, nil
+}
+
+// truncateHead replaces the log with its bytes after offset.
+func (s *walStorage) truncateHead(offset int64) error {
+	tail := make([]byte, s.size-offset)
+	if _, err := s.file.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return err
+	}
+
+	if err := replaceFile(s.path, func(w io.Writer) error {
+		_, err := w.Write(tail)
+		return err
+	}, false); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return err
+	}
+	s.file.Close()
+	s.file = file
+	s.size = int64(len(tail))
+	return nil
+}
+
+// rollback drops a partially written record so later appends do not land
+// behind garbage that replay would stop at.
+func (s *walStorage) rollback() {
+	if err := s.file.Truncate(s.size); err != nil {
+		slog.Error("error in wal rollback", "error", err)
+		return
+	}
+	if _, err := s.file.Seek(s.size, io.SeekStart); err != nil {
+		slog.Error("error in wal rollback", "error", err)
+	}
+}
+
+func (s *walStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *walStorage) ReadAllFile() (*[]models.ProcessedLinks, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := make([]models.ProcessedLinks, len(s.sets))
+	copy(data, s.sets)
+	return &data, nil
+}
+
+func (s *walStorage) AddNewLinkPerm(item *models.ProcessedLinks) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.append(walRecord{Op: walOpAddSet, Set: item})
+}
+
+func (s *walStorage) ReadSet(listNum int) (*models.ProcessedLinks, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return findSet(s.sets, listNum)
+}
+
+func (s *walStorage) ListSets() (map[int]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	created := make(map[int]time.Time, len(s.sets))
+	for _, set := range s.sets {
+		created[set.ListNum] = set.CreatedAt
+	}
+	return created, nil
+}
+
+func (s *walStorage) DeleteSets(listNums []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(listNums) == 0 {
+		return nil
+	}
+	return s.append(walRecord{Op: walOpDeleteSets, Sets: listNums})
+}
+
+func (s *walStorage) LastListNum() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return max(s.lastNum, lastJobNum(s.pendingLinks)), nil
+}
+
+func (s *walStorage) AddLinksProcessList(masLinks *models.SetLinksGet, owner string) (string, error) {
+	return s.addLinksTask(ProcessTasksLinks{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
+	})
+}
+
+func (s *walStorage) AddLinksJob(listNum int, masLinks *models.SetLinksGet, owner string) (string, error) {
+	return s.addLinksTask(ProcessTasksLinks{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
+		ListNum:     listNum,
+	})
+}
+
+func (s *walStorage) addLinksTask(task ProcessTasksLinks) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(walRecord{Op: walOpAddLinks, Links: &task}); err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+func (s *walStorage) AddNumProcessList(masLinks *models.SetNumsOfLinksGet, owner string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task := ProcessTasksNums{
+		PendingTask: newPendingTask(owner),
+		Data:        *masLinks,
+	}
+	if err := s.append(walRecord{Op: walOpAddNums, Nums: &task}); err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+func (s *walStorage) RemoveLinksTask(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := removeLinksTask(s.pendingLinks, id); !found {
+		return os.ErrNotExist
+	}
+	return s.append(walRecord{Op: walOpRemoveLinks, ID: id})
+}
+
+func (s *walStorage) RemoveNumsTask(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := removeNumsTask(s.pendingNums, id); !found {
+		return os.ErrNotExist
+	}
+	return s.append(walRecord{Op: walOpRemoveNums, ID: id})
+}
+
+func (s *walStorage) GetPendingLinksData() ([]models.SetLinksGet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]models.SetLinksGet, 0, len(s.pendingLinks))
+	for _, task := range s.pendingLinks {
+		if task.ListNum == 0 {
+			result = append(result, task.Data)
+		}
+	}
+
+	if len(result) > 0 {
+		if err := s.append(walRecord{Op: walOpClearLinks}); err != nil {
+			return nil, fmt.Errorf("failed to clear pending links: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func (s *walStorage) ClaimPendingJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	return s.claimJobs(walOpClaimJobs, owner, staleBefore, func(task *ProcessTasksLinks) bool {
+		return claimable(task, owner, staleBefore)
+	})
+}
+
+func (s *walStorage) TakeOverJobs(owner string, staleBefore time.Time) ([]models.LinksJob, error) {
+	return s.claimJobs(walOpTakeOver, owner, staleBefore, func(task *ProcessTasksLinks) bool {
+		return abandoned(task, owner, staleBefore)
+	})
+}
+
+// claimJobs logs a claim record, which replays with the same match, and
+// returns the jobs it took. Nothing is logged when no job matches.
+func (s *walStorage) claimJobs(op, owner string, staleBefore time.Time, match func(*ProcessTasksLinks) bool) ([]models.LinksJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make(map[string]bool)
+	for i := range s.pendingLinks {
+		if match(&s.pendingLinks[i]) {
+			ids[s.pendingLinks[i].ID] = true
+		}
+	}
+	if len(ids) == 0 {
+		return []models.LinksJob{}, nil
+	}
+
+	rec := walRecord{Op: op, Owner: owner, At: claimClock().UTC(), StaleBefore: staleBefore}
+	if err := s.append(rec); err != nil {
+		return nil, fmt.Errorf("failed to claim jobs: %w", err)
+	}
+
+	result := make([]models.LinksJob, 0, len(ids))
+	for _, task := range s.pendingLinks {
+		if ids[task.ID] {
+			result = append(result, linksJob(task))
+		}
+	}
+	return result, nil
+}
+
+func (s *walStorage) RenewJobClaims(owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owns := slices.ContainsFunc(s.pendingLinks, func(task ProcessTasksLinks) bool {
+		return task.ListNum > 0 && task.Owner == owner
+	})
+	if !owns {
+		return nil
+	}
+	if err := s.append(walRecord{Op: walOpRenewJobs, Owner: owner, At: claimClock().UTC()}); err != nil {
+		return fmt.Errorf("failed to renew job claims: %w", err)
+	}
+	return nil
+}
+
+func (s *walStorage) GetPendingNumsData() ([]models.SetNumsOfLinksGet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]models.SetNumsOfLinksGet, len(s.pendingNums))
+	for i, task := range s.pendingNums {
+		result[i] = task.Data
+	}
+
+	if len(result) > 0 {
+		if err := s.append(walRecord{Op: walOpClearNums}); err != nil {
+			return nil, fmt.Errorf("failed to clear pending nums: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func removeLinksTask(tasks []ProcessTasksLinks, id string) ([]ProcessTasksLinks, bool) {
+	for i, task := range tasks {
+		if task.ID == id {
+			return append(tasks[:i:i], tasks[i+1:]...), true
+		}
+	}
+	return tasks, false
+}
+
+func removeNumsTask(tasks []ProcessTasksNums, id string) ([]ProcessTasksNums, bool) {
+	for i, task := range tasks {
+		if task.ID == id {
+			return append(tasks[:i:i], tasks[i+1:]...), true
+		}
+	}
+	return tasks, false
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
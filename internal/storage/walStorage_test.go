@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"status-links/internal/models"
+	"testing"
+	"time"
+)
+
+func TestWALStorage(t *testing.T) {
+	openWAL := func(t *testing.T, path string) *walStorage {
+		t.Helper()
+		s, err := NewWALStorage(path)
+		if err != nil {
+			t.Fatalf("Unexpected error opening wal: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+
+	t.Run("state survives reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tasks.wal")
+		s := openWAL(t, path)
+
+		item := &models.ProcessedLinks{
+			Answer:  models.LinksAnswer{"https://example.com/": {Status: models.StatusAvailable}},
+			ListNum: 1,
+		}
+		if err := s.AddNewLinkPerm(item); err != nil {
+			t.Fatalf("Unexpected error adding set: %v", err)
+		}
+		id, err := s.AddLinksProcessList(&models.SetLinksGet{Links: []string{"a.com"}}, "")
+		if err != nil {
+			t.Fatalf("Unexpected error adding links: %v", err)
+		}
+		if _, err := s.AddLinksJob(2, &models.SetLinksGet{Links: []string{"b.com"}}, ""); err != nil {
+			t.Fatalf("Unexpected error adding job: %v", err)
+		}
+		if _, err := s.AddNumProcessList(&models.SetNumsOfLinksGet{NumsLinks: []int{1}}, ""); err != nil {
+			t.Fatalf("Unexpected error adding nums: %v", err)
+		}
+		if err := s.RemoveLinksTask(id); err != nil {
+			t.Fatalf("Unexpected error removing links: %v", err)
+		}
+		s.Close()
+
+		reopened := openWAL(t, path)
+		data, err := reopened.ReadAllFile()
+		if err != nil {
+			t.Fatalf("Unexpected error reading: %v", err)
+		}
+		if len(*data) != 1 || (*data)[0].Answer["https://example.com/"].Status != models.StatusAvailable {
+			t.Errorf("Expected the stored set back, got %+v", *data)
+		}
+
+		links, _ := reopened.GetPendingLinksData()
+		if len(links) != 0 {
+			t.Errorf("Expected removed links to stay removed, got %v", links)
+		}
+		jobs, _ := reopened.ClaimPendingJobs("", time.Time{})
+		if len(jobs) != 1 || jobs[0].ListNum != 2 {
+			t.Errorf("Expected one pending job, got %+v", jobs)
+		}
+		nums, _ := reopened.GetPendingNumsData()
+		if len(nums) != 1 {
+			t.Errorf("Expected one pending nums set, got %v", nums)
+		}
+	})
+
+	t.Run("GetPendingLinksData clears sets but keeps jobs", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tasks.wal")
+		s := openWAL(t, path)
+
+		s.AddLinksProcessList(&models.SetLinksGet{Links: []string{"a.com"}}, "")
+		s.AddLinksJob(3, &models.SetLinksGet{Links: []string{"b.com"}}, "")
+
+		links, err := s.GetPendingLinksData()
+		if err != nil || len(links) != 1 {
+			t.Fatalf("Expected one pending set, got %v (err %v)", links, err)
+		}
+		s.Close()
+
+		reopened := openWAL(t, path)
+		links, _ = reopened.GetPendingLinksData()
+		if len(links) != 0 {
+			t.Errorf("Expected pending sets to be cleared, got %v", links)
+		}
+		jobs, _ := reopened.ClaimPendingJobs("", time.Time{})
+		if len(jobs) != 1 {
+			t.Errorf("Expected the job to stay pending, got %+v", jobs)
+		}
+	})
+
+	t.Run("claims survive replay", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tasks.wal")
+		s := openWAL(t, path)
+
+		s.AddLinksJob(1, &models.SetLinksGet{Links: []string{"a.com"}}, "")
+		s.AddLinksJob(2, &models.SetLinksGet{Links: []string{"b.com"}}, "node-b")
+		if jobs, _ := s.ClaimPendingJobs("node-a", time.Time{}); len(jobs) != 1 || jobs[0].ListNum != 1 {
+			t.Fatalf("Expected node-a to claim job 1, got %+v", jobs)
+		}
+		s.Close()
+
+		reopened := openWAL(t, path)
+		jobs, _ := reopened.ClaimPendingJobs("node-a", time.Time{})
+		if len(jobs) != 1 || jobs[0].Owner != "node-a" || jobs[0].Attempts != 2 {
+			t.Errorf("Expected job 1 owned by node-a on its second attempt, got %+v", jobs)
+		}
+	})
+
+	t.Run("lease claims and renewals survive replay", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tasks.wal")
+		s := openWAL(t, path)
+
+		setClaimClock(t, leaseStart)
+		s.AddLinksJob(1, &models.SetLinksGet{Links: []string{"a.com"}}, "old-host")
+		s.AddLinksJob(2, &models.SetLinksGet{Links: []string{"b.com"}}, "node-b")
+		setClaimClock(t, leaseStart.Add(2*time.Hour))
+		s.RenewJobClaims("node-b")
+		if jobs, _ := s.ClaimPendingJobs("node-a", leaseStart.Add(time.Hour)); len(jobs) != 1 || jobs[0].ListNum != 1 {
+			t.Fatalf("Expected node-a to take over job 1, got %+v", jobs)
+		}
+		s.Close()
+
+		reopened := openWAL(t, path)
+		jobs, _ := reopened.ClaimPendingJobs("node-a", time.Time{})
+		if len(jobs) != 1 || jobs[0].ListNum != 1 || jobs[0].Attempts != 2 {
+			t.Errorf("Expected replay to keep job 1 with node-a, got %+v", jobs)
+		}
+	})
+
+	t.Run("claims and renewals that match no job are not logged", func(t *testing.T) {
+		s := openWAL(t, filepath.Join(t.TempDir(), "tasks.wal"))
+		s.AddLinksJob(1, &models.SetLinksGet{Links: []string{"a.com"}}, "node-b")
+		size := s.size
+
+		if err := s.RenewJobClaims("node-a"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if jobs, err := s.TakeOverJobs("node-a", time.Time{}); err != nil || len(jobs) != 0 {
+			t.Fatalf("Expected nothing to take over, got %+v, %v", jobs, err)
+		}
+		if jobs, err := s.ClaimPendingJobs("node-a", time.Time{}); err != nil || len(jobs) != 0 {
+			t.Fatalf("Expected nothing to claim, got %+v, %v", jobs, err)
+		}
+		if s.size != size {
+			t.Errorf("Expected the log to stay at %d bytes, got %d", size, s.size)
+		}
+
+		s.RenewJobClaims("node-b")
+		if s.size == size {
+			t.Error("Expected the owner's renewal to be logged")
+		}
+	})
+
+	t.Run("pending jobs count towards the last number", func(t *testing.T) {
+		s := openWAL(t, filepath.Join(t.TempDir(), "tasks.wal"))
+		defer s.Close()
+
+		s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 3})
+		s.AddLinksJob(7, &models.SetLinksGet{Links: []string{"a.com"}}, "node-b")
+		if last, err := s.LastListNum(); err != nil || last != 7 {
+			t.Errorf("Expected 7 from the pending job, got %d, %v", last, err)
+		}
+	})
+
+	t.Run("remove unknown task", func(t *testing.T) {
+		s := openWAL(t, filepath.Join(t.TempDir(), "tasks.wal"))
+		if err := s.RemoveLinksTask("missing"); !os.IsNotExist(err) {
+			t.Errorf("Expected not-exist error, got %v", err)
+		}
+		if err := s.RemoveNumsTask("missing"); !os.IsNotExist(err) {
+			t.Errorf("Expected not-exist error, got %v", err)
+		}
+	})
+
+	t.Run("corruption before valid records is an error", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tasks.wal")
+		s := openWAL(t, path)
+		s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 1})
+		firstSize := s.size
+		s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 2})
+		fullSize := s.size
+		s.Close()
+
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteAt([]byte("X"), firstSize-2)
+		f.Close()
+
+		if reopened, err := NewWALStorage(path); !errors.Is(err, errWALCorrupt) {
+			if reopened != nil {
+				reopened.Close()
+			}
+			t.Fatalf("Expected corrupt wal error, got %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != fullSize {
+			t.Errorf("Expected log left intact at %d bytes, got %d", fullSize, info.Size())
+		}
+	})
+
+	tornTail := map[string]func(t *testing.T, path string, size int64){
+		"partial record": func(t *testing.T, path string, size int64) {
+			if err := os.Truncate(path, size-5); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"garbage appended": func(t *testing.T, path string, size int64) {
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte{0x10, 0, 0, 0, 1, 2, 3})
+			f.Close()
+		},
+		"zeros after a bad header": func(t *testing.T, path string, size int64) {
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write(make([]byte, 64))
+			f.Close()
+		},
+		"checksum mismatch": func(t *testing.T, path string, size int64) {
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteAt([]byte("X"), size-2)
+			f.Close()
+		},
+	}
+
+	for name, corrupt := range tornTail {
+		t.Run("torn tail is truncated: "+name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tasks.wal")
+			s := openWAL(t, path)
+			s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 1})
+			goodSize := s.size
+			s.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 2})
+			fullSize := s.size
+			s.Close()
+
+			corrupt(t, path, fullSize)
+			if name == "garbage appended" || name == "zeros after a bad header" {
+				goodSize = fullSize
+			}
+
+			reopened := openWAL(t, path)
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != goodSize {
+				t.Errorf("Expected file truncated to %d bytes, got %d", goodSize, info.Size())
+			}
+
+			if err := reopened.AddNewLinkPerm(&models.ProcessedLinks{Answer: models.LinksAnswer{}, ListNum: 3}); err != nil {
+				t.Fatalf("Unexpected error appending after recovery: %v", err)
+			}
+			reopened.Close()
+
+			final := openWAL(t, path)
+			data, _ := final.ReadAllFile()
+			last := (*data)[len(*data)-1]
+			if last.ListNum != 3 {
+				t.Errorf("Expected record appended after recovery to replay, got %+v", *data)
+			}
+		})
+	}
+}